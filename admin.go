@@ -0,0 +1,237 @@
+package infrastructure
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const adminHelp = `commands:
+  level [debug|info|warn|error]  show or change the global log level
+  mute <module> [ttl]            suppress a module, 10m unless a ttl such as 30s is given
+  solo <module> [ttl]            suppress every module but this one
+  unmute [module]                remove the toggles of a module or of every module
+  tail [n]                       show the last records of the ring buffer
+  goroutines [full]              show the goroutine count or dump all stacks
+  health                         show host probes and health checks, run the self test
+  flush                          flush the log output and providers
+  restart [component]            list the components or restart one
+  shutdown                       release resources and exit
+  quit                           close this session
+`
+
+/*
+Listen on a unix socket reserved to the owner, closed when resources are released
+
+The socket is created in a directory only the owner can enter and moved to
+its path once restricted, so no one else can connect in between.
+*/
+func (pm *ProjectInfrastructure) listenUnix(_path string) (net.Listener, error) {
+	// A socket file left behind by a crashed run blocks Listen.
+	if err := os.Remove(_path); err != nil && !os.IsNotExist(err) {
+		return nil, errors.Wrap(err, "remove stale socket")
+	}
+	dir, err := os.MkdirTemp(filepath.Dir(_path), ".socket-")
+	if err != nil {
+		return nil, errors.Wrap(err, "socket directory")
+	}
+	defer os.RemoveAll(dir)
+
+	tmp := filepath.Join(dir, "s")
+	l, err := net.Listen("unix", tmp)
+	if err != nil {
+		return nil, err
+	}
+	// Unlinking on close would target the temporary path.
+	l.(*net.UnixListener).SetUnlinkOnClose(false)
+	if err := os.Chmod(tmp, 0600); err != nil {
+		l.Close()
+		return nil, errors.Wrap(err, "chmod socket")
+	}
+	if err := os.Rename(tmp, _path); err != nil {
+		l.Close()
+		return nil, errors.Wrap(err, "move socket")
+	}
+
+	go func() {
+		<-pm.cancel.Done()
+		l.Close()
+		os.Remove(_path)
+	}()
+	return l, nil
+}
+
+// Serve admin console sessions until resources are released.
+func (pm *ProjectInfrastructure) startAdminSocket(_path string) error {
+	l, err := pm.listenUnix(_path)
+	if err != nil {
+		return errors.Wrap(err, "admin socket")
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go pm.adminSession(conn)
+		}
+	}()
+	return nil
+}
+
+func (pm *ProjectInfrastructure) adminSession(_conn net.Conn) {
+	defer _conn.Close()
+
+	user := peerUser(_conn)
+	scanner := bufio.NewScanner(_conn)
+	for scanner.Scan() {
+		args := strings.Fields(scanner.Text())
+		if len(args) == 0 {
+			continue
+		}
+		if args[0] == "quit" {
+			return
+		}
+		pm.adminCommand(_conn, user, args)
+	}
+}
+
+func (pm *ProjectInfrastructure) adminCommand(_w io.Writer, _user string, _args []string) {
+	switch _args[0] {
+	case "level":
+		if len(_args) == 1 {
+			fmt.Fprintln(_w, pm.LogLevel())
+			return
+		}
+		err := pm.SetLogLevel(_args[1])
+		pm.OperatorAction(_user, "admin.level", _args[1], err)
+		if err != nil {
+			fmt.Fprintln(_w, "error:", err)
+			return
+		}
+		pm.logOutput("admin", "info", errors.Errorf("log level set to %s from admin console", _args[1]), false, nil)
+		fmt.Fprintln(_w, "ok")
+	case "mute", "solo":
+		if len(_args) < 2 {
+			fmt.Fprint(_w, adminHelp)
+			return
+		}
+		var ttl time.Duration
+		var err error
+		if len(_args) > 2 {
+			ttl, err = time.ParseDuration(_args[2])
+		}
+		if err == nil {
+			if _args[0] == "mute" {
+				err = pm.MuteModule(_args[1], ttl)
+			} else {
+				err = pm.SoloModule(_args[1], ttl)
+			}
+		}
+		pm.OperatorAction(_user, "admin."+_args[0], _args[1], err)
+		if err != nil {
+			fmt.Fprintln(_w, "error:", err)
+			return
+		}
+		fmt.Fprintln(_w, "ok")
+	case "unmute":
+		target := "all"
+		module := ""
+		if len(_args) > 1 {
+			target, module = _args[1], _args[1]
+		}
+		pm.UnmuteModule(module)
+		pm.OperatorAction(_user, "admin.unmute", target, nil)
+		fmt.Fprintln(_w, "ok")
+	case "tail":
+		n := 20
+		if len(_args) > 1 {
+			var err error
+			if n, err = strconv.Atoi(_args[1]); err != nil {
+				fmt.Fprintln(_w, "error:", err)
+				return
+			}
+		}
+		for _, r := range pm.TailLogs(n) {
+			fmt.Fprintf(_w, "%s %-5s %s %s\n", r.Time.Format(jsonTimestampFormat), r.Severity, r.Module, r.Message)
+		}
+	case "goroutines":
+		if len(_args) > 1 && _args[1] == "full" {
+			buf := make([]byte, 1<<20)
+			_w.Write(buf[:runtime.Stack(buf, true)])
+			return
+		}
+		fmt.Fprintln(_w, runtime.NumGoroutine())
+	case "health":
+		for name, r := range pm.HostHealth() {
+			if r.Err != nil {
+				fmt.Fprintf(_w, "%s: %v\n", name, r.Err)
+				continue
+			}
+			fmt.Fprintf(_w, "%s: %v\n", name, r.Values)
+		}
+		report := pm.CheckHealth(context.Background())
+		for name, r := range report.Checks {
+			switch {
+			case r.Error != "":
+				fmt.Fprintf(_w, "%s: %s\n", name, r.Error)
+			case len(r.BlockedBy) > 0:
+				fmt.Fprintf(_w, "%s: blocked by %s\n", name, strings.Join(r.BlockedBy, ", "))
+			default:
+				fmt.Fprintf(_w, "%s: ok\n", name)
+			}
+		}
+		if err := pm.SelfTest(); err != nil {
+			fmt.Fprintln(_w, err)
+			return
+		}
+		fmt.Fprintln(_w, "ok")
+	case "flush":
+		// stdout and the rotated file are unbuffered, only buffered writers need it.
+		if f, ok := pm.out.(interface{ Flush() error }); ok {
+			if err := f.Flush(); err != nil {
+				fmt.Fprintln(_w, "error:", err)
+				return
+			}
+		}
+		pm.OperatorAction(_user, "admin.flush", "providers", nil)
+		for name, err := range pm.Flush() {
+			if err != nil {
+				fmt.Fprintf(_w, "%s: %v\n", name, err)
+			}
+		}
+		fmt.Fprintln(_w, "ok")
+	case "restart":
+		if len(_args) < 2 {
+			for _, name := range pm.Components() {
+				fmt.Fprintln(_w, name)
+			}
+			return
+		}
+		err := pm.RestartComponent(_args[1])
+		pm.OperatorAction(_user, "admin.restart", _args[1], err)
+		if err != nil {
+			fmt.Fprintln(_w, "error:", err)
+			return
+		}
+		fmt.Fprintln(_w, "ok")
+	case "shutdown":
+		pm.OperatorAction(_user, "admin.shutdown", "process", nil)
+		pm.logOutput("admin", "warn", errors.New("shutdown requested from admin console"), false, nil)
+		fmt.Fprintln(_w, "ok")
+		pm.ResourceRelease()
+		pm.exit(0)
+	default:
+		fmt.Fprint(_w, adminHelp)
+	}
+}
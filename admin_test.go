@@ -0,0 +1,156 @@
+package infrastructure
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestListenUnix(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "admin.sock")
+	pm := &ProjectInfrastructure{}
+	pm.cancel, pm.cancelFunc = context.WithCancel(context.Background())
+
+	l, err := pm.listenUnix(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode()&os.ModeSocket == 0 || info.Mode().Perm() != 0600 {
+		t.Fatalf("socket mode = %v, want a 0600 socket", info.Mode())
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Fatalf("temporary socket directory left behind: %v", entries)
+	}
+
+	pm.cancelFunc()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("socket file not removed on release")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, err := l.Accept(); err == nil {
+		t.Fatal("listener still open after release")
+	}
+}
+
+// Component counting its starts, for the restart command.
+type countingComponent struct {
+	starts int
+}
+
+func (c *countingComponent) Name() string { return "cache" }
+
+func (c *countingComponent) Start(context.Context) error {
+	c.starts++
+	return nil
+}
+
+func (c *countingComponent) Stop(context.Context) error { return nil }
+
+func TestAdminConsole(t *testing.T) {
+	dir := t.TempDir()
+	socket := filepath.Join(dir, "admin.sock")
+	operatorLog := filepath.Join(dir, "operator.log")
+	pm, err := NewProjectInfrastructure(context.Background(),
+		WithLibraryMode(true),
+		WithLogOutput("writer"),
+		WithLogWriter(io.Discard),
+		WithLogLevel("info"),
+		WithLogRingBuffer(16),
+		WithAdminSocket(socket),
+		WithOperatorLog(operatorLog),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pm.Shutdown()
+	cache := &countingComponent{}
+	if err := pm.RegisterComponent(cache); err != nil {
+		t.Fatal(err)
+	}
+	pm.ErrorTransmit("db", "error", errors.New("connection refused"), false, false)
+
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	lines := bufio.NewScanner(conn)
+
+	tests := []struct {
+		command string
+		// Last line of the answer
+		answer string
+	}{
+		{"level", "info"},
+		{"tail 1", "connection refused"},
+		{"level debug", "ok"},
+		{"level loud", "error: "},
+		{"mute db 1m", "ok"},
+		{"restart", "cache"},
+		{"restart cache", "ok"},
+		{"health", "ok"},
+		{"help", "quit"},
+	}
+	for _, tt := range tests {
+		if _, err := conn.Write([]byte(tt.command + "\n")); err != nil {
+			t.Fatal(err)
+		}
+		for {
+			if !lines.Scan() {
+				t.Fatalf("%s: session closed: %v", tt.command, lines.Err())
+			}
+			if strings.Contains(lines.Text(), tt.answer) {
+				break
+			}
+		}
+	}
+
+	if pm.LogLevel() != "debug" {
+		t.Errorf("log level %s, want debug", pm.LogLevel())
+	}
+	if cache.starts != 2 {
+		t.Errorf("component started %d times, want 2", cache.starts)
+	}
+	pm.ErrorTransmit("db", "error", errors.New("muted"), false, false)
+	for _, r := range pm.TailLogs(0) {
+		if r.Message == "muted" {
+			t.Error("record of a muted module written")
+		}
+	}
+
+	// Every change is recorded in the operator log
+	data, err := os.ReadFile(operatorLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, action := range []string{"admin.level", "admin.mute", "admin.restart"} {
+		if !strings.Contains(string(data), action) {
+			t.Errorf("operator log lacks %s: %s", action, data)
+		}
+	}
+
+	conn.Write([]byte("quit\n"))
+	if lines.Scan() {
+		t.Errorf("session still open after quit, read %q", lines.Text())
+	}
+}
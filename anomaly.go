@@ -0,0 +1,117 @@
+package infrastructure
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// Weight of the last interval in the baseline of a module
+	anomalyAlpha = 0.2
+	// Intervals learnt before a module can be reported
+	anomalyWarmup = 5
+)
+
+// Per-module error counts of the current interval and their learnt baseline.
+type anomalyDetector struct {
+	mu        sync.Mutex
+	threshold float64
+	counts    map[string]int
+	baselines map[string]*errorBaseline
+}
+
+// Exponentially weighted mean and variance of the errors per interval.
+type errorBaseline struct {
+	mean      float64
+	variance  float64
+	intervals int
+}
+
+func newAnomalyDetector(_threshold float64) *anomalyDetector {
+	if _threshold <= 0 {
+		_threshold = _defaultAnomalyThreshold
+	}
+	return &anomalyDetector{
+		threshold: _threshold,
+		counts:    make(map[string]int),
+		baselines: make(map[string]*errorBaseline),
+	}
+}
+
+func (d *anomalyDetector) observe(_module, _severity string) {
+	if _severity != "error" && _severity != "fatal" && _severity != "panic" {
+		return
+	}
+	d.mu.Lock()
+	d.counts[_module]++
+	d.mu.Unlock()
+}
+
+type errorAnomaly struct {
+	module   string
+	errors   int
+	baseline float64
+	zscore   float64
+}
+
+// Close the interval, report the modules far above their baseline and fold
+// the counts into it. Modules without errors learn a zero interval.
+func (d *anomalyDetector) rotate() []errorAnomaly {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var anomalies []errorAnomaly
+	for module := range d.counts {
+		if _, ok := d.baselines[module]; !ok {
+			d.baselines[module] = &errorBaseline{}
+		}
+	}
+	for module, b := range d.baselines {
+		x := float64(d.counts[module])
+		// A floor of one error keeps a silent module from flagging its first error.
+		z := (x - b.mean) / math.Max(math.Sqrt(b.variance), 1)
+		if b.intervals >= anomalyWarmup && z > d.threshold {
+			anomalies = append(anomalies, errorAnomaly{module: module, errors: int(x), baseline: b.mean, zscore: z})
+		}
+		if b.intervals == 0 {
+			b.mean = x
+		} else {
+			diff := x - b.mean
+			b.mean += anomalyAlpha * diff
+			b.variance = (1 - anomalyAlpha) * (b.variance + anomalyAlpha*diff*diff)
+		}
+		b.intervals++
+	}
+	d.counts = make(map[string]int)
+	return anomalies
+}
+
+// Close an interval every period and log an anomaly event for the modules
+// whose error rate deviates from their baseline.
+func (pm *ProjectInfrastructure) detectAnomalies(_interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(_interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+			case <-pm.cancel.Done():
+				return
+			}
+			for _, a := range pm.anomalies.rotate() {
+				pm.logOutput(a.module, "warn",
+					errors.Errorf("anomaly: %d errors over %s, baseline %.1f", a.errors, _interval, a.baseline),
+					false, logrus.Fields{
+						"event":    "anomaly",
+						"errors":   a.errors,
+						"baseline": math.Round(a.baseline*100) / 100,
+						"zscore":   math.Round(a.zscore*100) / 100,
+					})
+			}
+		}
+	}()
+}
@@ -0,0 +1,133 @@
+package infrastructure
+
+import (
+	"context"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// Object store receiving the rotated log files, e.g. NewS3Archiver.
+type Archiver interface {
+	Name() string
+	Upload(ctx context.Context, key string, body []byte) error
+}
+
+// File extension of the archived files by codec.
+var archiveExtensions = map[string]string{"gzip": ".gz", "zstd": ".zst", "snappy": ".sz"}
+
+// Looks already compressed, e.g. by logrotate, and is uploaded as is.
+func compressedLogFile(_path string) bool {
+	switch filepath.Ext(_path) {
+	case ".gz", ".zst", ".sz", ".bz2", ".xz":
+		return true
+	}
+	return false
+}
+
+// Upload the rotated log files every interval and remove them once stored.
+// Failed uploads are retried at the next sweep.
+func (pm *ProjectInfrastructure) archiveLogs(_opts ProjectInfrastructureOptions) error {
+	codec, err := newCodec(_opts.ArchiveCompression, "best", _opts.CompressionCodecs)
+	if err != nil {
+		return errors.Wrap(err, "log archive")
+	}
+	interval := _opts.ArchiveInterval
+	if interval <= 0 {
+		interval = _defaultArchiveInterval
+	}
+	window, err := parseTimeWindow(_opts.ArchiveWindowStart, _opts.ArchiveWindowEnd)
+	if err != nil {
+		return errors.Wrap(err, "log archive")
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+			case <-pm.cancel.Done():
+				return
+			}
+			if !window.contains(time.Now()) {
+				continue
+			}
+			for _, glob := range logFileGlobs(_opts) {
+				for _, file := range rotatedLogFiles(glob) {
+					ctx, cancel := context.WithTimeout(pm.cancel, interval)
+					key, err := pm.archiveLogFile(ctx, _opts, codec, file)
+					cancel()
+					if err != nil {
+						pm.logOutput("archive", "warn", errors.Errorf("archive %s: %v", file, err), false, nil)
+						continue
+					}
+					pm.logOutput("archive", "info", errors.Errorf("archived %s to %s", file, key), false,
+						logrus.Fields{"archiver": _opts.LogArchiver.Name()})
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// The files of the glob but the one written to, the last modified.
+func rotatedLogFiles(_glob string) []string {
+	matches, err := filepath.Glob(_glob)
+	if err != nil {
+		return nil
+	}
+	var files []string
+	var current string
+	var latest time.Time
+	for _, p := range matches {
+		if strings.HasSuffix(p, "_lock") || strings.HasSuffix(p, "_symlink") {
+			continue
+		}
+		fi, err := os.Lstat(p)
+		if err != nil || !fi.Mode().IsRegular() {
+			continue
+		}
+		files = append(files, p)
+		if fi.ModTime().After(latest) {
+			current, latest = p, fi.ModTime()
+		}
+	}
+	rotated := files[:0]
+	for _, p := range files {
+		if p != current {
+			rotated = append(rotated, p)
+		}
+	}
+	return rotated
+}
+
+func (pm *ProjectInfrastructure) archiveLogFile(_ctx context.Context, _opts ProjectInfrastructureOptions, _codec Codec, _path string) (string, error) {
+	body, err := os.ReadFile(_path)
+	if err != nil {
+		return "", err
+	}
+	key := path.Join(_opts.ArchivePrefix, filepath.Base(_path))
+	if _codec != nil && !compressedLogFile(_path) {
+		if body, err = _codec.Compress(body); err != nil {
+			return "", errors.Wrap(err, _codec.Name())
+		}
+		ext, ok := archiveExtensions[_codec.Name()]
+		if !ok {
+			ext = "." + _codec.Name()
+		}
+		key += ext
+	}
+	if err := pm.shipping.wait(_ctx, len(body)); err != nil {
+		return "", err
+	}
+	if err := _opts.LogArchiver.Upload(_ctx, key, body); err != nil {
+		return "", errors.Wrapf(err, "upload to %s", _opts.LogArchiver.Name())
+	}
+	return key, os.Remove(_path)
+}
@@ -0,0 +1,95 @@
+package infrastructure
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	filerotatelogs "github.com/lestrrat-go/file-rotatelogs"
+	"github.com/pkg/errors"
+)
+
+// rotatelogs removes files after a week when no retention is given, audit
+// files are kept.
+const _auditRetention = 100 * 365 * 24 * time.Hour
+
+// Security relevant events, kept apart from the operational log.
+type auditLog struct {
+	mu sync.Mutex
+	w  io.Writer
+	// Rotated files opened from AuditLogPath, nil with an AuditWriter
+	file   io.Closer
+	closed bool
+}
+
+func openAuditLog(_opts ProjectInfrastructureOptions) (*auditLog, error) {
+	if _opts.AuditWriter != nil {
+		return &auditLog{w: _opts.AuditWriter}, nil
+	}
+	// Files are opened in append mode and never truncated.
+	f, err := filerotatelogs.New(_opts.AuditLogPath,
+		filerotatelogs.WithRotationSize(int64(_opts.AuditMaxFileSize)),
+		filerotatelogs.WithMaxAge(_auditRetention),
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "open audit log")
+	}
+	return &auditLog{w: f, file: f}, nil
+}
+
+// Close the audit files, a writer given with WithAuditWriter is left open.
+// Events are refused afterwards, rotatelogs would reopen a file.
+func (a *auditLog) close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.closed = true
+	if a.file == nil {
+		return nil
+	}
+	return a.file.Close()
+}
+
+/*
+Record a security relevant event in the audit log as a JSON line
+
+@module: module the event comes from
+
+@action: what happened, e.g. "user.login"
+
+@fields: details of the event, they cannot replace the timestamp, module and action
+*/
+func (pm *ProjectInfrastructure) AuditTransmit(_module, _action string, _fields map[string]interface{}) error {
+	if pm.auditLog == nil {
+		return errors.New("audit log not configured, see WithAuditLog")
+	}
+	if _module == "" || _action == "" {
+		return errors.Errorf("audit event needs a module and an action, got %q %q", _module, _action)
+	}
+
+	record := make(map[string]interface{}, len(_fields)+3)
+	for k, v := range _fields {
+		// Errors marshal to "{}", keep their message instead.
+		if err, ok := v.(error); ok {
+			v = err.Error()
+		}
+		record[k] = v
+	}
+	record["timestamp"] = time.Now().Format(jsonTimestampFormat)
+	record["module"] = _module
+	record["action"] = _action
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return errors.Wrap(err, "marshal audit event")
+	}
+	pm.auditLog.mu.Lock()
+	defer pm.auditLog.mu.Unlock()
+	if pm.auditLog.closed {
+		return errors.New("audit log closed, resources are released")
+	}
+	if _, err := pm.auditLog.w.Write(append(line, '\n')); err != nil {
+		return errors.Wrap(err, "write audit log")
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package infrastructure
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestAuditTransmit(t *testing.T) {
+	var buf bytes.Buffer
+	pm, err := NewProjectInfrastructure(context.Background(), WithLibraryMode(true), WithAuditWriter(&buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		module, action string
+		fields         map[string]interface{}
+		ok             bool
+	}{
+		{"auth", "user.login", map[string]interface{}{"user": "alice", "module": "spoofed", "cause": errors.New("bad password")}, true},
+		{"", "user.login", nil, false},
+		{"auth", "", nil, false},
+	}
+	for _, tt := range tests {
+		if err := pm.AuditTransmit(tt.module, tt.action, tt.fields); (err == nil) != tt.ok {
+			t.Errorf("AuditTransmit(%q, %q) err = %v", tt.module, tt.action, err)
+		}
+	}
+
+	var record map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatal(err)
+	}
+	if record["module"] != "auth" || record["user"] != "alice" || record["cause"] != "bad password" {
+		t.Errorf("unexpected audit record %v", record)
+	}
+
+	if err := pm.Shutdown(); err != nil {
+		t.Fatal(err)
+	}
+	if err := pm.AuditTransmit("auth", "user.logout", nil); err == nil {
+		t.Error("audit event accepted after release")
+	}
+}
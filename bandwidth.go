@@ -0,0 +1,105 @@
+package infrastructure
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+/*
+Pace the bytes shipped off the host, shared by the loki, otlp and remote
+outputs and the log archive so that together they stay under the rate
+
+A send of n bytes starts once the previous ones have used their share of
+the rate, the rate is kept on average and a single send is never split.
+*/
+type bandwidthLimiter struct {
+	rate int
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+// nil, never waiting, without a rate.
+func newBandwidthLimiter(_bytesPerSecond int) *bandwidthLimiter {
+	if _bytesPerSecond <= 0 {
+		return nil
+	}
+	return &bandwidthLimiter{rate: _bytesPerSecond}
+}
+
+// Wait until n bytes may be sent.
+func (l *bandwidthLimiter) wait(_ctx context.Context, _n int) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	start := time.Now()
+	if l.next.After(start) {
+		start = l.next
+	}
+	end := start.Add(time.Duration(_n) * time.Second / time.Duration(l.rate))
+	l.next = end
+	l.mu.Unlock()
+
+	d := time.Until(start)
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-_ctx.Done():
+		// Give the share back unless later sends are queued behind it.
+		l.mu.Lock()
+		if l.next.Equal(end) {
+			l.next = start
+		}
+		l.mu.Unlock()
+		return _ctx.Err()
+	}
+}
+
+// Daily window in local time, the end before the start spans midnight.
+type timeWindow struct {
+	start, end time.Duration
+}
+
+// The window from start to end, "15:04" times, nil when both are empty.
+func parseTimeWindow(_start, _end string) (*timeWindow, error) {
+	if _start == "" && _end == "" {
+		return nil, nil
+	}
+	var w timeWindow
+	for _, t := range []struct {
+		value string
+		d     *time.Duration
+	}{{_start, &w.start}, {_end, &w.end}} {
+		parsed, err := time.Parse("15:04", t.value)
+		if err != nil {
+			return nil, errors.Errorf("invalid window time %q, expected HH:MM", t.value)
+		}
+		*t.d = time.Duration(parsed.Hour())*time.Hour + time.Duration(parsed.Minute())*time.Minute
+	}
+	if w.start == w.end {
+		return nil, errors.Errorf("empty window %s-%s", _start, _end)
+	}
+	return &w, nil
+}
+
+// Report whether the time is in the window, always true for a nil window.
+func (w *timeWindow) contains(_t time.Time) bool {
+	if w == nil {
+		return true
+	}
+	h, m, s := _t.Clock()
+	d := time.Duration(h)*time.Hour + time.Duration(m)*time.Minute + time.Duration(s)*time.Second
+	if w.start < w.end {
+		return d >= w.start && d < w.end
+	}
+	return d >= w.start || d < w.end
+}
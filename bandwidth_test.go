@@ -0,0 +1,80 @@
+package infrastructure
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestBandwidthLimiterPaces(t *testing.T) {
+	l := newBandwidthLimiter(1000)
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := l.wait(context.Background(), 100); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The first send starts at once, the next ones 100ms apart.
+	if elapsed := time.Since(start); elapsed < 190*time.Millisecond || elapsed > time.Second {
+		t.Fatalf("3 sends of 100 bytes at 1000 B/s took %s", elapsed)
+	}
+
+	var unlimited *bandwidthLimiter
+	if err := unlimited.wait(context.Background(), 1<<30); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBandwidthLimiterCancel(t *testing.T) {
+	l := newBandwidthLimiter(10)
+	l.wait(context.Background(), 100)
+	reserved := l.next
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := l.wait(ctx, 100); err == nil {
+		t.Fatal("wait not cancelled")
+	}
+	if !l.next.Equal(reserved) {
+		t.Fatalf("cancelled send kept its share, next %s, want %s", l.next, reserved)
+	}
+}
+
+func TestTimeWindow(t *testing.T) {
+	at := func(_clock string) time.Time {
+		c, _ := time.Parse("15:04", _clock)
+		return time.Date(2026, 1, 1, c.Hour(), c.Minute(), 0, 0, time.Local)
+	}
+	tests := []struct {
+		start, end string
+		in, out    []string
+	}{
+		{"09:00", "17:00", []string{"09:00", "12:30", "16:59"}, []string{"08:59", "17:00", "23:00"}},
+		{"22:00", "06:00", []string{"22:00", "23:59", "00:00", "05:59"}, []string{"06:00", "12:00", "21:59"}},
+	}
+	for _, tt := range tests {
+		w, err := parseTimeWindow(tt.start, tt.end)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, c := range tt.in {
+			if !w.contains(at(c)) {
+				t.Errorf("%s-%s: %s outside", tt.start, tt.end, c)
+			}
+		}
+		for _, c := range tt.out {
+			if w.contains(at(c)) {
+				t.Errorf("%s-%s: %s inside", tt.start, tt.end, c)
+			}
+		}
+	}
+
+	if w, err := parseTimeWindow("", ""); err != nil || !w.contains(time.Now()) {
+		t.Fatalf("no window: %v, %v", w, err)
+	}
+	for _, bad := range [][2]string{{"22:00", ""}, {"25:00", "06:00"}, {"06:00", "06:00"}} {
+		if _, err := parseTimeWindow(bad[0], bad[1]); err == nil {
+			t.Errorf("window %s-%s accepted", bad[0], bad[1])
+		}
+	}
+}
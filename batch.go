@@ -0,0 +1,239 @@
+package infrastructure
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	_batchMaxAttempts = 5
+	_batchBackoff     = 500 * time.Millisecond
+	// Size of the batches kept in memory until acknowledged, without spool
+	_batchMaxRetained = int64(4 << 20)
+)
+
+/*
+Post batches of records over HTTP, the part shared by the Loki and OTLP outputs
+
+The output keeps the pending records and encodes them into a body with
+take, JSON unless the output sets another content type. A batch is pushed
+once the output reports it full, after the wait or on Flush, and retried
+with backoff on network errors and the retryable statuses.
+
+Delivery is at least once: a batch is only removed after a 2xx from the
+sink, until then it is kept, on disk with WithSinkSpool and in memory up to
+4MiB otherwise, and sent again on the next push. Every batch carries an
+Idempotency-Key header, the same on each retry and replay, so the collector
+can drop duplicates. A batch the sink rejected is dropped.
+*/
+type batchSink struct {
+	pm *ProjectInfrastructure
+	// Module of the push warnings and prefix of the errors, e.g. "loki push"
+	name    string
+	action  string
+	url     string
+	headers map[string]string
+	codec   Codec
+	// Sent as Content-Type, "application/json" by default
+	contentType string
+	// The codec is part of the content type, no Content-Encoding is sent
+	implicitCodec bool
+	client        *http.Client
+	retryable     func(status int) bool
+	// Take the pending records, encoded as the request body, and their count
+	take func() ([]byte, int, error)
+	// Batches waiting for the acknowledgement of the sink
+	spool *batchSpool
+
+	full   chan struct{}
+	pushMu sync.Mutex
+}
+
+func newBatchSink(_pm *ProjectInfrastructure, _name, _action, _url string, _take func() ([]byte, int, error)) *batchSink {
+	return &batchSink{
+		pm:          _pm,
+		name:        _name,
+		action:      _action,
+		url:         _url,
+		contentType: "application/json",
+		client:      &http.Client{Timeout: 10 * time.Second},
+		take:        _take,
+		spool:       newMemorySpool(_batchMaxRetained),
+		full:        make(chan struct{}, 1),
+		retryable: func(status int) bool {
+			return status == http.StatusTooManyRequests || status >= 500
+		},
+	}
+}
+
+// Keep the batches in the directory of the sink under the spool directory,
+// with the extension of their encoding.
+func (b *batchSink) openSpool(_opts ProjectInfrastructureOptions, _ext string) error {
+	if _opts.SpoolDir == "" {
+		return nil
+	}
+	maxSize := _opts.SpoolMaxSize
+	if maxSize <= 0 {
+		maxSize = _defaultSpoolMaxSize
+	}
+	spool, err := openBatchSpool(filepath.Join(_opts.SpoolDir, b.name), _ext, maxSize)
+	if err != nil {
+		return errors.Wrapf(err, "%s output", b.name)
+	}
+	b.spool = spool
+	return nil
+}
+
+// Push the pending records every wait until resources are released, and
+// register the push as flusher.
+func (b *batchSink) start(_wait time.Duration) {
+	go b.run(b.pm.cancel, _wait)
+	b.pm.RegisterFlusher(b.name, b.push)
+}
+
+// Ask for a push before the wait is over, the batch holds enough records.
+func (b *batchSink) notifyFull() {
+	select {
+	case b.full <- struct{}{}:
+	default:
+	}
+}
+
+func (b *batchSink) run(_ctx context.Context, _wait time.Duration) {
+	ticker := time.NewTicker(_wait)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-b.full:
+		case <-_ctx.Done():
+			return
+		}
+		ctx, cancel := context.WithTimeout(_ctx, b.pm.options.FlushTimeout)
+		if err := b.push(ctx); err != nil {
+			b.pm.logOutput(b.name, "warn", err, false, nil)
+		}
+		cancel()
+	}
+}
+
+// Store the pending records as a batch, then send the stored batches oldest
+// first, stopping at the first one the sink could not take for now.
+func (b *batchSink) push(_ctx context.Context) error {
+	b.pushMu.Lock()
+	defer b.pushMu.Unlock()
+
+	body, count, err := b.take()
+	if count > 0 && err != nil {
+		return errors.Errorf("%s %s dropped %d records: %v", b.name, b.action, count, err)
+	}
+	var dropped int
+	var failures []string
+	if count > 0 {
+		if err := b.spool.add(body, count); err != nil {
+			// Not durable, but the sink may still take it.
+			if _, err := b.deliver(_ctx, body, newUUID()); err != nil {
+				dropped += count
+				failures = append(failures, err.Error())
+			}
+		}
+	}
+	batches, stale := b.spool.batches()
+	batches, evicted := b.spool.evict(batches)
+	dropped += stale + evicted
+	if evicted > 0 {
+		failures = append(failures, "spool full")
+	}
+
+	kept := 0
+	for i, batch := range batches {
+		body, err := b.spool.read(batch)
+		if err == nil {
+			var retry bool
+			if retry, err = b.deliver(_ctx, body, batch.key); err != nil && retry {
+				for _, later := range batches[i:] {
+					kept += later.count
+				}
+				failures = append(failures, err.Error())
+				break
+			}
+		}
+		if err != nil {
+			dropped += batch.count
+			failures = append(failures, err.Error())
+		}
+		b.spool.remove(batch)
+	}
+	switch {
+	case dropped > 0 && kept > 0:
+		return errors.Errorf("%s %s dropped %d records, %d kept in the spool: %s", b.name, b.action, dropped, kept, strings.Join(failures, ", "))
+	case dropped > 0:
+		return errors.Errorf("%s %s dropped %d records: %s", b.name, b.action, dropped, strings.Join(failures, ", "))
+	case kept > 0:
+		return errors.Errorf("%s %s failed, %d records kept in the spool: %s", b.name, b.action, kept, strings.Join(failures, ", "))
+	}
+	return nil
+}
+
+// Compress and send a body, retrying with backoff. Whether the failure is
+// worth another try later is reported with the error.
+func (b *batchSink) deliver(_ctx context.Context, _body []byte, _key string) (bool, error) {
+	body := _body
+	if b.codec != nil {
+		var err error
+		if body, err = b.codec.Compress(_body); err != nil {
+			return false, errors.Wrap(err, b.codec.Name())
+		}
+	}
+
+	backoff := _batchBackoff
+	for attempt := 1; ; attempt++ {
+		retry, err := b.send(_ctx, body, _key)
+		if err == nil {
+			return false, nil
+		}
+		if !retry || attempt == _batchMaxAttempts {
+			return retry, err
+		}
+		select {
+		case <-time.After(backoff):
+			backoff *= 2
+		case <-_ctx.Done():
+			return true, err
+		}
+	}
+}
+
+func (b *batchSink) send(_ctx context.Context, _body []byte, _key string) (bool, error) {
+	if err := b.pm.shipping.wait(_ctx, len(_body)); err != nil {
+		return true, err
+	}
+	req, err := http.NewRequestWithContext(_ctx, http.MethodPost, b.url, bytes.NewReader(_body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", b.contentType)
+	if b.codec != nil && !b.implicitCodec {
+		req.Header.Set("Content-Encoding", b.codec.Name())
+	}
+	for k, v := range b.headers {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("Idempotency-Key", _key)
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		return false, nil
+	}
+	return b.retryable(resp.StatusCode), errors.Errorf("unexpected status %s", resp.Status)
+}
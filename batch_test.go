@@ -0,0 +1,119 @@
+package infrastructure
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBatchSinkPush(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		attempts int32
+		ok       bool
+	}{
+		{"accepted", []int{http.StatusNoContent}, 1, true},
+		{"retried", []int{http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusOK}, 3, true},
+		{"rejected", []int{http.StatusBadRequest}, 1, false},
+	}
+	for _, tt := range tests {
+		var attempts int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			n := atomic.AddInt32(&attempts, 1)
+			w.WriteHeader(tt.statuses[int(n)-1])
+		}))
+		pending := 1
+		b := newBatchSink(&ProjectInfrastructure{}, "test", "push", srv.URL, func() ([]byte, int, error) {
+			count := pending
+			pending = 0
+			return []byte("{}"), count, nil
+		})
+		err := b.push(context.Background())
+		srv.Close()
+
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: err = %v", tt.name, err)
+		}
+		if attempts != tt.attempts {
+			t.Errorf("%s: %d attempts, want %d", tt.name, attempts, tt.attempts)
+		}
+		if err := b.push(context.Background()); err != nil {
+			t.Errorf("%s: empty push: %v", tt.name, err)
+		}
+	}
+}
+
+// A request received by a capture server.
+type capturedRequest struct {
+	path   string
+	header http.Header
+	body   []byte
+}
+
+// Server recording the requests, answering 204.
+func captureServer(_t *testing.T) (*httptest.Server, chan capturedRequest) {
+	requests := make(chan capturedRequest, 16)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- capturedRequest{path: r.URL.Path, header: r.Header, body: body}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	_t.Cleanup(srv.Close)
+	return srv, requests
+}
+
+func nextRequest(_t *testing.T, _requests chan capturedRequest) capturedRequest {
+	_t.Helper()
+	select {
+	case r := <-_requests:
+		return r
+	case <-time.After(5 * time.Second):
+		_t.Fatal("no request received")
+	}
+	return capturedRequest{}
+}
+
+// A field of a protobuf message, varint or length-delimited.
+type protoField struct {
+	num    int
+	varint uint64
+	bytes  []byte
+}
+
+func parseProto(_t *testing.T, _b []byte) []protoField {
+	_t.Helper()
+	var fields []protoField
+	for len(_b) > 0 {
+		key, n := binary.Uvarint(_b)
+		if n <= 0 {
+			_t.Fatalf("invalid protobuf key")
+		}
+		_b = _b[n:]
+		f := protoField{num: int(key >> 3)}
+		switch key & 7 {
+		case 0:
+			f.varint, n = binary.Uvarint(_b)
+			if n <= 0 {
+				_t.Fatalf("invalid protobuf varint")
+			}
+			_b = _b[n:]
+		case 2:
+			size, n := binary.Uvarint(_b)
+			if n <= 0 || uint64(len(_b)-n) < size {
+				_t.Fatalf("invalid protobuf length")
+			}
+			f.bytes = _b[n : n+int(size)]
+			_b = _b[n+int(size):]
+		default:
+			_t.Fatalf("unexpected wire type %d", key&7)
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
@@ -0,0 +1,122 @@
+package infrastructure
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+/*
+Debug output allowed to a module per interval
+
+@Bytes: size of the messages, 0 for no limit
+
+@Entries: number of records, 0 for no limit
+
+@Interval: length of the budget interval, started by the first debug record
+*/
+type ModuleBudget struct {
+	Bytes    int
+	Entries  int
+	Interval time.Duration
+}
+
+// Debug records of a module beyond its budget are dropped until the
+// interval ends, the number of dropped ones is logged then.
+type budgets struct {
+	mu      sync.Mutex
+	budgets map[string]ModuleBudget
+	spent   map[string]*budgetSpent
+	// Set on release, debug records are no longer limited
+	stopped bool
+}
+
+type budgetSpent struct {
+	bytes      int
+	entries    int
+	suppressed int
+	timer      *time.Timer
+}
+
+func newBudgets(_budgets map[string]ModuleBudget) *budgets {
+	b := &budgets{budgets: make(map[string]ModuleBudget), spent: make(map[string]*budgetSpent)}
+	for module, budget := range _budgets {
+		if budget.Interval > 0 && (budget.Bytes > 0 || budget.Entries > 0) {
+			b.budgets[module] = budget
+		}
+	}
+	return b
+}
+
+// Report whether the debug record fits in the budget of its module.
+func (pm *ProjectInfrastructure) withinBudget(_module string, _err error, _print_stack bool) bool {
+	b := pm.budgets
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	budget, ok := b.budgets[_module]
+	if !ok || b.stopped {
+		return true
+	}
+	spent, ok := b.spent[_module]
+	if !ok {
+		spent = &budgetSpent{}
+		b.spent[_module] = spent
+		spent.timer = time.AfterFunc(budget.Interval, func() {
+			b.mu.Lock()
+			if b.spent[_module] != spent {
+				// Ended by stopBudgets.
+				b.mu.Unlock()
+				return
+			}
+			delete(b.spent, _module)
+			n := spent.suppressed
+			b.mu.Unlock()
+
+			pm.reportSuppressedDebug(_module, n, budget.Interval)
+		})
+	}
+	if spent.suppressed > 0 {
+		spent.suppressed++
+		return false
+	}
+
+	size := len(errors.Cause(_err).Error())
+	if _print_stack {
+		size = len(fmt.Sprintf("%+v", _err))
+	}
+	if (budget.Entries > 0 && spent.entries+1 > budget.Entries) || (budget.Bytes > 0 && spent.bytes+size > budget.Bytes) {
+		spent.suppressed++
+		return false
+	}
+	spent.entries++
+	spent.bytes += size
+	return true
+}
+
+// End the intervals on release, logging their counts now instead of from
+// timers firing after the instance is released.
+func (pm *ProjectInfrastructure) stopBudgets() {
+	b := pm.budgets
+	b.mu.Lock()
+	b.stopped = true
+	spent := b.spent
+	b.spent = make(map[string]*budgetSpent)
+	b.mu.Unlock()
+
+	for module, s := range spent {
+		s.timer.Stop()
+		pm.reportSuppressedDebug(module, s.suppressed, b.budgets[module].Interval)
+	}
+}
+
+func (pm *ProjectInfrastructure) reportSuppressedDebug(_module string, _n int, _interval time.Duration) {
+	if _n > 0 {
+		pm.logOutput(_module, "info",
+			errors.Errorf("suppressed %d debug records over the budget of %s", _n, _interval),
+			false, logrus.Fields{"suppressed": _n})
+	}
+}
@@ -0,0 +1,49 @@
+package infrastructure
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestModuleBudget(t *testing.T) {
+	tests := []struct {
+		name    string
+		budget  ModuleBudget
+		written int
+	}{
+		{"entries", ModuleBudget{Entries: 2, Interval: time.Hour}, 2},
+		{"bytes", ModuleBudget{Bytes: 25, Interval: time.Hour}, 2},
+		{"unlimited", ModuleBudget{Interval: time.Hour}, 5},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		pm, err := NewProjectInfrastructure(context.Background(),
+			WithLibraryMode(true),
+			WithLogOutput("writer"),
+			WithLogWriter(&buf),
+			WithModuleBudget("chatty", tt.budget),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 5; i++ {
+			pm.ErrorTransmit("chatty", "debug", errors.New("debug record"), false, false)
+		}
+		if n := strings.Count(buf.String(), "debug record"); n != tt.written {
+			t.Errorf("%s: %d records written, want %d", tt.name, n, tt.written)
+		}
+
+		if err := pm.Shutdown(); err != nil {
+			t.Fatal(err)
+		}
+		suppressed := 5 - tt.written
+		if summary := strings.Contains(buf.String(), "suppressed 3 debug records"); summary != (suppressed == 3) {
+			t.Errorf("%s: summary on release = %v:\n%s", tt.name, summary, buf.String())
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package infrastructure
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// File and line of the first frame outside of this package, the call site
+// of ErrorTransmit or of the package level helpers.
+func callerLocation() (string, bool) {
+	pcs := make([]uintptr, 32)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, infraPkgPath+".") && !strings.HasPrefix(frame.Function, "runtime.") {
+			return fmt.Sprintf("%s:%d", filepath.Base(frame.File), frame.Line), true
+		}
+		if !more {
+			return "", false
+		}
+	}
+}
@@ -0,0 +1,131 @@
+// Ready-made dependency checks for health and startup probing.
+package checks
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"runtime"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Timeout applied when the context passed to a check has no deadline.
+var DefaultTimeout = 5 * time.Second
+
+// A check returns nil when the dependency is healthy.
+type Check func(ctx context.Context) error
+
+func withTimeout(_ctx context.Context) (context.Context, context.CancelFunc) {
+	if _ctx == nil {
+		_ctx = context.Background()
+	}
+	if _, ok := _ctx.Deadline(); ok {
+		return context.WithCancel(_ctx)
+	}
+	return context.WithTimeout(_ctx, DefaultTimeout)
+}
+
+// Succeed when a TCP connection to the address can be established.
+func TCP(_addr string) Check {
+	return func(_ctx context.Context) error {
+		ctx, cancel := withTimeout(_ctx)
+		defer cancel()
+
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", _addr)
+		if err != nil {
+			return errors.Wrapf(err, "tcp check %s", _addr)
+		}
+		return conn.Close()
+	}
+}
+
+// Succeed when a GET request to the url answers with the expected status.
+func HTTP(_url string, _expectStatus int) Check {
+	return func(_ctx context.Context) error {
+		ctx, cancel := withTimeout(_ctx)
+		defer cancel()
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, _url, nil)
+		if err != nil {
+			return errors.Wrapf(err, "http check %s", _url)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return errors.Wrapf(err, "http check %s", _url)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != _expectStatus {
+			return errors.Errorf("http check %s: status %d, expected %d", _url, resp.StatusCode, _expectStatus)
+		}
+		return nil
+	}
+}
+
+// HTTP/2 connection preface followed by an empty SETTINGS frame.
+var http2Preface = append([]byte("PRI * HTTP/2.0\r\n\r\nSM\r\n\r\n"), 0, 0, 0, 0x4, 0, 0, 0, 0, 0)
+
+/*
+Succeed when the address speaks HTTP/2 with prior knowledge, as every gRPC
+server does. The check answers "is a gRPC server listening", it does not call
+the grpc.health.v1 service, which would pull in the gRPC module.
+*/
+func GRPC(_addr string) Check {
+	return func(_ctx context.Context) error {
+		ctx, cancel := withTimeout(_ctx)
+		defer cancel()
+
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", _addr)
+		if err != nil {
+			return errors.Wrapf(err, "grpc check %s", _addr)
+		}
+		defer conn.Close()
+		if deadline, ok := ctx.Deadline(); ok {
+			conn.SetDeadline(deadline)
+		}
+
+		if _, err := conn.Write(http2Preface); err != nil {
+			return errors.Wrapf(err, "grpc check %s", _addr)
+		}
+		// The server must answer with its own SETTINGS frame first.
+		header := make([]byte, 9)
+		if _, err := io.ReadFull(conn, header); err != nil {
+			return errors.Wrapf(err, "grpc check %s", _addr)
+		}
+		if header[3] != 0x4 {
+			return errors.Errorf("grpc check %s: peer did not answer with HTTP/2 settings", _addr)
+		}
+		return nil
+	}
+}
+
+// Succeed while at least minFree bytes are available on the filesystem of path.
+func Disk(_path string, _minFree uint64) Check {
+	return func(_ctx context.Context) error {
+		free, err := diskFree(_path)
+		if err != nil {
+			return errors.Wrapf(err, "disk check %s", _path)
+		}
+		if free < _minFree {
+			return errors.Errorf("disk check %s: %d bytes free, need %d", _path, free, _minFree)
+		}
+		return nil
+	}
+}
+
+// Succeed while the memory obtained from the OS by the Go runtime stays below max bytes.
+func Memory(_max uint64) Check {
+	return func(_ctx context.Context) error {
+		var stats runtime.MemStats
+		runtime.ReadMemStats(&stats)
+		if stats.Sys > _max {
+			return errors.Errorf("memory check: %d bytes in use, limit %d", stats.Sys, _max)
+		}
+		return nil
+	}
+}
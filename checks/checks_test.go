@@ -0,0 +1,127 @@
+package checks
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// Address nothing listens on.
+func closedAddr(_t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		_t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+	return addr
+}
+
+// Server answering each connection with the bytes, after reading the client
+// preface.
+func answeringServer(_t *testing.T, _answer []byte) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		_t.Fatal(err)
+	}
+	_t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.ReadFull(conn, make([]byte, len(http2Preface)))
+				conn.Write(_answer)
+			}()
+		}
+	}()
+	return l.Addr().String()
+}
+
+func TestChecks(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/down" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+	listening := srv.Listener.Addr().String()
+	// An empty SETTINGS frame, as an HTTP/2 server sends first
+	grpcServer := answeringServer(t, []byte{0, 0, 0, 0x4, 0, 0, 0, 0, 0})
+	httpServer := answeringServer(t, []byte("HTTP/1.1 400 Bad Request\r\n\r\n"))
+	// The disk check fails where free space cannot be read
+	_, err := diskFree(t.TempDir())
+	diskSupported := err == nil
+
+	tests := []struct {
+		name  string
+		check Check
+		ok    bool
+	}{
+		{"tcp listening", TCP(listening), true},
+		{"tcp closed", TCP(closedAddr(t)), false},
+		{"http expected status", HTTP(srv.URL+"/", http.StatusOK), true},
+		{"http other status", HTTP(srv.URL+"/down", http.StatusOK), false},
+		{"http closed", HTTP("http://"+closedAddr(t), http.StatusOK), false},
+		{"grpc settings", GRPC(grpcServer), true},
+		{"grpc http/1", GRPC(httpServer), false},
+		{"grpc closed", GRPC(closedAddr(t)), false},
+		{"disk enough", Disk(t.TempDir(), 1), diskSupported},
+		{"disk full", Disk(t.TempDir(), 1<<62), false},
+		{"disk missing", Disk("/nonexistent/path", 1), false},
+		{"memory below", Memory(1 << 62), true},
+		{"memory above", Memory(1), false},
+	}
+	for _, tt := range tests {
+		if err := tt.check(context.Background()); (err == nil) != tt.ok {
+			t.Errorf("%s: err = %v", tt.name, err)
+		}
+	}
+}
+
+func TestCheckDeadline(t *testing.T) {
+	// Reads whatever is sent and never answers
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go io.Copy(io.Discard, conn)
+		}
+	}()
+
+	// The deadline of the context wins over DefaultTimeout
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := GRPC(l.Addr().String())(ctx); err == nil {
+		t.Fatal("silent server passed the grpc check")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("check returned after %s", elapsed)
+	}
+
+	// Without deadline DefaultTimeout applies
+	defer func(d time.Duration) { DefaultTimeout = d }(DefaultTimeout)
+	DefaultTimeout = 100 * time.Millisecond
+	start = time.Now()
+	if err := GRPC(l.Addr().String())(context.Background()); err == nil {
+		t.Fatal("silent server passed the grpc check")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("check returned after %s", elapsed)
+	}
+}
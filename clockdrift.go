@@ -0,0 +1,84 @@
+package infrastructure
+
+import (
+	"encoding/binary"
+	"net"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// Seconds between the NTP epoch (1900) and the Unix epoch (1970).
+const ntpEpochOffset = 2208988800
+
+var _defaultNTPTimeout = 5 * time.Second
+
+func ntpTime(_b []byte) time.Time {
+	sec := binary.BigEndian.Uint32(_b[0:4])
+	frac := binary.BigEndian.Uint32(_b[4:8])
+	nsec := (int64(frac) * 1e9) >> 32
+	return time.Unix(int64(sec)-ntpEpochOffset, nsec)
+}
+
+// Measure the offset of the local clock against the SNTP server, positive
+// when the local clock is behind.
+func clockOffset(_server string) (time.Duration, error) {
+	addr := _server
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "123")
+	}
+	conn, err := net.DialTimeout("udp", addr, _defaultNTPTimeout)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(_defaultNTPTimeout))
+
+	// LI 0, version 3, mode 3 (client).
+	req := make([]byte, 48)
+	req[0] = 0x1b
+	t0 := time.Now()
+	if _, err := conn.Write(req); err != nil {
+		return 0, err
+	}
+	resp := make([]byte, 48)
+	if _, err := conn.Read(resp); err != nil {
+		return 0, err
+	}
+	t3 := time.Now()
+
+	if mode := resp[0] & 0x7; mode != 4 {
+		return 0, errors.Errorf("unexpected NTP mode %d", mode)
+	}
+	if stratum := resp[1]; stratum == 0 {
+		return 0, errors.New("NTP server sent a kiss-of-death or unsynchronized answer")
+	}
+	t1, t2 := ntpTime(resp[32:40]), ntpTime(resp[40:48])
+	return (t1.Sub(t0) + t2.Sub(t3)) / 2, nil
+}
+
+// Check the clock drift every interval and warn once it exceeds the threshold.
+func (pm *ProjectInfrastructure) monitorClockDrift(_server string, _interval, _threshold time.Duration) {
+	go func() {
+		ticker := time.NewTicker(_interval)
+		defer ticker.Stop()
+		for {
+			offset, err := clockOffset(_server)
+			switch {
+			case err != nil:
+				pm.logOutput("clock", "warn", errors.Errorf("clock drift check against %s failed: %v", _server, err), false, nil)
+			case offset > _threshold || -offset > _threshold:
+				pm.logOutput("clock", "warn", errors.Errorf("system clock drifts %s from %s, threshold %s", offset, _server, _threshold), false, logrus.Fields{"offset": offset.String()})
+			default:
+				pm.logOutput("clock", "debug", errors.Errorf("system clock drifts %s from %s", offset, _server), false, logrus.Fields{"offset": offset.String()})
+			}
+
+			select {
+			case <-ticker.C:
+			case <-pm.cancel.Done():
+				return
+			}
+		}
+	}()
+}
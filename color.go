@@ -0,0 +1,62 @@
+package infrastructure
+
+import (
+	"os"
+	"regexp"
+
+	"github.com/pkg/errors"
+)
+
+var supportLogColors = []string{"auto", "always", "never"}
+
+// SGR parameters of a color, e.g. "31" or "97;104".
+var sgrParams = regexp.MustCompile(`^[0-9]+(;[0-9]+)*$`)
+
+// Whether stdout is colored: always, never, or when it is a terminal.
+func colorEnabled(_mode string, _f *os.File) (bool, error) {
+	switch _mode {
+	case "always":
+		return true, nil
+	case "never":
+		return false, nil
+	case "auto", "":
+		fi, err := _f.Stat()
+		return err == nil && fi.Mode()&os.ModeCharDevice != 0, nil
+	}
+	return false, errors.Errorf("invalid log color %s, valid values are %s", _mode, supportLogColors)
+}
+
+// Module colors by severity, the configured SGR parameters over the default.
+func parseColorTheme(_colors map[string]string) (map[string]string, error) {
+	types := logTypes()
+	theme := make(map[string]string, len(types))
+	for _, severity := range types {
+		theme[severity] = green
+	}
+	for severity, params := range _colors {
+		if _, err := parseLogLevel(severity); err != nil {
+			return nil, errors.Errorf("invalid log color severity %s, valid values are %s", severity, types)
+		}
+		if !sgrParams.MatchString(params) {
+			return nil, errors.Errorf("invalid log color %q for %s, expected SGR parameters such as \"97;41\"", params, severity)
+		}
+		theme[severity] = "\x1b[" + params + "m"
+	}
+	return theme, nil
+}
+
+// Unknown severities are logged as errors and colored like them.
+func themeColor(_theme map[string]string, _severity string) string {
+	if color, ok := _theme[_severity]; ok {
+		return color
+	}
+	return _theme["error"]
+}
+
+// Color of the module in text records, empty when stdout is not colored.
+func (pm *ProjectInfrastructure) moduleColor(_severity string) string {
+	if !pm.colored {
+		return ""
+	}
+	return themeColor(pm.colorTheme, _severity)
+}
@@ -0,0 +1,109 @@
+package infrastructure
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// A long running part of the program, e.g. an HTTP server, a consumer or a
+// scheduler, started on registration and stopped when resources are released.
+type Component interface {
+	Name() string
+	// Start must return once the component runs, not when it stops.
+	Start(ctx context.Context) error
+	Stop(ctx context.Context) error
+}
+
+type components struct {
+	mu    sync.Mutex
+	list  []Component
+	names map[string]Component
+}
+
+// Start the component and stop it, in reverse registration order, during
+// ResourceRelease. Names must be unique.
+func (pm *ProjectInfrastructure) RegisterComponent(_c Component) error {
+	if err := pm.addWork("RegisterComponent()"); err != nil {
+		return err
+	}
+	defer pm.WaitGroup.Done()
+
+	pm.components.mu.Lock()
+	defer pm.components.mu.Unlock()
+
+	if _, ok := pm.components.names[_c.Name()]; ok {
+		return errors.Errorf("component %s already registered", _c.Name())
+	}
+	if err := _c.Start(pm.GoroutineCancel); err != nil {
+		return errors.Errorf("start component %s: %v", _c.Name(), err)
+	}
+	if pm.components.names == nil {
+		pm.components.names = make(map[string]Component)
+	}
+	pm.components.names[_c.Name()] = _c
+	pm.components.list = append(pm.components.list, _c)
+	pm.logOutput(_c.Name(), "info", errors.New("component started"), false, nil)
+	return nil
+}
+
+// Stop and start again a single component, e.g. to recover a wedged one.
+func (pm *ProjectInfrastructure) RestartComponent(_name string) error {
+	if err := pm.addWork("RestartComponent()"); err != nil {
+		return err
+	}
+	defer pm.WaitGroup.Done()
+
+	pm.components.mu.Lock()
+	defer pm.components.mu.Unlock()
+
+	c, ok := pm.components.names[_name]
+	if !ok {
+		return errors.Errorf("unknown component %s", _name)
+	}
+	pm.logOutput(_name, "warn", errors.New("restarting component"), false, nil)
+
+	ctx, cancel := pm.shutdownContext()
+	defer cancel()
+	if err := c.Stop(ctx); err != nil {
+		pm.logOutput(_name, "error", errors.Errorf("stop component: %v", err), false, nil)
+		return errors.Errorf("stop component %s: %v", _name, err)
+	}
+	if err := c.Start(pm.GoroutineCancel); err != nil {
+		pm.logOutput(_name, "error", errors.Errorf("start component: %v", err), false, nil)
+		return errors.Errorf("start component %s: %v", _name, err)
+	}
+	pm.logOutput(_name, "info", errors.New("component restarted"), false, nil)
+	return nil
+}
+
+// Names of the registered components in registration order.
+func (pm *ProjectInfrastructure) Components() []string {
+	pm.components.mu.Lock()
+	defer pm.components.mu.Unlock()
+
+	names := make([]string, len(pm.components.list))
+	for i, c := range pm.components.list {
+		names[i] = c.Name()
+	}
+	return names
+}
+
+// Stop the components in reverse registration order within the shutdown
+// timeout, errors are logged.
+func (pm *ProjectInfrastructure) stopComponents() {
+	pm.components.mu.Lock()
+	defer pm.components.mu.Unlock()
+
+	ctx, cancel := pm.shutdownContext()
+	defer cancel()
+	for i := len(pm.components.list) - 1; i >= 0; i-- {
+		c := pm.components.list[i]
+		if err := c.Stop(ctx); err != nil {
+			pm.logOutput(c.Name(), "error", errors.Errorf("stop component: %v", err), false, nil)
+			continue
+		}
+		pm.logOutput(c.Name(), "info", errors.New("component stopped"), false, nil)
+	}
+}
@@ -0,0 +1,122 @@
+package infrastructure
+
+import (
+	"bytes"
+	"compress/gzip"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pkg/errors"
+)
+
+var supportCompressions = []string{"gzip", "zstd", "snappy"}
+
+// Codecs the receivers of the sinks decode. Loki takes gzip and deflate as
+// the Content-Encoding of JSON pushes and snappy as its protobuf push, the
+// OTLP/HTTP receivers take gzip and zstd.
+var (
+	lokiCompressions = []string{"gzip", "deflate", "snappy"}
+	otlpCompressions = []string{"gzip", "zstd"}
+)
+
+// Levels trading CPU for bandwidth, mapped onto the levels of each codec.
+var supportCompressionLevels = []string{"fastest", "default", "best"}
+
+// Compression of the request bodies of batched sinks, the name is sent as
+// the Content-Encoding, e.g. "zstd".
+type Codec interface {
+	Name() string
+	Compress(p []byte) ([]byte, error)
+}
+
+// The codec of a sink, a custom one registered with WithCompressionCodec
+// takes precedence over the built-in one of the same name. nil without name.
+func newCodec(_name, _level string, _custom []Codec) (Codec, error) {
+	if _name == "" {
+		return nil, nil
+	}
+	for _, c := range _custom {
+		if c.Name() == _name {
+			return c, nil
+		}
+	}
+
+	level := -1
+	for i, l := range supportCompressionLevels {
+		if l == _level {
+			level = i
+		}
+	}
+	if _level == "" {
+		level = 1
+	}
+	if level < 0 {
+		return nil, errors.Errorf("invalid compression level %s, valid values are %s", _level, supportCompressionLevels)
+	}
+
+	switch _name {
+	case "gzip":
+		return gzipCodec{level: [...]int{gzip.BestSpeed, gzip.DefaultCompression, gzip.BestCompression}[level]}, nil
+	case "zstd":
+		enc, err := zstd.NewWriter(nil, zstd.WithEncoderLevel(
+			[...]zstd.EncoderLevel{zstd.SpeedFastest, zstd.SpeedDefault, zstd.SpeedBestCompression}[level]))
+		if err != nil {
+			return nil, errors.Wrap(err, "zstd encoder")
+		}
+		return zstdCodec{enc: enc}, nil
+	case "snappy":
+		// Snappy has a single level, it is the fastest of the codecs.
+		return snappyCodec{}, nil
+	}
+	return nil, errors.Errorf("invalid compression %s, valid values are %s", _name, supportCompressions)
+}
+
+// Whether the codec is one of the accepted ones.
+func codecAccepted(_name string, _accepted []string) bool {
+	for _, c := range _accepted {
+		if c == _name {
+			return true
+		}
+	}
+	return false
+}
+
+type gzipCodec struct {
+	level int
+}
+
+func (gzipCodec) Name() string { return "gzip" }
+
+func (c gzipCodec) Compress(_p []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw, err := gzip.NewWriterLevel(&buf, c.level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := zw.Write(_p); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// EncodeAll of the encoder is safe for concurrent use.
+type zstdCodec struct {
+	enc *zstd.Encoder
+}
+
+func (zstdCodec) Name() string { return "zstd" }
+
+func (c zstdCodec) Compress(_p []byte) ([]byte, error) {
+	return c.enc.EncodeAll(_p, nil), nil
+}
+
+type snappyCodec struct{}
+
+func (snappyCodec) Name() string { return "snappy" }
+
+func (snappyCodec) Compress(_p []byte) ([]byte, error) {
+	return snappy.Encode(nil, _p), nil
+}
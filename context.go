@@ -0,0 +1,24 @@
+package infrastructure
+
+import "context"
+
+type infraContextKey struct{}
+
+// Return a copy of the context carrying the instance, see From.
+func (pm *ProjectInfrastructure) Context(_ctx context.Context) context.Context {
+	if _ctx == nil {
+		_ctx = context.Background()
+	}
+	return context.WithValue(_ctx, infraContextKey{}, pm)
+}
+
+// The instance carried by the context, or the default instance set with
+// SetDefault when the context carries none.
+func From(_ctx context.Context) *ProjectInfrastructure {
+	if _ctx != nil {
+		if pm, ok := _ctx.Value(infraContextKey{}).(*ProjectInfrastructure); ok {
+			return pm
+		}
+	}
+	return Default()
+}
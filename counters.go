@@ -0,0 +1,152 @@
+package infrastructure
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// Counters kept across restarts by WithCounterPersistence.
+const (
+	CounterErrors   = "errors_total"
+	CounterUptime   = "uptime_seconds"
+	CounterRestarts = "restarts"
+)
+
+// Storage of the persistent counters, e.g. NewFileCounterStore or a
+// key-value database.
+type CounterStore interface {
+	Load() (map[string]uint64, error)
+	Save(counters map[string]uint64) error
+}
+
+// Counters of the previous runs, the current run is added to them.
+type persistentCounters struct {
+	mu    sync.Mutex
+	store CounterStore
+	base  map[string]uint64
+}
+
+type fileCounterStore struct {
+	path string
+}
+
+// Keep the counters in a JSON file, replaced atomically on each save.
+func NewFileCounterStore(_path string) CounterStore {
+	return &fileCounterStore{path: _path}
+}
+
+// No file yet loads no counters.
+func (s *fileCounterStore) Load() (map[string]uint64, error) {
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var counters map[string]uint64
+	if err := json.Unmarshal(data, &counters); err != nil {
+		return nil, errors.Wrapf(err, "parse counters %s", s.path)
+	}
+	return counters, nil
+}
+
+func (s *fileCounterStore) Save(_counters map[string]uint64) error {
+	data, err := json.MarshalIndent(_counters, "", "  ")
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(s.path), "."+filepath.Base(s.path)+"-*")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), s.path)
+}
+
+// Restore the counters of the previous runs and save them every interval
+// until resources are released.
+func (pm *ProjectInfrastructure) restoreCounters(_store CounterStore, _interval time.Duration) error {
+	base, err := _store.Load()
+	if err != nil {
+		return errors.Wrap(err, "load counters")
+	}
+	if base != nil {
+		base[CounterRestarts]++
+	}
+	counters := map[string]uint64{CounterErrors: 0, CounterUptime: 0, CounterRestarts: 0}
+	for k, v := range base {
+		counters[k] = v
+	}
+	pm.counters = &persistentCounters{store: _store, base: counters}
+
+	if _interval <= 0 {
+		_interval = _defaultCounterSaveInterval
+	}
+	go func() {
+		ticker := time.NewTicker(_interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				pm.saveCounters()
+			case <-pm.cancel.Done():
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+/*
+Counters since the first run, with the current run included
+
+errors_total counts the records emitted at error severity or above,
+uptime_seconds the time all runs were up and restarts the starts after the
+first one. Empty without WithCounterPersistence.
+*/
+func (pm *ProjectInfrastructure) PersistentCounters() map[string]uint64 {
+	if pm.counters == nil {
+		return map[string]uint64{}
+	}
+	pm.counters.mu.Lock()
+	defer pm.counters.mu.Unlock()
+
+	counters := make(map[string]uint64, len(pm.counters.base)+3)
+	for k, v := range pm.counters.base {
+		counters[k] = v
+	}
+	for _, bySeverity := range pm.Stats() {
+		for severity, n := range bySeverity {
+			if severityAtLeast(severity, logrus.ErrorLevel) {
+				counters[CounterErrors] += n
+			}
+		}
+	}
+	counters[CounterUptime] += uint64(time.Since(pm.started).Seconds())
+	return counters
+}
+
+// Save the current totals, failures are logged and retried at the next save.
+func (pm *ProjectInfrastructure) saveCounters() {
+	if pm.counters == nil {
+		return
+	}
+	if err := pm.counters.store.Save(pm.PersistentCounters()); err != nil {
+		pm.logOutput("counters", "warn", errors.Errorf("save counters: %v", err), false, nil)
+	}
+}
@@ -0,0 +1,167 @@
+package infrastructure
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"strings"
+)
+
+// Runtime statistics shown on the dashboard.
+type RuntimeStats struct {
+	Goroutines int    `json:"goroutines"`
+	HeapAlloc  uint64 `json:"heap_alloc"`
+	HeapSys    uint64 `json:"heap_sys"`
+	NumGC      uint32 `json:"num_gc"`
+	PauseTotal uint64 `json:"pause_total_ns"`
+	GoVersion  string `json:"go_version"`
+}
+
+func readRuntimeStats() RuntimeStats {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return RuntimeStats{
+		Goroutines: runtime.NumGoroutine(),
+		HeapAlloc:  m.HeapAlloc,
+		HeapSys:    m.HeapSys,
+		NumGC:      m.NumGC,
+		PauseTotal: m.PauseTotalNs,
+		GoVersion:  runtime.Version(),
+	}
+}
+
+/*
+HTTP handler serving a single-page dashboard, meant to be mounted at
+"/debug/dashboard/" of an admin server with the prefix stripped:
+
+	mux.Handle("/debug/dashboard/", http.StripPrefix("/debug/dashboard", pm.DashboardHandler()))
+
+The page shows the live log tail, the health of the host probes, the
+registered components, the error rate per module and the runtime statistics.
+It polls "state" for a Snapshot with the runtime statistics and follows
+"logs/stream" and "logs/tail", served by LogStreamHandler and TailHandler.
+"health" runs the health checks and returns the HealthReport, with the
+dependency graph and the root causes, whatever the readiness.
+*/
+func (pm *ProjectInfrastructure) DashboardHandler() http.Handler {
+	stream := pm.LogStreamHandler()
+	tail := pm.TailHandler()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch strings.TrimPrefix(r.URL.Path, "/") {
+		case "":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write([]byte(dashboardPage))
+		case "state":
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(struct {
+				InfraSnapshot
+				Runtime RuntimeStats `json:"runtime"`
+			}{pm.Snapshot(), readRuntimeStats()})
+		case "health":
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(pm.CheckHealth(r.Context()))
+		case "logs/stream":
+			stream.ServeHTTP(w, r)
+		case "logs/tail":
+			tail.ServeHTTP(w, r)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
+
+// Relative URLs only, the page works under any mount point.
+const dashboardPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>infrastructure</title>
+<style>
+body { font: 13px monospace; margin: 1em; background: #111; color: #ddd; }
+h2 { font-size: 14px; margin: 1em 0 .3em; color: #8bd; }
+table { border-collapse: collapse; }
+td, th { padding: 2px 10px 2px 0; text-align: left; vertical-align: top; }
+#logs { height: 40vh; overflow-y: auto; white-space: pre-wrap; border: 1px solid #333; padding: 4px; }
+.error, .fatal, .panic, .failed { color: #f66; }
+.warn { color: #fc6; }
+.debug { color: #888; }
+</style>
+</head>
+<body>
+<div id="summary"></div>
+<h2>Logs</h2>
+<div id="logs"></div>
+<h2>Health</h2>
+<table id="health"></table>
+<h2>Error rate per minute</h2>
+<table id="rates"></table>
+<h2>Components</h2>
+<table id="components"></table>
+<h2>Runtime</h2>
+<table id="runtime"></table>
+<script>
+const pollSeconds = 5, maxLines = 500;
+const logs = document.getElementById("logs");
+let lastErrors = null;
+
+function esc(s) {
+	return String(s).replace(/[&<>"]/g, c => ({"&": "&amp;", "<": "&lt;", ">": "&gt;", '"': "&quot;"}[c]));
+}
+
+function rows(id, list) {
+	document.getElementById(id).innerHTML = list.map(r => "<tr>" + r.map(c => "<td>" + c + "</td>").join("") + "</tr>").join("");
+}
+
+function addLog(rec) {
+	const follow = logs.scrollTop + logs.clientHeight >= logs.scrollHeight - 4;
+	const line = document.createElement("div");
+	line.className = rec.severity;
+	line.textContent = rec.time + " " + rec.severity.toUpperCase() + " [" + rec.module + "] " + rec.message +
+		(rec.fields ? " " + JSON.stringify(rec.fields) : "");
+	logs.appendChild(line);
+	while (logs.childNodes.length > maxLines) logs.removeChild(logs.firstChild);
+	if (follow) logs.scrollTop = logs.scrollHeight;
+}
+
+function errorCounts(counters) {
+	const errors = {};
+	for (const [module, counts] of Object.entries(counters || {})) {
+		errors[module] = (counts.error || 0) + (counts.fatal || 0) + (counts.panic || 0);
+	}
+	return errors;
+}
+
+async function poll() {
+	let s;
+	try {
+		s = await (await fetch("state")).json();
+	} catch (e) {
+		document.getElementById("summary").innerHTML = '<span class="failed">unreachable</span>';
+		return;
+	}
+	document.getElementById("summary").textContent =
+		s.state + ", up " + s.uptime + ", log level " + s.log_level + ", exit code " + s.exit_code;
+	rows("health", Object.entries(s.health || {}).map(([name, h]) => [
+		esc(name), h.error ? '<span class="failed">' + esc(h.error) + "</span>" : "ok",
+		esc(JSON.stringify(h.values || {})), esc(h.time)]));
+	const errors = errorCounts(s.counters);
+	rows("rates", Object.entries(errors).map(([module, n]) => [
+		esc(module), n, lastErrors ? ((n - (lastErrors[module] || 0)) * 60 / pollSeconds).toFixed(1) : "-"]));
+	lastErrors = errors;
+	rows("components", (s.components || []).map(c => [esc(c)]));
+	const rt = s.runtime;
+	rows("runtime", [
+		["goroutines", rt.goroutines], ["heap alloc", rt.heap_alloc], ["heap sys", rt.heap_sys],
+		["gc runs", rt.num_gc], ["gc pause ns", rt.pause_total_ns], ["go", esc(rt.go_version)]]);
+}
+
+fetch("logs/tail?n=100").then(r => r.json()).then(records => {
+	records.forEach(addLog);
+	new EventSource("logs/stream").onmessage = e => addLog(JSON.parse(e.data));
+});
+poll();
+setInterval(poll, pollSeconds * 1000);
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,76 @@
+package infrastructure
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestDashboardHandler(t *testing.T) {
+	pm, err := NewProjectInfrastructure(context.Background(),
+		WithLibraryMode(true),
+		WithLogOutput("writer"),
+		WithLogWriter(io.Discard),
+		WithLogRingBuffer(16),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pm.Shutdown()
+	pm.ErrorTransmit("db", "error", errors.New("connection refused"), false, false)
+
+	srv := httptest.NewServer(http.StripPrefix("/debug/dashboard", pm.DashboardHandler()))
+	defer srv.Close()
+
+	tests := []struct {
+		path        string
+		status      int
+		contentType string
+		body        string
+	}{
+		{"/debug/dashboard/", http.StatusOK, "text/html", "EventSource"},
+		{"/debug/dashboard/state", http.StatusOK, "application/json", `"runtime"`},
+		{"/debug/dashboard/logs/tail?n=1", http.StatusOK, "application/json", "connection refused"},
+		{"/debug/dashboard/nope", http.StatusNotFound, "", ""},
+	}
+	for _, tt := range tests {
+		resp, err := http.Get(srv.URL + tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != tt.status {
+			t.Errorf("%s: status %d, want %d", tt.path, resp.StatusCode, tt.status)
+			continue
+		}
+		if !strings.HasPrefix(resp.Header.Get("Content-Type"), tt.contentType) {
+			t.Errorf("%s: content type %s, want %s", tt.path, resp.Header.Get("Content-Type"), tt.contentType)
+		}
+		if !strings.Contains(string(body), tt.body) {
+			t.Errorf("%s: body %s, want %s in it", tt.path, body, tt.body)
+		}
+	}
+
+	resp, err := http.Get(srv.URL + "/debug/dashboard/state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var state struct {
+		Counters map[string]map[string]uint64 `json:"counters"`
+		Runtime  RuntimeStats                 `json:"runtime"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&state); err != nil {
+		t.Fatal(err)
+	}
+	if state.Counters["db"]["error"] != 1 || state.Runtime.Goroutines == 0 {
+		t.Fatalf("state %+v", state)
+	}
+}
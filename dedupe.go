@@ -0,0 +1,91 @@
+package infrastructure
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// Drop identical records within a window started by the first of them, the
+// number of dropped ones is logged when the window closes.
+type deduper struct {
+	mu      sync.Mutex
+	window  time.Duration
+	windows map[dedupeKey]*dedupeWindow
+	// Set on release, records are no longer deduplicated
+	stopped bool
+}
+
+type dedupeKey struct {
+	module   string
+	severity string
+	message  string
+}
+
+type dedupeWindow struct {
+	dropped int
+	timer   *time.Timer
+}
+
+func newDeduper(_window time.Duration) *deduper {
+	return &deduper{window: _window, windows: make(map[dedupeKey]*dedupeWindow)}
+}
+
+// Report whether the record is the first one of its window.
+func (pm *ProjectInfrastructure) dedupe(_module, _severity string, _err error) bool {
+	key := dedupeKey{module: _module, severity: _severity, message: _err.Error()}
+
+	d := pm.deduper
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.stopped {
+		return true
+	}
+	if w, ok := d.windows[key]; ok {
+		w.dropped++
+		return false
+	}
+	w := &dedupeWindow{}
+	d.windows[key] = w
+	w.timer = time.AfterFunc(d.window, func() {
+		d.mu.Lock()
+		if d.windows[key] != w {
+			// Closed by stopDedupe.
+			d.mu.Unlock()
+			return
+		}
+		delete(d.windows, key)
+		n := w.dropped
+		d.mu.Unlock()
+
+		pm.reportRepeated(key, n)
+	})
+	return true
+}
+
+// Close the open windows on release, logging their counts now instead of
+// from timers firing after the instance is released.
+func (pm *ProjectInfrastructure) stopDedupe() {
+	d := pm.deduper
+	d.mu.Lock()
+	d.stopped = true
+	windows := d.windows
+	d.windows = make(map[dedupeKey]*dedupeWindow)
+	d.mu.Unlock()
+
+	for key, w := range windows {
+		w.timer.Stop()
+		pm.reportRepeated(key, w.dropped)
+	}
+}
+
+func (pm *ProjectInfrastructure) reportRepeated(_key dedupeKey, _n int) {
+	if _n > 0 {
+		pm.logOutput(_key.module, _key.severity,
+			errors.Errorf("repeated %d times in %s: %s", _n, pm.deduper.window, _key.message),
+			false, logrus.Fields{"repeated": _n})
+	}
+}
@@ -0,0 +1,46 @@
+package infrastructure
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestDedupeWindow(t *testing.T) {
+	var buf bytes.Buffer
+	pm, err := NewProjectInfrastructure(context.Background(),
+		WithLibraryMode(true),
+		WithLogOutput("writer"),
+		WithLogWriter(&buf),
+		WithDedupeWindow(time.Hour),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 4; i++ {
+		pm.ErrorTransmit("db", "warn", errors.New("connection refused"), false, false)
+	}
+	pm.ErrorTransmit("db", "warn", errors.New("timeout"), false, false)
+	if n := strings.Count(buf.String(), "connection refused"); n != 1 {
+		t.Fatalf("duplicate written %d times, want 1", n)
+	}
+
+	// The summary is written on release, not by a timer afterwards.
+	if err := pm.Shutdown(); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "repeated 3 times") {
+		t.Fatalf("no summary of the repeats:\n%s", buf.String())
+	}
+	pm.deduper.mu.Lock()
+	open := len(pm.deduper.windows)
+	pm.deduper.mu.Unlock()
+	if open != 0 {
+		t.Fatalf("%d windows still open after release", open)
+	}
+}
@@ -0,0 +1,122 @@
+package infrastructure
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Number of frames kept per stack in dev mode.
+const devStackFrames = 4
+
+var devLevelColors = map[logrus.Level]string{
+	logrus.TraceLevel: "\x1b[90m",
+	logrus.DebugLevel: "\x1b[90m",
+	logrus.InfoLevel:  "\x1b[36m",
+	logrus.WarnLevel:  "\x1b[33m",
+	logrus.ErrorLevel: "\x1b[31m",
+	logrus.FatalLevel: "\x1b[1;31m",
+	logrus.PanicLevel: "\x1b[1;31m",
+}
+
+var devLevelNames = map[logrus.Level]string{
+	logrus.TraceLevel: "TRACE",
+	logrus.DebugLevel: "DEBUG",
+	logrus.InfoLevel:  "INFO",
+	logrus.WarnLevel:  "WARN",
+	logrus.ErrorLevel: "ERROR",
+	logrus.FatalLevel: "FATAL",
+	logrus.PanicLevel: "PANIC",
+}
+
+// Name of the level column, the registered severity an entry carries or
+// that of its level.
+func devLevelName(_entry *logrus.Entry) string {
+	if s, ok := _entry.Data["severity"].(string); ok && s != "" {
+		return strings.ToUpper(s)
+	}
+	if name, ok := devLevelNames[_entry.Level]; ok {
+		return name
+	}
+	return strings.ToUpper(_entry.Level.String())
+}
+
+// Human-friendly multiline formatter for local development.
+type devFormatter struct {
+	start   time.Time
+	colored bool
+}
+
+func (f *devFormatter) Format(_entry *logrus.Entry) ([]byte, error) {
+	b := &bytes.Buffer{}
+
+	module, _ := _entry.Data["module"].(string)
+	lines := strings.Split(strings.TrimRight(_entry.Message, "\n"), "\n")
+
+	color, end := devLevelColors[_entry.Level], reset
+	if !f.colored {
+		color, end = "", ""
+	}
+	fmt.Fprintf(b, "+%9.3fs %s%-5s%s %-12s %s\n",
+		_entry.Time.Sub(f.start).Seconds(),
+		color,
+		devLevelName(_entry),
+		end,
+		module,
+		lines[0],
+	)
+	for _, line := range lines[1:] {
+		fmt.Fprintf(b, "%31s%s\n", "", line)
+	}
+
+	keys := make([]string, 0, len(_entry.Data))
+	for k := range _entry.Data {
+		if k != "module" && k != "severity" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(b, "%31s%-12s = %v\n", "", k, _entry.Data[k])
+	}
+	return b.Bytes(), nil
+}
+
+// Shorten a "%+v" error chain: runtime frames are dropped, file paths are
+// reduced to their base name and each stack keeps only a few frames.
+func abbreviateStack(_stack string) string {
+	var out []string
+	frames := 0
+
+	lines := strings.Split(_stack, "\n")
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		isFrame := i+1 < len(lines) && strings.HasPrefix(lines[i+1], "\t")
+		if !isFrame {
+			// An error message starts a new stack.
+			frames = 0
+			out = append(out, line)
+			continue
+		}
+
+		location := strings.TrimPrefix(lines[i+1], "\t")
+		i++
+		if strings.HasPrefix(line, "runtime.") {
+			continue
+		}
+		frames++
+		if frames > devStackFrames {
+			if frames == devStackFrames+1 {
+				out = append(out, "  ...")
+			}
+			continue
+		}
+		out = append(out, fmt.Sprintf("  %s (%s)", filepath.Base(line), filepath.Base(location)))
+	}
+	return strings.Join(out, "\n")
+}
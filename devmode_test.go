@@ -0,0 +1,49 @@
+package infrastructure
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestDevFormatterLevel(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	f := &devFormatter{start: start}
+	tests := []struct {
+		level logrus.Level
+		data  logrus.Fields
+		want  string
+	}{
+		{logrus.InfoLevel, logrus.Fields{"module": "api"}, "INFO "},
+		{logrus.FatalLevel, logrus.Fields{"module": "api"}, "FATAL"},
+		{logrus.PanicLevel, logrus.Fields{"module": "api"}, "PANIC"},
+		{logrus.ErrorLevel, logrus.Fields{"module": "api", "severity": "critical"}, "CRITICAL"},
+	}
+	for _, tt := range tests {
+		entry := &logrus.Entry{Level: tt.level, Time: start.Add(1500 * time.Millisecond), Message: "boom", Data: tt.data}
+		b, err := f.Format(entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+		line := string(b)
+		if !strings.HasPrefix(line, "+    1.500s "+tt.want) {
+			t.Errorf("level %s formatted as %q, want the level column %q", tt.level, line, tt.want)
+		}
+		if strings.Contains(line, "severity") {
+			t.Errorf("severity repeated as a field: %q", line)
+		}
+	}
+}
+
+func TestAbbreviateStackKeepsMessages(t *testing.T) {
+	stack := "boom\nmain.main\n\t/home/dev/project/main.go:12\nruntime.main\n\t/usr/local/go/src/runtime/proc.go:250"
+	got := abbreviateStack(stack)
+	if !strings.Contains(got, "boom") || !strings.Contains(got, "main.go:12") {
+		t.Errorf("abbreviateStack lost the message or frame: %q", got)
+	}
+	if strings.Contains(got, "/home/dev") || strings.Contains(got, "runtime.main") {
+		t.Errorf("abbreviateStack kept a full path or runtime frame: %q", got)
+	}
+}
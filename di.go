@@ -0,0 +1,78 @@
+package infrastructure
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+/*
+Constructor for google/wire graphs: the cleanup function releases resources
+
+	wire.Build(infrastructure.NewWithCleanup, ...)
+*/
+func NewWithCleanup(_ctx context.Context, _optionFuncs ...OptionFunc) (*ProjectInfrastructure, func(), error) {
+	pm, err := NewProjectInfrastructure(_ctx, _optionFuncs...)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pm, pm.ResourceRelease, nil
+}
+
+/*
+OnStop hook for uber/fx lifecycles, releasing resources when the app stops
+
+	lc.Append(fx.Hook{OnStop: pm.LifecycleStop})
+*/
+func (pm *ProjectInfrastructure) LifecycleStop(_ctx context.Context) error {
+	done := make(chan error, 1)
+	go func() { done <- pm.Shutdown() }()
+	select {
+	case err := <-done:
+		return err
+	case <-_ctx.Done():
+		return errors.Errorf("shutdown: %v", _ctx.Err())
+	}
+}
+
+/*
+OnStart and OnStop hooks of a component for uber/fx lifecycles
+
+The component is registered when the app starts and stopped and removed when
+it stops, ResourceRelease does not stop it again.
+
+	onStart, onStop := pm.ComponentHooks(server)
+	lc.Append(fx.Hook{OnStart: onStart, OnStop: onStop})
+*/
+func (pm *ProjectInfrastructure) ComponentHooks(_c Component) (func(context.Context) error, func(context.Context) error) {
+	onStart := func(context.Context) error {
+		return pm.RegisterComponent(_c)
+	}
+	onStop := func(ctx context.Context) error {
+		return pm.removeComponent(ctx, _c.Name())
+	}
+	return onStart, onStop
+}
+
+// Stop a component and remove it from the registry.
+func (pm *ProjectInfrastructure) removeComponent(_ctx context.Context, _name string) error {
+	pm.components.mu.Lock()
+	defer pm.components.mu.Unlock()
+
+	c, ok := pm.components.names[_name]
+	if !ok {
+		return nil
+	}
+	delete(pm.components.names, _name)
+	for i, other := range pm.components.list {
+		if other.Name() == _name {
+			pm.components.list = append(pm.components.list[:i], pm.components.list[i+1:]...)
+			break
+		}
+	}
+	if err := c.Stop(_ctx); err != nil {
+		return errors.Errorf("stop component %s: %v", _name, err)
+	}
+	pm.logOutput(_name, "info", errors.New("component stopped"), false, nil)
+	return nil
+}
@@ -0,0 +1,110 @@
+package infrastructure
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	_defaultDialTimeout    = 10 * time.Second
+	_defaultDialLogBackoff = time.Second
+	_maxDialLogBackoff     = time.Minute
+)
+
+/*
+Outbound dialer bound to the infrastructure lifecycle
+
+Every dial resolves the host again, so a reconnect follows DNS changes.
+Failures are logged with the dialer name as module, backing off from 1s
+to 1m between log lines while the target stays down, and dials are
+cancelled once project goroutines are asked to stop.
+*/
+type Dialer struct {
+	pm     *ProjectInfrastructure
+	name   string
+	dialer net.Dialer
+
+	mu       sync.Mutex
+	failures uint
+	nextLog  time.Time
+	backoff  time.Duration
+}
+
+func (pm *ProjectInfrastructure) Dialer(_name string) *Dialer {
+	return &Dialer{
+		pm:      pm,
+		name:    _name,
+		dialer:  net.Dialer{Timeout: _defaultDialTimeout, KeepAlive: 30 * time.Second},
+		backoff: _defaultDialLogBackoff,
+	}
+}
+
+func (d *Dialer) DialContext(_ctx context.Context, _network, _addr string) (net.Conn, error) {
+	ctx, cancel := context.WithCancel(_ctx)
+	defer cancel()
+	stop := context.AfterFunc(d.pm.GoroutineCancel, cancel)
+	defer stop()
+
+	conn, err := d.dialer.DialContext(ctx, _network, _addr)
+	d.report(_addr, err)
+	return conn, err
+}
+
+// Log a failure at most once per backoff interval, and the recovery.
+func (d *Dialer) report(_addr string, _err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now()
+	if _err == nil {
+		if d.failures > 0 {
+			d.pm.logOutput(d.name, "info", errors.Errorf("connected to %s after %d failed attempts", _addr, d.failures), false, nil)
+		}
+		d.failures, d.nextLog, d.backoff = 0, time.Time{}, _defaultDialLogBackoff
+		return
+	}
+
+	d.failures++
+	if now.Before(d.nextLog) {
+		return
+	}
+	d.pm.logOutput(d.name, "warn", errors.Errorf("dial %s: %v", _addr, _err), false, logrus.Fields{"failures": d.failures})
+	d.nextLog = now.Add(d.backoff)
+	if d.backoff *= 2; d.backoff > _maxDialLogBackoff {
+		d.backoff = _maxDialLogBackoff
+	}
+}
+
+// HTTP transport using the dialer. Idle connections are closed every
+// reresolve interval so pooled connections are re-dialed, and re-resolved,
+// instead of sticking to a stale address.
+func (d *Dialer) Transport(_reresolve time.Duration) *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.DialContext = d.DialContext
+
+	if _reresolve > 0 {
+		err := d.pm.Go(func(ctx context.Context) {
+			ticker := time.NewTicker(_reresolve)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					t.CloseIdleConnections()
+				case <-ctx.Done():
+					t.CloseIdleConnections()
+					return
+				}
+			}
+		})
+		if err != nil {
+			d.pm.logOutput(d.name, "warn", err, false, nil)
+		}
+	}
+	return t
+}
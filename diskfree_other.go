@@ -0,0 +1,13 @@
+//go:build !linux && !darwin && !freebsd
+
+package infrastructure
+
+import (
+	"runtime"
+
+	"github.com/pkg/errors"
+)
+
+func diskFree(_path string) (uint64, error) {
+	return 0, errors.Errorf("disk guard is not supported on %s", runtime.GOOS)
+}
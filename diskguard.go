@@ -0,0 +1,285 @@
+package infrastructure
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// Severities dropped at each disk pressure level, the lowest kept first. Below
+// the minimum only warnings and errors are written, below half of it errors.
+var diskPressureLevels = []logrus.Level{logrus.TraceLevel, logrus.WarnLevel, logrus.ErrorLevel}
+
+// Check the free space of the log directory and drop the records of low
+// severities while it runs short.
+type diskGuard struct {
+	dir      string
+	minFree  uint64
+	pressure int32
+	dropped  uint64
+}
+
+// Directory of the log files, the working directory for a strftime pattern
+// whose directories only exist after rotation.
+func logDir(_opts ProjectInfrastructureOptions) string {
+	dir := filepath.Dir(_opts.LogPath)
+	if _opts.LogRotationPattern != "" && !strings.Contains(filepath.Dir(_opts.LogRotationPattern), "%") {
+		dir = filepath.Dir(_opts.LogRotationPattern)
+	}
+	return dir
+}
+
+// Report whether a record of the severity is written at the current pressure.
+func (g *diskGuard) allow(_severity string) bool {
+	p := atomic.LoadInt32(&g.pressure)
+	if p == 0 {
+		return true
+	}
+	level, err := parseLogLevel(_severity)
+	if err == nil && level > diskPressureLevels[p] {
+		atomic.AddUint64(&g.dropped, 1)
+		return false
+	}
+	return true
+}
+
+func (g *diskGuard) level(_free uint64) int32 {
+	switch {
+	case _free < g.minFree/2:
+		return 2
+	case _free < g.minFree:
+		return 1
+	}
+	return 0
+}
+
+// Check the free space every interval and log when the pressure changes.
+func (pm *ProjectInfrastructure) guardDisk(_interval time.Duration) {
+	g := pm.diskGuard
+	check := func() {
+		free, err := diskFree(g.dir)
+		if err != nil {
+			pm.internalError(errors.Errorf("disk guard %s: %v", g.dir, err))
+			return
+		}
+		level := g.level(free)
+		prev := atomic.SwapInt32(&g.pressure, level)
+		switch {
+		case level > prev:
+			// Logged at the lowest severity still written.
+			pm.logOutput("disk", logLevelName(diskPressureLevels[level]),
+				errors.Errorf("disk pressure on %s, %d bytes free of the %d required, dropping records below %s", g.dir, free, g.minFree, logLevelName(diskPressureLevels[level])),
+				false, logrus.Fields{"free": free})
+		case level == 0 && prev > 0:
+			n := atomic.SwapUint64(&g.dropped, 0)
+			pm.logOutput("disk", "info",
+				errors.Errorf("disk pressure on %s resolved, dropped %d records", g.dir, n),
+				false, logrus.Fields{"free": free, "dropped": n})
+		}
+	}
+
+	if _interval <= 0 {
+		_interval = _defaultDiskGuardInterval
+	}
+	check()
+	go func() {
+		ticker := time.NewTicker(_interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				check()
+			case <-pm.cancel.Done():
+				return
+			}
+		}
+	}()
+}
+
+const (
+	_emergencyMagic    = "INFRAEMR"
+	_emergencyHeader   = 16
+	_emergencySlotSize = 1024
+)
+
+/*
+Last error records in a preallocated file, so they survive a full disk
+
+The file holds a header, the magic, the slot count and the next slot, and
+one fixed size slot per record: its length and the deflated JSON LogRecord.
+Writing a record only overwrites allocated blocks.
+*/
+type emergencyLog struct {
+	mu    sync.Mutex
+	f     *os.File
+	slots uint32
+	next  uint32
+}
+
+// Open the emergency file, keeping the records of a previous run when it
+// has the same number of slots.
+func openEmergencyLog(_path string, _entries int) (*emergencyLog, error) {
+	f, err := os.OpenFile(_path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, errors.Wrap(err, "emergency log")
+	}
+	if _entries <= 0 {
+		_entries = _defaultEmergencyLogEntries
+	}
+	e := &emergencyLog{f: f, slots: uint32(_entries)}
+
+	header := make([]byte, _emergencyHeader)
+	if _, err := io.ReadFull(f, header); err == nil && string(header[:8]) == _emergencyMagic && binary.BigEndian.Uint32(header[8:]) == e.slots {
+		e.next = binary.BigEndian.Uint32(header[12:]) % e.slots
+		return e, nil
+	}
+
+	// Write the whole file, truncating or extending it only reserves holes.
+	size := _emergencyHeader + int64(e.slots)*_emergencySlotSize
+	if _, err := f.WriteAt(make([]byte, size), 0); err != nil {
+		f.Close()
+		return nil, errors.Wrap(err, "emergency log preallocation")
+	}
+	if err := f.Truncate(size); err == nil {
+		err = e.writeHeader()
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if err != nil {
+		f.Close()
+		return nil, errors.Wrap(err, "emergency log")
+	}
+	return e, nil
+}
+
+func (e *emergencyLog) writeHeader() error {
+	header := make([]byte, _emergencyHeader)
+	copy(header, _emergencyMagic)
+	binary.BigEndian.PutUint32(header[8:], e.slots)
+	binary.BigEndian.PutUint32(header[12:], e.next)
+	_, err := e.f.WriteAt(header, 0)
+	return err
+}
+
+// Deflate the record into a slot, shortening the message, then dropping the
+// fields and shortening the module and severity until it fits.
+func encodeEmergencyRecord(_record LogRecord) ([]byte, error) {
+	for {
+		line, err := json.Marshal(_record)
+		if err != nil {
+			return nil, err
+		}
+		var buf bytes.Buffer
+		w, _ := flate.NewWriter(&buf, flate.BestCompression)
+		w.Write(line)
+		w.Close()
+		if buf.Len() <= _emergencySlotSize-2 {
+			return buf.Bytes(), nil
+		}
+		switch {
+		case _record.Message != "":
+			_record.Message = halveText(_record.Message)
+		case _record.Fields != nil:
+			_record.Fields = nil
+		case _record.Module != "":
+			_record.Module = halveText(_record.Module)
+		case _record.Severity != "":
+			_record.Severity = halveText(_record.Severity)
+		default:
+			return nil, errors.New("record does not fit an emergency log slot")
+		}
+	}
+}
+
+// The first half of the text, cut on a rune boundary.
+func halveText(_s string) string {
+	n := len(_s) / 2
+	for n > 0 && !utf8.RuneStart(_s[n]) {
+		n--
+	}
+	return _s[:n]
+}
+
+func (e *emergencyLog) add(_record LogRecord) error {
+	data, err := encodeEmergencyRecord(_record)
+	if err != nil {
+		return err
+	}
+	slot := make([]byte, _emergencySlotSize)
+	binary.BigEndian.PutUint16(slot, uint16(len(data)))
+	copy(slot[2:], data)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.f == nil {
+		return nil
+	}
+	if _, err := e.f.WriteAt(slot, _emergencyHeader+int64(e.next)*_emergencySlotSize); err != nil {
+		return err
+	}
+	e.next = (e.next + 1) % e.slots
+	return e.writeHeader()
+}
+
+// Sync and close the file, the records logged afterwards are not kept.
+func (e *emergencyLog) close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.f == nil {
+		return nil
+	}
+	err := e.f.Sync()
+	if cerr := e.f.Close(); err == nil {
+		err = cerr
+	}
+	e.f = nil
+	return err
+}
+
+// The records of an emergency file, oldest first, see WithEmergencyLog.
+func ReadEmergencyLog(_path string) ([]LogRecord, error) {
+	b, err := os.ReadFile(_path)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) < _emergencyHeader || string(b[:8]) != _emergencyMagic {
+		return nil, errors.Errorf("%s is not an emergency log", _path)
+	}
+	slots := binary.BigEndian.Uint32(b[8:])
+	next := binary.BigEndian.Uint32(b[12:])
+	if slots == 0 || int64(len(b)) < _emergencyHeader+int64(slots)*_emergencySlotSize {
+		return nil, errors.Errorf("emergency log %s is truncated", _path)
+	}
+
+	var records []LogRecord
+	for i := uint32(0); i < slots; i++ {
+		off := _emergencyHeader + int64((next+i)%slots)*_emergencySlotSize
+		slot := b[off : off+_emergencySlotSize]
+		n := int(binary.BigEndian.Uint16(slot))
+		if n == 0 || n > _emergencySlotSize-2 {
+			continue
+		}
+		line, err := io.ReadAll(flate.NewReader(bytes.NewReader(slot[2 : 2+n])))
+		if err != nil {
+			continue
+		}
+		var record LogRecord
+		if json.Unmarshal(line, &record) == nil {
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
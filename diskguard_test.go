@@ -0,0 +1,129 @@
+package infrastructure
+
+import (
+	"context"
+	"io"
+	"math/rand"
+	"path/filepath"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+// Text that deflate cannot shrink much.
+func noisyText(_n int) string {
+	rng := rand.New(rand.NewSource(1))
+	const alphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789é世"
+	runes := []rune(alphabet)
+	b := make([]rune, _n)
+	for i := range b {
+		b[i] = runes[rng.Intn(len(runes))]
+	}
+	return string(b)
+}
+
+func TestEncodeEmergencyRecord(t *testing.T) {
+	tests := []struct {
+		name   string
+		record LogRecord
+	}{
+		{"small", LogRecord{Module: "db", Severity: "error", Message: "connection lost"}},
+		{"long message", LogRecord{Module: "db", Severity: "error", Message: noisyText(5000)}},
+		{"long fields", LogRecord{Module: "db", Severity: "error", Fields: map[string]interface{}{"body": noisyText(5000)}}},
+		{"long module", LogRecord{Module: noisyText(5000), Severity: "error", Message: "x"}},
+		{"long severity", LogRecord{Module: "db", Severity: noisyText(5000)}},
+	}
+	for _, tt := range tests {
+		done := make(chan struct{})
+		var data []byte
+		var err error
+		go func() {
+			data, err = encodeEmergencyRecord(tt.record)
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s: encoding does not terminate", tt.name)
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if len(data) > _emergencySlotSize-2 {
+			t.Errorf("%s: %d bytes do not fit a slot", tt.name, len(data))
+		}
+	}
+}
+
+func TestHalveText(t *testing.T) {
+	tests := []string{"", "a", "abcd", "éééé", "世世世", "a世b"}
+	for _, s := range tests {
+		got := halveText(s)
+		if !utf8.ValidString(got) {
+			t.Errorf("halveText(%q) = %q splits a rune", s, got)
+		}
+		if len(s) > 0 && len(got) >= len(s) {
+			t.Errorf("halveText(%q) = %q does not shorten", s, got)
+		}
+	}
+}
+
+func TestEmergencyLogWrapsAround(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "emergency.log")
+	e, err := openEmergencyLog(path, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer e.f.Close()
+	for _, msg := range []string{"one", "two", "three", "four"} {
+		if err := e.add(LogRecord{Module: "m", Severity: "error", Message: msg}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	records, err := ReadEmergencyLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"two", "three", "four"}
+	if len(records) != len(want) {
+		t.Fatalf("got %d records, want %d", len(records), len(want))
+	}
+	for i, r := range records {
+		if r.Message != want[i] {
+			t.Errorf("record %d = %q, want %q", i, r.Message, want[i])
+		}
+	}
+}
+
+func TestEmergencyLogClosedOnShutdown(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "emergency.log")
+	pm, err := NewProjectInfrastructure(context.Background(),
+		WithLibraryMode(true),
+		WithLogOutput("writer"),
+		WithLogWriter(io.Discard),
+		WithEmergencyLog(path, 4),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pm.ErrorTransmit("db", "error", errors.New("connection lost"), false, false)
+	pm.Shutdown()
+
+	if pm.emergency.f != nil {
+		t.Error("emergency log still open after shutdown")
+	}
+	if err := pm.emergency.add(LogRecord{Module: "db", Severity: "error", Message: "late"}); err != nil {
+		t.Errorf("record after close: %v", err)
+	}
+	records, err := ReadEmergencyLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].Message != "connection lost" {
+		t.Errorf("records %+v, want the one logged before shutdown", records)
+	}
+}
@@ -0,0 +1,88 @@
+package infrastructure
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+type configState struct {
+	Hash   string            `json:"hash"`
+	Config map[string]string `json:"config"`
+}
+
+// Flatten the options into printable values keyed by option key. Only
+// options that can be layered are kept, since writers, stores and hooks
+// print as addresses that change every run, and secrets are masked.
+func effectiveConfig(_opts ProjectInfrastructureOptions) map[string]string {
+	config := make(map[string]string)
+	for key, value := range snapshotOptions(_opts) {
+		config[key] = fmt.Sprintf("%v", value)
+	}
+	return config
+}
+
+func configHash(_config map[string]string) string {
+	// encoding/json sorts map keys, so the hash is stable.
+	data, _ := json.Marshal(_config)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Compare the effective configuration with the one persisted by the
+// previous run, log the differences and persist the current one.
+func (pm *ProjectInfrastructure) detectConfigDrift(_opts ProjectInfrastructureOptions) error {
+	current := configState{Config: effectiveConfig(_opts)}
+	current.Hash = configHash(current.Config)
+
+	var previous configState
+	data, err := os.ReadFile(_opts.ConfigStatePath)
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(data, &previous); err != nil {
+			return errors.Wrapf(err, "parse config state %s", _opts.ConfigStatePath)
+		}
+	case !os.IsNotExist(err):
+		return errors.Wrap(err, "read config state")
+	}
+
+	if previous.Hash != "" && previous.Hash != current.Hash {
+		pm.logOutput("config", "warn", errors.New("config changed since last run"), false, logrus.Fields{
+			"previous_hash": previous.Hash,
+			"current_hash":  current.Hash,
+			"changed":       diffConfig(previous.Config, current.Config),
+		})
+	}
+
+	data, err = json.MarshalIndent(current, "", "  ")
+	if err != nil {
+		return err
+	}
+	return errors.Wrap(os.WriteFile(_opts.ConfigStatePath, data, 0600), "write config state")
+}
+
+// List "key: old -> new" for every value that differs.
+func diffConfig(_old, _new map[string]string) []string {
+	keys := make(map[string]struct{})
+	for k := range _old {
+		keys[k] = struct{}{}
+	}
+	for k := range _new {
+		keys[k] = struct{}{}
+	}
+
+	var diff []string
+	for k := range keys {
+		if _old[k] != _new[k] {
+			diff = append(diff, fmt.Sprintf("%s: %q -> %q", k, _old[k], _new[k]))
+		}
+	}
+	sort.Strings(diff)
+	return diff
+}
@@ -0,0 +1,50 @@
+package infrastructure
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEffectiveConfigStable(t *testing.T) {
+	a, b := DefaultOptions(), DefaultOptions()
+	a.LogWriter, b.LogWriter = &bytes.Buffer{}, &bytes.Buffer{}
+	a.AuditWriter, b.AuditWriter = &bytes.Buffer{}, &bytes.Buffer{}
+	if configHash(effectiveConfig(a)) != configHash(effectiveConfig(b)) {
+		t.Fatal("hash depends on non-layerable options")
+	}
+
+	b.LogLevel = "error"
+	if configHash(effectiveConfig(a)) == configHash(effectiveConfig(b)) {
+		t.Fatal("hash ignores a changed option")
+	}
+}
+
+func TestEffectiveConfigMasksSecrets(t *testing.T) {
+	opts := DefaultOptions()
+	opts.SentryDSN = "https://secret@sentry.example/1"
+	opts.OTLPHeaders = map[string]string{"Authorization": "Bearer secret"}
+	for key, value := range effectiveConfig(opts) {
+		if strings.Contains(value, "secret") {
+			t.Errorf("%s leaks a secret: %s", key, value)
+		}
+	}
+}
+
+func TestDetectConfigDriftStateFileMode(t *testing.T) {
+	opts := DefaultOptions()
+	opts.ConfigStatePath = filepath.Join(t.TempDir(), "state.json")
+	pm := &ProjectInfrastructure{}
+	if err := pm.detectConfigDrift(opts); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(opts.ConfigStatePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Fatalf("state file mode = %v, want 0600", info.Mode().Perm())
+	}
+}
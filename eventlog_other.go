@@ -0,0 +1,24 @@
+//go:build !windows
+
+package infrastructure
+
+import (
+	"runtime"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+type eventLogWriter struct{}
+
+func openEventLog(_opts ProjectInfrastructureOptions) (*eventLogWriter, error) {
+	return nil, errors.Errorf("eventlog output is not supported on %s", runtime.GOOS)
+}
+
+func (e *eventLogWriter) Write(_p []byte) (int, error) {
+	return 0, errors.New("eventlog output is not supported")
+}
+
+func (e *eventLogWriter) WriteLevel(_level logrus.Level, _p []byte) error {
+	return errors.New("eventlog output is not supported")
+}
@@ -0,0 +1,57 @@
+//go:build windows
+
+package infrastructure
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/windows/svc/eventlog"
+)
+
+// Event ID of every record, the message carries the details.
+const _eventLogEventID = 1
+
+// Write records to the Windows Event Log with the event type matching their
+// severity.
+type eventLogWriter struct {
+	log *eventlog.Log
+}
+
+func openEventLog(_opts ProjectInfrastructureOptions) (*eventLogWriter, error) {
+	source := _opts.EventLogSource
+	if source == "" {
+		source = strings.TrimSuffix(filepath.Base(os.Args[0]), ".exe")
+	}
+	// Registering the source needs administrator rights and is done once,
+	// usually by the installer. Without it the events are still written.
+	eventlog.InstallAsEventCreate(source, eventlog.Error|eventlog.Warning|eventlog.Info)
+
+	l, err := eventlog.Open(source)
+	if err != nil {
+		return nil, errors.Wrap(err, "open event log")
+	}
+	return &eventLogWriter{log: l}, nil
+}
+
+func (e *eventLogWriter) Write(_p []byte) (int, error) {
+	if err := e.WriteLevel(logrus.InfoLevel, _p); err != nil {
+		return 0, err
+	}
+	return len(_p), nil
+}
+
+func (e *eventLogWriter) WriteLevel(_level logrus.Level, _p []byte) error {
+	msg := strings.TrimRight(string(_p), "\n")
+	switch _level {
+	case logrus.PanicLevel, logrus.FatalLevel, logrus.ErrorLevel:
+		return e.log.Error(_eventLogEventID, msg)
+	case logrus.WarnLevel:
+		return e.log.Warning(_eventLogEventID, msg)
+	default:
+		return e.log.Info(_eventLogEventID, msg)
+	}
+}
@@ -0,0 +1,374 @@
+package infrastructure
+
+import (
+	"bufio"
+	"container/heap"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Pattern matching the current and rotated log files.
+func logFileGlob(_opts ProjectInfrastructureOptions) string {
+	pattern := _opts.LogPath
+	if _opts.LogRotationPattern != "" {
+		pattern = _opts.LogRotationPattern
+	}
+	return strftimeVerb.ReplaceAllString(pattern, "*") + "*"
+}
+
+// Globs of the main log files and of the files split by severity.
+func logFileGlobs(_opts ProjectInfrastructureOptions) []string {
+	globs := []string{logFileGlob(_opts)}
+	for severity := range _opts.SeverityRetention {
+		opts := _opts
+		opts.LogPath = severityLogPath(opts.LogPath, severity)
+		if opts.LogRotationPattern != "" {
+			opts.LogRotationPattern = severityLogPath(opts.LogRotationPattern, severity)
+		}
+		globs = append(globs, logFileGlob(opts))
+	}
+	return globs
+}
+
+/*
+Write the records logged between from and to, from the current and rotated
+log files and the ring buffer
+
+Records of all files, including the files split by severity, are merged in
+time order and written in the log format as they are read, each file being
+in time order already. Lines without a timestamp, such as warnings of the
+infrastructure itself, follow the record before them. The ring buffer of
+WithLogRingBuffer holds every record since its oldest one, it takes over
+from the files at that time. Needs the file output or the ring buffer.
+*/
+func (pm *ProjectInfrastructure) ExportLogs(_from, _to time.Time, _w io.Writer) error {
+	hasFile := pm.hasLogOutput("file")
+	if !hasFile && pm.ring == nil {
+		return errors.New("log export needs the file output or the ring buffer")
+	}
+
+	var sources []exportSource
+	var cutoff time.Time
+	if pm.ring != nil {
+		ring, err := pm.ringExportSource(_from, _to)
+		if err != nil {
+			return err
+		}
+		if ring != nil {
+			cutoff = ring.first
+			sources = append(sources, ring)
+		}
+	}
+	if hasFile {
+		files, err := pm.exportFiles(_from)
+		if err != nil {
+			return err
+		}
+		for _, path := range files {
+			r, err := pm.openExportFile(path, _from, _to, cutoff)
+			if err != nil {
+				closeExportSources(sources)
+				return err
+			}
+			sources = append(sources, r)
+		}
+	}
+	defer closeExportSources(sources)
+
+	// k-way merge, the heap holds the next line of every source.
+	var h exportHeap
+	for i, src := range sources {
+		line, ok, err := src.next()
+		if err != nil {
+			return err
+		}
+		if ok {
+			h = append(h, exportCursor{line: line, src: src, order: i})
+		}
+	}
+	heap.Init(&h)
+	for h.Len() > 0 {
+		c := &h[0]
+		if _, err := io.WriteString(_w, c.line.line+"\n"); err != nil {
+			return errors.Wrap(err, "log export")
+		}
+		line, ok, err := c.src.next()
+		if err != nil {
+			return err
+		}
+		if ok {
+			c.line = line
+			heap.Fix(&h, 0)
+		} else {
+			heap.Pop(&h)
+		}
+	}
+	return nil
+}
+
+// The current and rotated log files possibly holding records after from,
+// oldest first.
+func (pm *ProjectInfrastructure) exportFiles(_from time.Time) ([]string, error) {
+	var matches []string
+	for _, glob := range logFileGlobs(*pm.options) {
+		m, err := filepath.Glob(glob)
+		if err != nil {
+			return nil, errors.Wrap(err, "log export")
+		}
+		matches = append(matches, m...)
+	}
+	type logFile struct {
+		path    string
+		modTime time.Time
+	}
+	var files []logFile
+	for _, path := range matches {
+		if strings.HasSuffix(path, "_lock") || strings.HasSuffix(path, "_symlink") {
+			continue
+		}
+		// The link to the current file is skipped along with other non regular files.
+		fi, err := os.Lstat(path)
+		if err != nil || !fi.Mode().IsRegular() || fi.ModTime().Before(_from) {
+			continue
+		}
+		files = append(files, logFile{path: path, modTime: fi.ModTime()})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+	paths := make([]string, len(files))
+	for i, f := range files {
+		paths[i] = f.path
+	}
+	return paths, nil
+}
+
+type exportLine struct {
+	time time.Time
+	line string
+}
+
+// Lines of a source in time order, ok is false once it is exhausted.
+type exportSource interface {
+	next() (line exportLine, ok bool, err error)
+	close()
+}
+
+func closeExportSources(_sources []exportSource) {
+	for _, src := range _sources {
+		src.close()
+	}
+}
+
+type exportCursor struct {
+	line  exportLine
+	src   exportSource
+	order int
+}
+
+// Earliest line first, lines of equal time keep the source order.
+type exportHeap []exportCursor
+
+func (h exportHeap) Len() int { return len(h) }
+
+func (h exportHeap) Less(i, j int) bool {
+	if !h[i].line.time.Equal(h[j].line.time) {
+		return h[i].line.time.Before(h[j].line.time)
+	}
+	return h[i].order < h[j].order
+}
+
+func (h exportHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *exportHeap) Push(_x interface{}) { *h = append(*h, _x.(exportCursor)) }
+
+func (h *exportHeap) Pop() interface{} {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
+
+// Lines of a log file within the window, with the time of their record.
+// Records at or after the cutoff are left to the ring buffer.
+type exportFile struct {
+	pm      *ProjectInfrastructure
+	path    string
+	f       *os.File
+	scanner *bufio.Scanner
+	from    time.Time
+	to      time.Time
+	cutoff  time.Time
+	last    time.Time
+	in      bool
+}
+
+func (pm *ProjectInfrastructure) openExportFile(_path string, _from, _to, _cutoff time.Time) (*exportFile, error) {
+	f, err := os.Open(_path)
+	if err != nil {
+		return nil, errors.Wrap(err, "log export")
+	}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	return &exportFile{pm: pm, path: _path, f: f, scanner: scanner, from: _from, to: _to, cutoff: _cutoff}, nil
+}
+
+func (e *exportFile) next() (exportLine, bool, error) {
+	for e.scanner.Scan() {
+		line := e.scanner.Text()
+		if t, ok := e.pm.recordTime(line); ok {
+			e.last = t
+			e.in = !t.Before(e.from) && t.Before(e.to) && (e.cutoff.IsZero() || t.Before(e.cutoff))
+		}
+		if e.in {
+			return exportLine{time: e.last, line: line}, true, nil
+		}
+	}
+	if err := e.scanner.Err(); err != nil {
+		return exportLine{}, false, errors.Wrapf(err, "log export %s", e.path)
+	}
+	return exportLine{}, false, nil
+}
+
+func (e *exportFile) close() {
+	e.f.Close()
+}
+
+// The records of the ring buffer within the window, formatted as the file
+// output writes them.
+type exportRing struct {
+	first time.Time
+	lines []exportLine
+}
+
+// nil when the ring buffer is empty.
+func (pm *ProjectInfrastructure) ringExportSource(_from, _to time.Time) (*exportRing, error) {
+	records := pm.ring.tail(0)
+	if len(records) == 0 {
+		return nil, nil
+	}
+	opts := *pm.options
+	opts.LogFormat = pm.fileFormat()
+	opts.LogColor = "never"
+	// As the ECS formatter of the instance fills them.
+	if opts.ServiceName == "" {
+		opts.ServiceName = filepath.Base(os.Args[0])
+	}
+	hostname, _ := os.Hostname()
+	r := &exportRing{first: records[0].Time}
+	for _, record := range records {
+		if record.Time.Before(_from) || !record.Time.Before(_to) {
+			continue
+		}
+		line, err := FormatEntry(opts, Entry{
+			Time:     record.Time,
+			Module:   record.Module,
+			Severity: record.Severity,
+			Err:      errors.New(record.Message),
+			Fields:   record.Fields,
+			Hostname: hostname,
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "log export")
+		}
+		r.lines = append(r.lines, exportLine{time: record.Time, line: strings.TrimSuffix(string(line), "\n")})
+	}
+	return r, nil
+}
+
+func (r *exportRing) next() (exportLine, bool, error) {
+	if len(r.lines) == 0 {
+		return exportLine{}, false, nil
+	}
+	line := r.lines[0]
+	r.lines = r.lines[1:]
+	return line, true, nil
+}
+
+func (r *exportRing) close() {}
+
+// Format of the file output, its own one or the log format.
+func (pm *ProjectInfrastructure) fileFormat() string {
+	if format, ok := pm.options.OutputFormats["file"]; ok {
+		return format
+	}
+	return pm.options.LogFormat
+}
+
+// Timestamp of a log file line, the "timestamp" field in JSON and logfmt,
+// "@timestamp" in ECS, the start of the message in text.
+func (pm *ProjectInfrastructure) recordTime(_line string) (time.Time, bool) {
+	loc := time.Local
+	if pm.options.TimestampUTC {
+		loc = time.UTC
+	}
+	layout := pm.options.TimestampFormat
+
+	format := pm.fileFormat()
+	if format == "logfmt" {
+		if !strings.HasPrefix(_line, "timestamp=") {
+			return time.Time{}, false
+		}
+		value := strings.TrimPrefix(_line, "timestamp=")
+		if strings.HasPrefix(value, `"`) {
+			unquoted, err := strconv.QuotedPrefix(value)
+			if err != nil {
+				return time.Time{}, false
+			}
+			value, _ = strconv.Unquote(unquoted)
+		} else if end := strings.IndexByte(value, ' '); end >= 0 {
+			value = value[:end]
+		}
+		if layout == "" {
+			layout = jsonTimestampFormat
+		}
+		t, err := time.ParseInLocation(layout, value, loc)
+		return t, err == nil
+	}
+	if format == "ecs" {
+		var record struct {
+			Timestamp string `json:"@timestamp"`
+		}
+		if json.Unmarshal([]byte(_line), &record) != nil || record.Timestamp == "" {
+			return time.Time{}, false
+		}
+		t, err := time.Parse(jsonTimestampFormat, record.Timestamp)
+		return t, err == nil
+	}
+	if format == "json" {
+		var record struct {
+			Timestamp string `json:"timestamp"`
+		}
+		if json.Unmarshal([]byte(_line), &record) != nil || record.Timestamp == "" {
+			return time.Time{}, false
+		}
+		if layout == "" {
+			layout = jsonTimestampFormat
+		}
+		t, err := time.ParseInLocation(layout, record.Timestamp, loc)
+		return t, err == nil
+	}
+
+	i := strings.Index(_line, `msg="`)
+	if i < 0 {
+		return time.Time{}, false
+	}
+	msg := _line[i+len(`msg="`):]
+	if layout == "" {
+		layout = textTimestampFormat
+	}
+	// The timestamp is as long as its layout unless it holds variable width
+	// elements, try the layout length first.
+	if len(msg) >= len(layout) {
+		if t, err := time.ParseInLocation(layout, msg[:len(layout)], loc); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,98 @@
+package infrastructure
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func exportTestInstance(_t *testing.T) (*ProjectInfrastructure, string) {
+	dir := _t.TempDir()
+	opts := DefaultOptions()
+	opts.LogFormat = "json"
+	opts.LogPath = filepath.Join(dir, "project.log")
+	opts.TimestampUTC = true
+	pm := &ProjectInfrastructure{
+		options:    &opts,
+		structured: true,
+		outputs:    []logOutputWriter{{name: "file"}},
+	}
+	return pm, dir
+}
+
+func writeExportFile(_t *testing.T, _path string, _times ...string) {
+	var b strings.Builder
+	for _, ts := range _times {
+		fmt.Fprintf(&b, `{"timestamp":"%s","msg":"%s"}`+"\n", ts, ts)
+	}
+	if err := os.WriteFile(_path, []byte(b.String()), 0644); err != nil {
+		_t.Fatal(err)
+	}
+}
+
+func exportTime(_t *testing.T, _s string) time.Time {
+	t, err := time.Parse(jsonTimestampFormat, _s)
+	if err != nil {
+		_t.Fatal(err)
+	}
+	return t
+}
+
+func TestExportLogsMergesFiles(t *testing.T) {
+	pm, dir := exportTestInstance(t)
+	writeExportFile(t, filepath.Join(dir, "project.log.1"),
+		"2026-01-01T10:00:00.000Z", "2026-01-01T10:00:02.000Z", "2026-01-01T10:00:04.000Z")
+	writeExportFile(t, filepath.Join(dir, "project.log"),
+		"2026-01-01T10:00:01.000Z", "2026-01-01T10:00:03.000Z", "2026-01-01T10:00:05.000Z")
+
+	tests := []struct {
+		from, to string
+		want     []string
+	}{
+		{"2026-01-01T10:00:00.000Z", "2026-01-01T11:00:00.000Z", []string{"00", "01", "02", "03", "04", "05"}},
+		{"2026-01-01T10:00:01.000Z", "2026-01-01T10:00:04.000Z", []string{"01", "02", "03"}},
+		{"2026-01-01T11:00:00.000Z", "2026-01-01T12:00:00.000Z", nil},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		if err := pm.ExportLogs(exportTime(t, tt.from), exportTime(t, tt.to), &out); err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+			if line == "" {
+				continue
+			}
+			i := strings.Index(line, `"msg":"`)
+			got = append(got, line[i+len(`"msg":"2026-01-01T10:00:`):][:2])
+		}
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("[%s, %s) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestExportLogsRingTakesOver(t *testing.T) {
+	pm, dir := exportTestInstance(t)
+	writeExportFile(t, filepath.Join(dir, "project.log"),
+		"2026-01-01T10:00:00.000Z", "2026-01-01T10:00:01.000Z", "2026-01-01T10:00:02.000Z")
+	pm.ring = newRingBuffer(4)
+	pm.ring.add(LogRecord{Time: exportTime(t, "2026-01-01T10:00:01.000Z"), Module: "m", Severity: "info", Message: "ring one"})
+	pm.ring.add(LogRecord{Time: exportTime(t, "2026-01-01T10:00:02.000Z"), Module: "m", Severity: "info", Message: "ring two"})
+
+	var out bytes.Buffer
+	if err := pm.ExportLogs(exportTime(t, "2026-01-01T09:00:00.000Z"), exportTime(t, "2026-01-01T11:00:00.000Z"), &out); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got %d lines, want 3:\n%s", len(lines), out.String())
+	}
+	if !strings.Contains(lines[0], "10:00:00") || !strings.Contains(lines[1], "ring one") || !strings.Contains(lines[2], "ring two") {
+		t.Errorf("unexpected export:\n%s", out.String())
+	}
+}
@@ -0,0 +1,105 @@
+package infrastructure
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+type flusher struct {
+	name string
+	fn   func(context.Context) error
+}
+
+/*
+Register an observability provider (tracing, metrics, profiling, error
+reporting, ...) to be flushed during ResourceRelease
+
+@name: provider name used in the flush report
+
+@fn: flush function, it must return when the context is done
+*/
+func (pm *ProjectInfrastructure) RegisterFlusher(_name string, _fn func(ctx context.Context) error) {
+	pm.flushMu.Lock()
+	defer pm.flushMu.Unlock()
+	pm.flushers = append(pm.flushers, flusher{name: _name, fn: _fn})
+}
+
+// Flush all registered providers concurrently within the flush timeout and
+// return the result of each provider, nil meaning success.
+func (pm *ProjectInfrastructure) Flush() map[string]error {
+	pm.flushMu.Lock()
+	flushers := append([]flusher(nil), pm.flushers...)
+	pm.flushMu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), pm.options.FlushTimeout)
+	defer cancel()
+
+	var mu sync.Mutex
+	results := make(map[string]error, len(flushers))
+	var wg sync.WaitGroup
+	for _, f := range flushers {
+		wg.Add(1)
+		go func(f flusher) {
+			defer wg.Done()
+
+			done := make(chan error, 1)
+			go func() { done <- f.fn(ctx) }()
+
+			var err error
+			select {
+			case err = <-done:
+			case <-ctx.Done():
+				err = errors.Errorf("flush timed out after %s", pm.options.FlushTimeout)
+			}
+			mu.Lock()
+			results[f.name] = err
+			mu.Unlock()
+		}(f)
+	}
+	wg.Wait()
+	return results
+}
+
+// Flush the providers and log the result of each one.
+func (pm *ProjectInfrastructure) flushProviders() {
+	results := pm.Flush()
+
+	names := make([]string, 0, len(results))
+	for name := range results {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if err := results[name]; err != nil {
+			pm.logOutput("flush", "error", errors.Errorf("flush failed: %v", err), false, logrus.Fields{"provider": name})
+			continue
+		}
+		pm.logOutput("flush", "debug", errors.New("flushed"), false, logrus.Fields{"provider": name})
+	}
+}
+
+// Close a file of the infrastructure once the providers are flushed.
+func (pm *ProjectInfrastructure) registerCloser(_name string, _fn func() error) {
+	pm.flushMu.Lock()
+	defer pm.flushMu.Unlock()
+	pm.closers = append(pm.closers, flusher{name: _name, fn: func(context.Context) error { return _fn() }})
+}
+
+// Close the registered files, last opened first, and log the failures.
+func (pm *ProjectInfrastructure) closeResources() {
+	pm.flushMu.Lock()
+	closers := pm.closers
+	pm.closers = nil
+	pm.flushMu.Unlock()
+
+	for i := len(closers) - 1; i >= 0; i-- {
+		if err := closers[i].fn(context.Background()); err != nil {
+			pm.logOutput("release", "error", errors.Errorf("close %s: %v", closers[i].name, err), false, nil)
+		}
+	}
+}
@@ -0,0 +1,336 @@
+package infrastructure
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	textTimestampFormat = "2006-01-02 15:04:05"
+	jsonTimestampFormat = "2006-01-02T15:04:05.000Z07:00"
+)
+
+// Format a timestamp with the layout, or the format default when empty.
+func formatTimestamp(_t time.Time, _layout, _default string, _utc bool) string {
+	if _layout == "" {
+		_layout = _default
+	}
+	if _utc {
+		_t = _t.UTC()
+	}
+	return _t.Format(_layout)
+}
+
+// One JSON object per line with timestamp, severity, module, error and the
+// structured fields of the record.
+type jsonFormatter struct {
+	layout string
+	utc    bool
+	// Schema version of the records, 0 for the latest
+	schema int
+}
+
+func (f *jsonFormatter) Format(_entry *logrus.Entry) ([]byte, error) {
+	fields, severity := pinSchema(_entry, f.schema)
+	data := make(logrus.Fields, len(fields)+3)
+	for k, v := range fields {
+		// Errors marshal to "{}", keep their message instead.
+		if err, ok := v.(error); ok {
+			v = err.Error()
+		}
+		data[k] = v
+	}
+	data["timestamp"] = formatTimestamp(_entry.Time, f.layout, jsonTimestampFormat, f.utc)
+	data["severity"] = severity
+	data["error"] = _entry.Message
+
+	b, err := json.Marshal(data)
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal log entry")
+	}
+	return append(b, '\n'), nil
+}
+
+// One line of key=value pairs with timestamp, severity, module and error
+// first, then the structured fields by name.
+type logfmtFormatter struct {
+	layout string
+	utc    bool
+	// Schema version of the records, 0 for the latest
+	schema int
+}
+
+func (f *logfmtFormatter) Format(_entry *logrus.Entry) ([]byte, error) {
+	fields, severity := pinSchema(_entry, f.schema)
+	var b bytes.Buffer
+	writeLogfmt(&b, "timestamp", formatTimestamp(_entry.Time, f.layout, jsonTimestampFormat, f.utc))
+	writeLogfmt(&b, "severity", severity)
+	if module, ok := fields["module"]; ok {
+		writeLogfmt(&b, "module", module)
+	}
+	writeLogfmt(&b, "error", _entry.Message)
+
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		if k != "module" && k != "severity" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		writeLogfmt(&b, k, fields[k])
+	}
+	b.WriteByte('\n')
+	return b.Bytes(), nil
+}
+
+const ecsVersion = "8.11.0"
+
+// One JSON object per line with the Elastic Common Schema fields: log.level,
+// message, error.message and error.stack_trace, service.name and
+// event.module. The trace and span IDs of the record become trace.id and
+// span.id, the other fields are kept at the top level.
+type ecsFormatter struct {
+	service  string
+	hostname string
+}
+
+func newECSFormatter(_opts ProjectInfrastructureOptions) *ecsFormatter {
+	service := _opts.ServiceName
+	if service == "" {
+		service = filepath.Base(os.Args[0])
+	}
+	hostname, _ := os.Hostname()
+	return &ecsFormatter{service: service, hostname: hostname}
+}
+
+func (f *ecsFormatter) Format(_entry *logrus.Entry) ([]byte, error) {
+	data := make(map[string]interface{}, len(_entry.Data)+6)
+	for k, v := range _entry.Data {
+		if err, ok := v.(error); ok {
+			v = err.Error()
+		}
+		data[k] = v
+	}
+	delete(data, "module")
+	delete(data, "severity")
+
+	// The error chain of print_stack starts with the bottom message.
+	message := _entry.Message
+	ecsError := map[string]interface{}{"message": message}
+	if i := strings.IndexByte(message, '\n'); i >= 0 {
+		message = message[:i]
+		ecsError["message"] = message
+		ecsError["stack_trace"] = _entry.Message
+	}
+	for field, key := range map[string]string{"trace_id": "trace", "span_id": "span"} {
+		if id, ok := data[field]; ok {
+			data[key] = map[string]interface{}{"id": id}
+			delete(data, field)
+		}
+	}
+
+	data["@timestamp"] = formatTimestamp(_entry.Time, jsonTimestampFormat, "", true)
+	data["ecs"] = map[string]string{"version": ecsVersion}
+	data["log"] = map[string]string{"level": entrySeverity(_entry)}
+	data["message"] = message
+	if f.service != "" {
+		data["service"] = map[string]string{"name": f.service}
+	}
+	if f.hostname != "" {
+		data["host"] = map[string]string{"hostname": f.hostname}
+	}
+	if _entry.Level <= logrus.ErrorLevel {
+		data["error"] = ecsError
+	}
+	if module := entryModule(_entry); module != "" {
+		data["event"] = map[string]string{"module": module}
+	}
+
+	b, err := json.Marshal(data)
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal log entry")
+	}
+	return append(b, '\n'), nil
+}
+
+func writeLogfmt(_b *bytes.Buffer, _key string, _value interface{}) {
+	if _b.Len() > 0 {
+		_b.WriteByte(' ')
+	}
+	_b.WriteString(_key)
+	_b.WriteByte('=')
+
+	var s string
+	switch v := _value.(type) {
+	case string:
+		s = v
+	case error:
+		s = v.Error()
+	case fmt.Stringer:
+		s = v.String()
+	default:
+		s = fmt.Sprint(v)
+	}
+	if s == "" || strings.ContainsAny(s, " =\"") || strings.IndexFunc(s, func(r rune) bool { return r < ' ' || r == 0x7f }) >= 0 {
+		s = strconv.Quote(s)
+	}
+	_b.WriteString(s)
+}
+
+// Emit a record for the structured formatters: the module goes into a field
+// and the message is the bottom error or the error chain.
+func (pm *ProjectInfrastructure) structuredOutput(_entry *logrus.Entry, _module, _severity string, _err error, _print_stack bool) {
+	msg := errors.Cause(_err).Error()
+	if _print_stack {
+		msg = fmt.Sprintf("%+v", _err)
+		if pm.devMode {
+			msg = abbreviateStack(msg)
+		}
+	}
+
+	level, err := parseLogLevel(_severity)
+	if err != nil {
+		level = logrus.ErrorLevel
+		msg = fmt.Sprintf("[unsupport error type: %s] %s", _severity, msg)
+	}
+	logAt(_entry.WithField("module", _module), level, msg)
+}
+
+// Fields of every record: the static labels and the environment.
+func staticFields(_opts ProjectInfrastructureOptions) logrus.Fields {
+	fields := make(logrus.Fields, len(_opts.StaticLabels)+3)
+	for k, v := range _opts.StaticLabels {
+		fields[k] = v
+	}
+	for k, v := range map[string]string{"env": _opts.Environment, "region": _opts.Region, "cluster": _opts.Cluster} {
+		if v != "" {
+			fields[k] = v
+		}
+	}
+	return fields
+}
+
+// A record to format with FormatEntry.
+type Entry struct {
+	// Also the time shown in the message of the text format
+	Time       time.Time
+	Module     string
+	Severity   string
+	Err        error
+	PrintStack bool
+	Fields     map[string]interface{}
+	// File and line of the call site, reported when ErrorCaller is set
+	Caller string
+	// Host of the ECS format, left out when empty
+	Hostname string
+}
+
+/*
+Format a record into the bytes the first log output would receive
+
+The result only depends on the options and the entry, it reads no clock and
+no instance state. Dev mode, whose timestamps are relative to the start of
+the process, is formatted as text, and text is formatted as for an output
+that is not a terminal: colored only when LogColor is "always". The ECS
+service is ServiceName and its host the Hostname of the entry, each left
+out when empty.
+*/
+func FormatEntry(_opts ProjectInfrastructureOptions, _entry Entry) ([]byte, error) {
+	if _entry.Err == nil {
+		return nil, errors.New("entry without error")
+	}
+	if len(_opts.RedactPatterns) > 0 || len(_opts.RedactKeys) > 0 {
+		r, err := newRedactor(_opts)
+		if err != nil {
+			return nil, err
+		}
+		_entry.Err = r.error(_entry.Err)
+		_entry.Fields = r.fields(_entry.Fields)
+	}
+
+	data := staticFields(_opts)
+	for k, v := range _entry.Fields {
+		data[k] = v
+	}
+	if _opts.ErrorOrigin {
+		if pkg, fn, ok := errorOrigin(_entry.Err); ok {
+			data["origin_pkg"] = pkg
+			data["origin_func"] = fn
+		}
+	}
+	if _opts.ErrorCaller && _entry.Caller != "" {
+		data["caller"] = _entry.Caller
+	}
+	if _opts.FirstOccurrenceWindow > 0 {
+		data["fingerprint"] = Fingerprint(_entry.Module, _entry.Err)
+	}
+	return formatRecord(_opts, _entry, data)
+}
+
+// Format the record with the fields of its line, in the LogFormat of the
+// options.
+func formatRecord(_opts ProjectInfrastructureOptions, _entry Entry, _data logrus.Fields) ([]byte, error) {
+	if isCustomSeverity(_entry.Severity) {
+		_data["severity"] = _entry.Severity
+	}
+	entry := &logrus.Entry{Data: _data, Time: _entry.Time}
+
+	switch _opts.LogFormat {
+	case "json", "logfmt", "ecs":
+		msg := errors.Cause(_entry.Err).Error()
+		if _entry.PrintStack {
+			msg = fmt.Sprintf("%+v", _entry.Err)
+		}
+		level, err := parseLogLevel(_entry.Severity)
+		if err != nil {
+			level = logrus.ErrorLevel
+			msg = fmt.Sprintf("[unsupport error type: %s] %s", _entry.Severity, msg)
+		}
+		_data["module"] = _entry.Module
+		entry.Level, entry.Message = level, msg
+		switch _opts.LogFormat {
+		case "logfmt":
+			return (&logfmtFormatter{layout: _opts.TimestampFormat, utc: _opts.TimestampUTC, schema: _opts.SchemaVersion}).Format(entry)
+		case "ecs":
+			return (&ecsFormatter{service: _opts.ServiceName, hostname: _entry.Hostname}).Format(entry)
+		}
+		return (&jsonFormatter{layout: _opts.TimestampFormat, utc: _opts.TimestampUTC, schema: _opts.SchemaVersion}).Format(entry)
+	case "text":
+		var color string
+		if outputs := parseLogOutputs(_opts.LogOut); len(outputs) > 0 && outputs[0] == "stdout" && _opts.LogColor == "always" {
+			theme, err := parseColorTheme(_opts.LogColors)
+			if err != nil {
+				return nil, err
+			}
+			color = themeColor(theme, _entry.Severity)
+		}
+		ts := formatTimestamp(_entry.Time, _opts.TimestampFormat, textTimestampFormat, _opts.TimestampUTC)
+
+		level, err := parseLogLevel(_entry.Severity)
+		switch {
+		case err != nil:
+			entry.Level = logrus.ErrorLevel
+			entry.Message = fmt.Sprintf("[unsupport error type: %s]", _entry.Severity) +
+				formatLogMessage(ts, errors.Cause(_entry.Err), _entry.Module, color)
+		case _entry.PrintStack:
+			entry.Level = level
+			entry.Message = fmt.Sprintf(formatStackHeader(ts, _entry.Module, color)+"\n%+v", _entry.Err)
+		default:
+			entry.Level = level
+			entry.Message = formatLogMessage(ts, errors.Cause(_entry.Err), _entry.Module, color)
+		}
+		return (&logrus.TextFormatter{DisableTimestamp: true}).Format(entry)
+	}
+	return nil, errors.Errorf("invalid log format %s, valid values are %s", _opts.LogFormat, supportLogFormats)
+}
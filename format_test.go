@@ -0,0 +1,84 @@
+package infrastructure
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestFormatEntry(t *testing.T) {
+	entry := Entry{
+		Time:     time.Date(2026, 3, 4, 5, 6, 7, 0, time.UTC),
+		Module:   "api",
+		Severity: "warn",
+		Err:      errors.Wrap(errors.New("disk full"), "write cache"),
+		Fields:   map[string]interface{}{"request_id": "r1"},
+	}
+	tests := []struct {
+		format string
+		want   []string
+	}{
+		{"text", []string{`level=warning`, `msg="2026-03-04 05:06:07 api        disk full"`, `request_id=r1`}},
+		{"json", []string{`"timestamp":"2026-03-04T05:06:07.000Z"`, `"module":"api"`, `"error":"disk full"`, `"request_id":"r1"`}},
+		{"logfmt", []string{`timestamp=2026-03-04T05:06:07.000Z`, `module=api`, `request_id=r1`}},
+		{"ecs", []string{`"@timestamp":"2026-03-04T05:06:07.000Z"`, `"log":{"level":"warn"}`, `"event":{"module":"api"}`}},
+	}
+	for _, tt := range tests {
+		opts := DefaultOptions()
+		opts.LogFormat = tt.format
+		opts.TimestampUTC = true
+		b, err := FormatEntry(opts, entry)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.format, err)
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(string(b), want) {
+				t.Errorf("%s: %q does not contain %q", tt.format, b, want)
+			}
+		}
+		again, _ := FormatEntry(opts, entry)
+		if string(again) != string(b) {
+			t.Errorf("%s: output is not deterministic", tt.format)
+		}
+	}
+}
+
+func TestFormatEntryECSHost(t *testing.T) {
+	opts := DefaultOptions()
+	opts.LogFormat = "ecs"
+	entry := Entry{Time: time.Unix(0, 0), Module: "api", Severity: "error", Err: errors.New("boom")}
+
+	tests := []struct {
+		service  string
+		hostname string
+	}{
+		{"", ""},
+		{"shop", "web-1"},
+	}
+	for _, tt := range tests {
+		opts.ServiceName = tt.service
+		entry.Hostname = tt.hostname
+		b, err := FormatEntry(opts, entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var record map[string]interface{}
+		if err := json.Unmarshal(b, &record); err != nil {
+			t.Fatal(err)
+		}
+		_, hasService := record["service"]
+		_, hasHost := record["host"]
+		if hasService != (tt.service != "") || hasHost != (tt.hostname != "") {
+			t.Errorf("service %q host %q: got %s", tt.service, tt.hostname, b)
+		}
+	}
+}
+
+func TestFormatEntryWithoutError(t *testing.T) {
+	if _, err := FormatEntry(DefaultOptions(), Entry{Severity: "info"}); err == nil {
+		t.Fatal("expected an error for an entry without error")
+	}
+}
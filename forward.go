@@ -0,0 +1,70 @@
+package infrastructure
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// Descriptor of the pipe a supervising ManageProcess hands to its child.
+const LogForwardFDEnv = "INFRA_LOG_FD"
+
+// Records sent to the parent process as JSON LogRecord lines.
+type parentForwarder struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// The pipe inherited from the parent, nil when not started by a ManageProcess
+// forwarding the logs.
+func openParentForwarder() (*parentForwarder, error) {
+	env := os.Getenv(LogForwardFDEnv)
+	if env == "" {
+		return nil, nil
+	}
+	fd, err := strconv.Atoi(env)
+	if err != nil || fd < 3 {
+		return nil, errors.Errorf("invalid %s %q", LogForwardFDEnv, env)
+	}
+	return &parentForwarder{w: os.NewFile(uintptr(fd), "parent-log")}, nil
+}
+
+func (f *parentForwarder) forward(_record LogRecord) error {
+	line, err := json.Marshal(_record)
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, err = f.w.Write(append(line, '\n'))
+	return err
+}
+
+// Add the pipe receiving the structured records of the child to the command,
+// the parent end is returned.
+func forwardProcessLogs(_cmd *exec.Cmd) (*os.File, error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, errors.Wrap(err, "log forwarding pipe")
+	}
+	env := _cmd.Env
+	if env == nil {
+		env = os.Environ()
+	}
+	_cmd.Env = append(append([]string(nil), env...), fmt.Sprintf("%s=%d", LogForwardFDEnv, 3+len(_cmd.ExtraFiles)))
+	_cmd.ExtraFiles = append(append([]*os.File(nil), _cmd.ExtraFiles...), w)
+	return r, nil
+}
+
+// Log the records forwarded by a child until it closes the pipe.
+func (pm *ProjectInfrastructure) receiveProcessLogs(_name string, _r io.ReadCloser) {
+	defer _r.Close()
+	pm.ingestRecords(_r, "forward", logrus.Fields{"process": _name})
+}
@@ -0,0 +1,78 @@
+package infrastructure
+
+import (
+	"bytes"
+	"context"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Buffer written by the output while the test reads it.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(_p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(_p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// Wait until the buffer holds the text.
+func (b *syncBuffer) waitFor(_t *testing.T, _text string) {
+	_t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !strings.Contains(b.String(), _text) {
+		if time.Now().After(deadline) {
+			_t.Fatalf("%q not written:\n%s", _text, b.String())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestForwardedChildFatal(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a shell")
+	}
+	var buf syncBuffer
+	exited := false
+	pm, err := NewProjectInfrastructure(context.Background(),
+		WithLibraryMode(true),
+		WithLogOutput("writer"),
+		WithLogWriter(&buf),
+		WithExitHandler(func(int) { exited = true }),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pm.Shutdown()
+
+	// The child writes its records to the descriptor named by INFRA_LOG_FD.
+	cmd := exec.Command("sh", "-c", `echo '{"module":"db","severity":"fatal","message":"cannot open store","fields":{"attempt":3}}' >&$INFRA_LOG_FD`)
+	if err := pm.ManageProcess("worker", cmd, RestartPolicy{ForwardLogs: true}); err != nil {
+		t.Fatal(err)
+	}
+	buf.waitFor(t, "process exited")
+	buf.waitFor(t, "cannot open store")
+
+	out := buf.String()
+	for _, want := range []string{"level=error", "ingested_severity=fatal", "process=worker", "attempt=3"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("%s missing from the forwarded record:\n%s", want, out)
+		}
+	}
+	if exited || pm.State() != StateRunning {
+		t.Fatalf("forwarded fatal ended the parent, state %s", pm.State())
+	}
+}
@@ -0,0 +1,159 @@
+package infrastructure
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"encoding/json"
+	"net"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	_gelfChunkSize = 8192
+	_gelfMaxChunks = 128
+)
+
+var gelfLevels = map[logrus.Level]int{
+	logrus.PanicLevel: 0,
+	logrus.FatalLevel: 2,
+	logrus.ErrorLevel: 3,
+	logrus.WarnLevel:  4,
+	logrus.InfoLevel:  6,
+	logrus.DebugLevel: 7,
+	logrus.TraceLevel: 7,
+}
+
+// Send records to Graylog as GELF messages. Over UDP messages are compressed
+// and split into chunks when larger than a datagram, over TCP they are
+// delimited by a null byte. GELF has no receipts, delivery is best effort.
+type gelfWriter struct {
+	mu       sync.Mutex
+	conn     net.Conn
+	udp      bool
+	hostname string
+}
+
+func openGelf(_opts ProjectInfrastructureOptions) (*gelfWriter, error) {
+	if _opts.GelfNetwork != "udp" && _opts.GelfNetwork != "tcp" {
+		return nil, errors.Errorf("invalid gelf network %s, valid values are udp and tcp", _opts.GelfNetwork)
+	}
+	conn, err := net.Dial(_opts.GelfNetwork, _opts.GelfAddress)
+	if err != nil {
+		return nil, errors.Wrap(err, "connect graylog")
+	}
+	hostname, _ := os.Hostname()
+	return &gelfWriter{conn: conn, udp: _opts.GelfNetwork == "udp", hostname: hostname}, nil
+}
+
+func (g *gelfWriter) Write(_p []byte) (int, error) {
+	if err := g.WriteLevel(logrus.InfoLevel, _p); err != nil {
+		return 0, err
+	}
+	return len(_p), nil
+}
+
+func (g *gelfWriter) WriteLevel(_level logrus.Level, _p []byte) error {
+	return g.send(g.message(_level, string(_p), "", nil))
+}
+
+func (g *gelfWriter) WriteEntry(_entry *logrus.Entry, _line []byte) error {
+	msg := g.message(_entry.Level, _entry.Message, entryModule(_entry), _entry.Data)
+	msg["timestamp"] = float64(_entry.Time.UnixNano()) / 1e9
+	return g.send(msg)
+}
+
+// A GELF 1.1 message, the first line is the short message and the whole
+// text, such as an error chain with its stack, the full message.
+func (g *gelfWriter) message(_level logrus.Level, _text, _module string, _fields logrus.Fields) map[string]interface{} {
+	text := strings.TrimRight(_text, "\n")
+	short := text
+	if i := strings.IndexByte(text, '\n'); i >= 0 {
+		short = strings.TrimSpace(text[:i])
+	}
+
+	msg := map[string]interface{}{
+		"version":       "1.1",
+		"host":          g.hostname,
+		"short_message": short,
+		"level":         gelfLevels[_level],
+	}
+	if short != text {
+		msg["full_message"] = text
+	}
+	if _module != "" {
+		msg["_module"] = _module
+	}
+	for k, v := range _fields {
+		if k == "module" || k == "id" {
+			continue
+		}
+		if err, ok := v.(error); ok {
+			v = err.Error()
+		}
+		msg["_"+k] = v
+	}
+	return msg
+}
+
+func (g *gelfWriter) send(_msg map[string]interface{}) error {
+	data, err := json.Marshal(_msg)
+	if err != nil {
+		return errors.Wrap(err, "marshal gelf message")
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if !g.udp {
+		_, err = g.conn.Write(append(data, 0))
+		return err
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write(data)
+	zw.Close()
+	data = buf.Bytes()
+	if len(data) <= _gelfChunkSize {
+		_, err = g.conn.Write(data)
+		return err
+	}
+	return g.sendChunks(data)
+}
+
+// Split a message into chunks sharing a random id, each prefixed with the
+// chunk magic bytes, its sequence number and the chunk count.
+func (g *gelfWriter) sendChunks(_data []byte) error {
+	const header = 12
+	size := _gelfChunkSize - header
+	count := (len(_data) + size - 1) / size
+	if count > _gelfMaxChunks {
+		return errors.Errorf("gelf message of %d bytes needs more than %d chunks", len(_data), _gelfMaxChunks)
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return errors.Wrap(err, "gelf message id")
+	}
+	for i := 0; i < count; i++ {
+		end := (i + 1) * size
+		if end > len(_data) {
+			end = len(_data)
+		}
+		chunk := make([]byte, 0, header+end-i*size)
+		chunk = append(chunk, 0x1e, 0x0f)
+		chunk = append(chunk, id...)
+		chunk = append(chunk, byte(i), byte(count))
+		chunk = append(chunk, _data[i*size:end]...)
+		if _, err := g.conn.Write(chunk); err != nil {
+			return errors.Errorf("gelf chunk %d/%d: %v", i+1, count, err)
+		}
+	}
+	return nil
+}
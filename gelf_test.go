@@ -0,0 +1,154 @@
+package infrastructure
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+func gelfOutput(_t *testing.T, _network, _address string) *ProjectInfrastructure {
+	_t.Helper()
+	pm, err := NewProjectInfrastructure(context.Background(),
+		WithLibraryMode(true),
+		WithLogOutput("gelf"),
+		WithGelf(_network, _address),
+	)
+	if err != nil {
+		_t.Fatal(err)
+	}
+	_t.Cleanup(func() { pm.Shutdown() })
+	return pm
+}
+
+func decodeGelf(_t *testing.T, _data []byte) map[string]interface{} {
+	_t.Helper()
+	var msg map[string]interface{}
+	if err := json.Unmarshal(_data, &msg); err != nil {
+		_t.Fatalf("invalid gelf message %q: %v", _data, err)
+	}
+	return msg
+}
+
+func gunzip(_t *testing.T, _data []byte) []byte {
+	_t.Helper()
+	zr, err := gzip.NewReader(bytes.NewReader(_data))
+	if err != nil {
+		_t.Fatal(err)
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		_t.Fatal(err)
+	}
+	return data
+}
+
+func TestGelfUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	pm := gelfOutput(t, "udp", conn.LocalAddr().String())
+
+	// A small message is one gzip datagram
+	pm.ErrorTransmit("db", "error", errors.New("connection refused"), false, false)
+	buf := make([]byte, 65536)
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := decodeGelf(t, gunzip(t, buf[:n]))
+	if msg["version"] != "1.1" || msg["level"] != float64(gelfLevels[logrus.ErrorLevel]) || msg["_module"] != "db" {
+		t.Errorf("message %v", msg)
+	}
+	if msg["host"] == "" || msg["timestamp"] == nil {
+		t.Errorf("message without host or timestamp: %v", msg)
+	}
+
+	// A large one is split into chunks of one id, reassembled in order
+	body := noisyText(12000)
+	pm.ErrorTransmitFields("db", "warn", errors.New("slow query"), map[string]interface{}{"query": body}, false, false)
+	var id []byte
+	var chunks [][]byte
+	for count := 1; len(chunks) < count; {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		chunk := buf[:n]
+		if n < 12 || chunk[0] != 0x1e || chunk[1] != 0x0f {
+			t.Fatalf("datagram %d is not a gelf chunk", len(chunks))
+		}
+		if id == nil {
+			id, count = append([]byte(nil), chunk[2:10]...), int(chunk[11])
+			chunks = make([][]byte, 0, count)
+		}
+		if !bytes.Equal(chunk[2:10], id) || int(chunk[10]) != len(chunks) || int(chunk[11]) != count {
+			t.Fatalf("chunk %d: id %x seq %d count %d", len(chunks), chunk[2:10], chunk[10], chunk[11])
+		}
+		if n > _gelfChunkSize {
+			t.Errorf("chunk %d of %d bytes", len(chunks), n)
+		}
+		chunks = append(chunks, append([]byte(nil), chunk[12:]...))
+	}
+	if len(chunks) < 2 {
+		t.Fatalf("%d chunks, want a split message", len(chunks))
+	}
+	msg = decodeGelf(t, gunzip(t, bytes.Join(chunks, nil)))
+	if msg["_query"] != body || msg["level"] != float64(gelfLevels[logrus.WarnLevel]) {
+		t.Errorf("reassembled message level %v, query of %d bytes", msg["level"], len(msg["_query"].(string)))
+	}
+}
+
+func TestGelfTCP(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	pm := gelfOutput(t, "tcp", l.Addr().String())
+	conn, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	// Messages are plain JSON delimited by a null byte, the first line of a
+	// multi-line text is the short message
+	pm.ErrorTransmit("db", "error", errors.New("connection refused\nat db.go:12"), false, false)
+	pm.ErrorTransmit("api", "warn", errors.New("slow"), false, false)
+	r := bufio.NewReader(conn)
+	tests := []struct {
+		module, short string
+		full          bool
+	}{
+		{"db", "connection refused", true},
+		{"api", "slow", false},
+	}
+	for _, tt := range tests {
+		data, err := r.ReadBytes(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		msg := decodeGelf(t, data[:len(data)-1])
+		short, _ := msg["short_message"].(string)
+		if msg["_module"] != tt.module || !strings.HasSuffix(short, tt.short) {
+			t.Errorf("message %v, want module %s and short message %q", msg, tt.module, tt.short)
+		}
+		if _, full := msg["full_message"]; full != tt.full {
+			t.Errorf("%s: full message %v, want %v", tt.module, msg["full_message"], tt.full)
+		}
+	}
+}
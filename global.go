@@ -0,0 +1,52 @@
+package infrastructure
+
+import (
+	"sync/atomic"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Instance used by the package-level helpers, set with SetDefault.
+var defaultInfra atomic.Pointer[ProjectInfrastructure]
+
+// Make the instance the target of the package-level helpers. Prefer passing
+// the *ProjectInfrastructure around, this is meant for small programs and
+// library code that cannot reach it.
+func SetDefault(_pm *ProjectInfrastructure) {
+	defaultInfra.Store(_pm)
+}
+
+// The instance set with SetDefault, nil if none.
+func Default() *ProjectInfrastructure {
+	return defaultInfra.Load()
+}
+
+// Transmit the error through the default instance. Without one the error is
+// still written to the standard logrus logger so it is never lost.
+func transmitDefault(_module, _severity string, _err error) {
+	if pm := Default(); pm != nil {
+		pm.ErrorTransmit(_module, _severity, _err, false, false)
+		return
+	}
+	level, err := parseLogLevel(_severity)
+	if err != nil {
+		level = logrus.ErrorLevel
+	}
+	logrus.WithField("module", _module).Log(level, _err)
+}
+
+func Debug(_module string, _err error) {
+	transmitDefault(_module, "debug", _err)
+}
+
+func Info(_module string, _err error) {
+	transmitDefault(_module, "info", _err)
+}
+
+func Warn(_module string, _err error) {
+	transmitDefault(_module, "warn", _err)
+}
+
+func Error(_module string, _err error) {
+	transmitDefault(_module, "error", _err)
+}
@@ -3,13 +3,17 @@ module github.com/just-lick-it/infrastructure
 go 1.21.6
 
 require (
+	github.com/fsnotify/fsnotify v1.7.0
+	github.com/getsentry/sentry-go v0.27.0
+	github.com/klauspost/compress v1.17.4
 	github.com/lestrrat-go/file-rotatelogs v2.4.0+incompatible
 	github.com/pkg/errors v0.9.1
 	github.com/sirupsen/logrus v1.9.3
+	golang.org/x/sys v0.6.0
 )
 
 require (
 	github.com/jonboulle/clockwork v0.5.0 // indirect
 	github.com/lestrrat-go/strftime v1.1.0 // indirect
-	golang.org/x/sys v0.0.0-20220715151400-c0bba94af5f8 // indirect
+	golang.org/x/text v0.8.0 // indirect
 )
@@ -0,0 +1,166 @@
+package infrastructure
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// A health check and the checks it depends on.
+type healthCheck struct {
+	name      string
+	check     func(ctx context.Context) error
+	dependsOn []string
+}
+
+type healthChecks struct {
+	mu     sync.Mutex
+	checks []healthCheck
+	names  map[string]bool
+	// Readiness of the last report, nil before the first one
+	ready *bool
+}
+
+/*
+Result of a health check in a HealthReport
+
+@DependsOn: the checks it depends on, the edges of the dependency graph
+
+@BlockedBy: the root causes a failed dependency leads to, the check was not
+run then
+*/
+type HealthCheckResult struct {
+	DependsOn []string `json:"depends_on,omitempty"`
+	Error     string   `json:"error,omitempty"`
+	BlockedBy []string `json:"blocked_by,omitempty"`
+}
+
+/*
+Readiness of the registered health checks, see CheckHealth
+
+@RootCauses: the checks failing although their dependencies pass, e.g. "db"
+rather than every check depending on it
+*/
+type HealthReport struct {
+	Time       time.Time                    `json:"time"`
+	Ready      bool                         `json:"ready"`
+	RootCauses []string                     `json:"root_causes,omitempty"`
+	Checks     map[string]HealthCheckResult `json:"checks"`
+}
+
+/*
+Add a health check to the readiness, e.g. one of the checks package
+
+The dependencies must be registered first, a check is not run while one of
+them fails and reports the root cause instead.
+*/
+func (pm *ProjectInfrastructure) RegisterHealthCheck(_name string, _check func(ctx context.Context) error, _dependsOn ...string) error {
+	pm.health.mu.Lock()
+	defer pm.health.mu.Unlock()
+
+	if pm.health.names[_name] {
+		return errors.Errorf("health check %s already registered", _name)
+	}
+	for _, dep := range _dependsOn {
+		if !pm.health.names[dep] {
+			return errors.Errorf("health check %s depends on %s, which is not registered", _name, dep)
+		}
+	}
+	if pm.health.names == nil {
+		pm.health.names = make(map[string]bool)
+	}
+	pm.health.names[_name] = true
+	pm.health.checks = append(pm.health.checks, healthCheck{name: _name, check: _check, dependsOn: _dependsOn})
+	return nil
+}
+
+/*
+Gate the readiness on a circuit breaker, e.g. the one of the db client
+
+The instance is unready while open returns true, so load balancers stop
+sending traffic it cannot serve, and ready again once the breaker closed.
+Health checks may depend on the breaker by its name.
+*/
+func (pm *ProjectInfrastructure) RegisterReadinessBreaker(_name string, _open func() bool) error {
+	return pm.RegisterHealthCheck(_name, func(context.Context) error {
+		if _open() {
+			return errors.Errorf("circuit breaker %s open", _name)
+		}
+		return nil
+	})
+}
+
+// Run the health checks in dependency order. Changes of the readiness are
+// logged.
+func (pm *ProjectInfrastructure) CheckHealth(_ctx context.Context) HealthReport {
+	pm.health.mu.Lock()
+	checks := append([]healthCheck(nil), pm.health.checks...)
+	pm.health.mu.Unlock()
+
+	report := HealthReport{Time: time.Now(), Ready: true, Checks: make(map[string]HealthCheckResult, len(checks))}
+	// Root causes of the failed checks, themselves for the failing ones
+	causes := make(map[string][]string)
+	for _, c := range checks {
+		result := HealthCheckResult{DependsOn: c.dependsOn}
+		for _, dep := range c.dependsOn {
+			for _, cause := range causes[dep] {
+				if !containsString(result.BlockedBy, cause) {
+					result.BlockedBy = append(result.BlockedBy, cause)
+				}
+			}
+		}
+		if len(result.BlockedBy) > 0 {
+			causes[c.name] = result.BlockedBy
+		} else if err := c.check(_ctx); err != nil {
+			result.Error = err.Error()
+			causes[c.name] = []string{c.name}
+			report.RootCauses = append(report.RootCauses, c.name)
+		}
+		if causes[c.name] != nil {
+			report.Ready = false
+		}
+		report.Checks[c.name] = result
+	}
+
+	pm.health.mu.Lock()
+	changed := pm.health.ready == nil || *pm.health.ready != report.Ready
+	pm.health.ready = &report.Ready
+	pm.health.mu.Unlock()
+	switch {
+	case changed && !report.Ready:
+		pm.logOutput("health", "warn", errors.Errorf("not ready: %s", strings.Join(report.RootCauses, ", ")), false, nil)
+	case changed && len(checks) > 0:
+		pm.logOutput("health", "info", errors.New("ready"), false, nil)
+	}
+	return report
+}
+
+func containsString(_list []string, _s string) bool {
+	for _, v := range _list {
+		if v == _s {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+HTTP handler running the health checks, meant to be mounted at "/readyz" for
+the load balancer. It answers 200 when ready, 503 otherwise, with the
+HealthReport as JSON.
+*/
+func (pm *ProjectInfrastructure) ReadinessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := pm.CheckHealth(r.Context())
+		w.Header().Set("Content-Type", "application/json")
+		if !report.Ready {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(report)
+	})
+}
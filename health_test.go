@@ -0,0 +1,182 @@
+package infrastructure
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+
+	"github.com/pkg/errors"
+
+	"github.com/just-lick-it/infrastructure/checks"
+)
+
+func TestHealthCheckDependencies(t *testing.T) {
+	pm, err := NewProjectInfrastructure(context.Background(),
+		WithLibraryMode(true),
+		WithLogOutput("writer"),
+		WithLogWriter(io.Discard),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pm.Shutdown()
+
+	var dbDown int32 = 1
+	db := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&dbDown) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer db.Close()
+	var apiRuns int32
+	registrations := []struct {
+		name      string
+		check     func(context.Context) error
+		dependsOn []string
+	}{
+		{"db", checks.HTTP(db.URL, http.StatusOK), nil},
+		{"cache", func(context.Context) error { return nil }, nil},
+		{"api", func(context.Context) error { atomic.AddInt32(&apiRuns, 1); return nil }, []string{"db", "cache"}},
+		{"worker", func(context.Context) error { return errors.New("queue full") }, []string{"api"}},
+	}
+	for _, r := range registrations {
+		if err := pm.RegisterHealthCheck(r.name, r.check, r.dependsOn...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := pm.RegisterHealthCheck("db", registrations[0].check); err == nil {
+		t.Error("registered db twice")
+	}
+	if err := pm.RegisterHealthCheck("web", registrations[1].check, "auth"); err == nil {
+		t.Error("registered a check depending on an unknown one")
+	}
+
+	// Every check depending on the db reports it as the root cause
+	report := pm.CheckHealth(context.Background())
+	if report.Ready || !reflect.DeepEqual(report.RootCauses, []string{"db"}) {
+		t.Fatalf("ready %v, root causes %v", report.Ready, report.RootCauses)
+	}
+	for _, name := range []string{"api", "worker"} {
+		if got := report.Checks[name].BlockedBy; !reflect.DeepEqual(got, []string{"db"}) {
+			t.Errorf("%s blocked by %v, want db", name, got)
+		}
+	}
+	if report.Checks["cache"].Error != "" || len(report.Checks["cache"].BlockedBy) > 0 {
+		t.Errorf("cache %+v, want healthy", report.Checks["cache"])
+	}
+	if apiRuns != 0 {
+		t.Error("api checked while the db is down")
+	}
+
+	// Once the db is back the next failure is the root cause
+	atomic.StoreInt32(&dbDown, 0)
+	report = pm.CheckHealth(context.Background())
+	if report.Ready || !reflect.DeepEqual(report.RootCauses, []string{"worker"}) || report.Checks["worker"].Error != "queue full" {
+		t.Fatalf("ready %v, root causes %v, worker %+v", report.Ready, report.RootCauses, report.Checks["worker"])
+	}
+	if apiRuns != 1 {
+		t.Errorf("api checked %d times, want 1", apiRuns)
+	}
+}
+
+func TestReadinessHandler(t *testing.T) {
+	pm, err := NewProjectInfrastructure(context.Background(),
+		WithLibraryMode(true),
+		WithLogOutput("writer"),
+		WithLogWriter(io.Discard),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pm.Shutdown()
+
+	var failing int32 = 1
+	pm.RegisterHealthCheck("db", func(context.Context) error {
+		if atomic.LoadInt32(&failing) == 1 {
+			return errors.New("connection refused")
+		}
+		return nil
+	})
+	pm.RegisterHealthCheck("api", func(context.Context) error { return nil }, "db")
+
+	srv := httptest.NewServer(pm.ReadinessHandler())
+	defer srv.Close()
+	dashboard := httptest.NewServer(pm.DashboardHandler())
+	defer dashboard.Close()
+
+	get := func(_url string) (int, HealthReport) {
+		t.Helper()
+		resp, err := http.Get(_url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var report HealthReport
+		if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode, report
+	}
+	if status, _ := get(srv.URL); status != http.StatusServiceUnavailable {
+		t.Errorf("status %d while the db fails", status)
+	}
+	// The debug endpoint serves the graph whatever the readiness
+	status, report := get(dashboard.URL + "/health")
+	if status != http.StatusOK || !reflect.DeepEqual(report.Checks["api"].DependsOn, []string{"db"}) {
+		t.Errorf("debug health: status %d, api %+v", status, report.Checks["api"])
+	}
+	atomic.StoreInt32(&failing, 0)
+	if status, report := get(srv.URL); status != http.StatusOK || !report.Ready {
+		t.Errorf("status %d, ready %v once the db is back", status, report.Ready)
+	}
+}
+
+func TestReadinessBreaker(t *testing.T) {
+	pm, err := NewProjectInfrastructure(context.Background(),
+		WithLibraryMode(true),
+		WithLogOutput("writer"),
+		WithLogWriter(io.Discard),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pm.Shutdown()
+
+	var open int32
+	if err := pm.RegisterReadinessBreaker("db breaker", func() bool { return atomic.LoadInt32(&open) == 1 }); err != nil {
+		t.Fatal(err)
+	}
+	pm.RegisterHealthCheck("orders", func(context.Context) error { return nil }, "db breaker")
+	srv := httptest.NewServer(pm.ReadinessHandler())
+	defer srv.Close()
+
+	tests := []struct {
+		open   int32
+		status int
+	}{
+		{0, http.StatusOK},
+		{1, http.StatusServiceUnavailable},
+		{0, http.StatusOK},
+	}
+	for _, tt := range tests {
+		atomic.StoreInt32(&open, tt.open)
+		resp, err := http.Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var report HealthReport
+		json.NewDecoder(resp.Body).Decode(&report)
+		resp.Body.Close()
+		if resp.StatusCode != tt.status {
+			t.Errorf("breaker open %d: status %d, want %d", tt.open, resp.StatusCode, tt.status)
+		}
+		if tt.open == 1 && !reflect.DeepEqual(report.Checks["orders"].BlockedBy, []string{"db breaker"}) {
+			t.Errorf("orders %+v, want blocked by the breaker", report.Checks["orders"])
+		}
+	}
+}
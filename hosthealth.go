@@ -0,0 +1,162 @@
+package infrastructure
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// A host health plugin, e.g. CPU temperature, disk SMART status or battery.
+type HostProbe interface {
+	Name() string
+	// Measure the host, values are reported as fields of the summary record.
+	Probe(ctx context.Context) (map[string]interface{}, error)
+}
+
+// Last result of a host probe.
+type HostProbeResult struct {
+	Time   time.Time
+	Values map[string]interface{}
+	Err    error
+}
+
+type hostHealth struct {
+	mu      sync.RWMutex
+	probes  []HostProbe
+	results map[string]HostProbeResult
+}
+
+// Add a probe run every host health interval, see WithHostHealthInterval.
+func (pm *ProjectInfrastructure) RegisterHostProbe(_probe HostProbe) {
+	pm.host.mu.Lock()
+	defer pm.host.mu.Unlock()
+	pm.host.probes = append(pm.host.probes, _probe)
+}
+
+// The last result of every probe, keyed by probe name.
+func (pm *ProjectInfrastructure) HostHealth() map[string]HostProbeResult {
+	pm.host.mu.RLock()
+	defer pm.host.mu.RUnlock()
+
+	results := make(map[string]HostProbeResult, len(pm.host.results))
+	for k, v := range pm.host.results {
+		results[k] = v
+	}
+	return results
+}
+
+// Run every probe and log one summary record per probe.
+func (pm *ProjectInfrastructure) probeHost(_ctx context.Context, _timeout time.Duration) {
+	pm.host.mu.RLock()
+	probes := append([]HostProbe(nil), pm.host.probes...)
+	pm.host.mu.RUnlock()
+
+	for _, p := range probes {
+		ctx, cancel := context.WithTimeout(_ctx, _timeout)
+		values, err := p.Probe(ctx)
+		cancel()
+
+		pm.host.mu.Lock()
+		if pm.host.results == nil {
+			pm.host.results = make(map[string]HostProbeResult)
+		}
+		pm.host.results[p.Name()] = HostProbeResult{Time: time.Now(), Values: values, Err: err}
+		pm.host.mu.Unlock()
+
+		if err != nil {
+			pm.logOutput("host", "warn", errors.Errorf("host probe %s failed: %v", p.Name(), err), false, nil)
+			continue
+		}
+		fields := logrus.Fields{"probe": p.Name()}
+		for k, v := range values {
+			fields[k] = v
+		}
+		pm.logOutput("host", "info", errors.Errorf("host probe %s", p.Name()), false, fields)
+	}
+}
+
+func (pm *ProjectInfrastructure) monitorHost(_interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(_interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				pm.probeHost(pm.cancel, _interval)
+			case <-pm.cancel.Done():
+				return
+			}
+		}
+	}()
+}
+
+// CPU and board temperatures from the Linux thermal zones, in degrees Celsius.
+type ThermalProbe struct{}
+
+func (ThermalProbe) Name() string {
+	return "thermal"
+}
+
+func (ThermalProbe) Probe(_ctx context.Context) (map[string]interface{}, error) {
+	zones, _ := filepath.Glob("/sys/class/thermal/thermal_zone*")
+	if len(zones) == 0 {
+		return nil, errors.New("no thermal zone found")
+	}
+
+	values := make(map[string]interface{})
+	for _, zone := range zones {
+		milli, err := readSysfsInt(filepath.Join(zone, "temp"))
+		if err != nil {
+			continue
+		}
+		name := filepath.Base(zone)
+		if kind, err := os.ReadFile(filepath.Join(zone, "type")); err == nil {
+			name = strings.TrimSpace(string(kind))
+		}
+		values[name+"_celsius"] = float64(milli) / 1000
+	}
+	return values, nil
+}
+
+// Charge level of the batteries reported by Linux power supplies, in percent.
+type BatteryProbe struct{}
+
+func (BatteryProbe) Name() string {
+	return "battery"
+}
+
+func (BatteryProbe) Probe(_ctx context.Context) (map[string]interface{}, error) {
+	supplies, _ := filepath.Glob("/sys/class/power_supply/BAT*")
+	if len(supplies) == 0 {
+		return nil, errors.New("no battery found")
+	}
+
+	values := make(map[string]interface{})
+	for _, supply := range supplies {
+		capacity, err := readSysfsInt(filepath.Join(supply, "capacity"))
+		if err != nil {
+			continue
+		}
+		name := strings.ToLower(filepath.Base(supply))
+		values[name+"_percent"] = capacity
+		if status, err := os.ReadFile(filepath.Join(supply, "status")); err == nil {
+			values[name+"_status"] = strings.TrimSpace(string(status))
+		}
+	}
+	return values, nil
+}
+
+func readSysfsInt(_path string) (int64, error) {
+	data, err := os.ReadFile(_path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+}
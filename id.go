@@ -0,0 +1,68 @@
+package infrastructure
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+)
+
+type requestIDKey struct{}
+
+// A random UUID version 4, the default ID generator.
+func newUUID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// A new request ID from the generator set with WithIDGenerator.
+func (pm *ProjectInfrastructure) NewID() string {
+	if pm.options.IDGenerator != nil {
+		return pm.options.IDGenerator()
+	}
+	return newUUID()
+}
+
+// Return a copy of the context carrying a new request ID, and the ID. The ID
+// already carried by the context is kept.
+func (pm *ProjectInfrastructure) ContextWithRequestID(_ctx context.Context) (context.Context, string) {
+	if _ctx == nil {
+		_ctx = context.Background()
+	}
+	if id := RequestID(_ctx); id != "" {
+		return _ctx, id
+	}
+	id := pm.NewID()
+	return context.WithValue(_ctx, requestIDKey{}, id), id
+}
+
+// The request ID carried by the context, empty when there is none.
+func RequestID(_ctx context.Context) string {
+	if _ctx == nil {
+		return ""
+	}
+	id, _ := _ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+type traceIDKey struct{}
+
+// Return a copy of the context carrying the trace ID, e.g. the one of an
+// incoming request header, logged by ErrorTransmitCtx.
+func WithTraceID(_ctx context.Context, _id string) context.Context {
+	if _ctx == nil {
+		_ctx = context.Background()
+	}
+	return context.WithValue(_ctx, traceIDKey{}, _id)
+}
+
+// The trace ID carried by the context, empty when there is none.
+func TraceID(_ctx context.Context) string {
+	if _ctx == nil {
+		return ""
+	}
+	id, _ := _ctx.Value(traceIDKey{}).(string)
+	return id
+}
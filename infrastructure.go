@@ -3,16 +3,19 @@ package infrastructure
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"sync"
 	"time"
 
-	filerotatelogs "github.com/lestrrat-go/file-rotatelogs"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 )
 
-var supportLogTypes = []string{"debug", "info", "warn", "error"}
+// Built-in severities, logTypes adds the registered ones.
+var supportLogTypes = []string{"debug", "info", "warn", "error", "fatal", "panic"}
+
+var supportLogFormats = []string{"text", "json", "logfmt", "ecs"}
 
 const (
 	green string = "\x1b[97;104m"
@@ -22,6 +25,12 @@ const (
 type ProjectInfrastructure struct {
 	options *ProjectInfrastructureOptions
 
+	// Logger of this instance, the global logrus logger is left untouched
+	logger *logrus.Logger
+
+	// Layer each effective option value came from
+	optionSources map[string]string
+
 	// Context for controlling resource release of ProjectInfrastructure
 	cancel     context.Context
 	cancelFunc context.CancelFunc
@@ -33,6 +42,120 @@ type ProjectInfrastructure struct {
 
 	// Release of project resources
 	releaseFunc func() error
+
+	// Global log level, per-module levels and level patterns, as severity ranks
+	levelMu sync.RWMutex
+	level   int
+	// Incremented on every change of the global level
+	levelGen      uint64
+	moduleLevels  map[string]int
+	levelPatterns []levelPattern
+
+	// Records are emitted as structured entries, see structuredOutput
+	structured bool
+
+	// Human-friendly console output for local development
+	devMode bool
+
+	// Attach the origin package/function of errors as fields
+	errorOrigin bool
+
+	// Acknowledged errors to downgrade or suppress, keyed by fingerprint
+	knownIssues map[string]KnownIssue
+
+	// First-seen tracking of error fingerprints, nil when disabled
+	occurrences *occurrenceTracker
+
+	// Per-module error rate baselines, nil when disabled
+	anomalies *anomalyDetector
+
+	// Writers the logs end up in, out is the logger output and the others
+	// are written by hooks. Colors are only used when out is stdout.
+	out        io.Writer
+	outputs    []logOutputWriter
+	colored    bool
+	colorTheme map[string]string
+
+	// Live subscribers of emitted records
+	stream logBroadcaster
+
+	// Last records for TailLogs, nil when disabled
+	ring *ringBuffer
+
+	// Emitted records per module and severity
+	stats recordStats
+
+	// Counters restored from the previous runs, nil when not persisted
+	counters *persistentCounters
+
+	// Pipe to the supervising process, nil unless forwarding to it
+	parent *parentForwarder
+
+	// Free space check of the log directory and the reserved file of the last
+	// errors, nil when disabled
+	diskGuard *diskGuard
+	emergency *emergencyLog
+
+	// Observability providers flushed on release, then the files closed
+	flushMu  sync.Mutex
+	flushers []flusher
+	closers  []flusher
+
+	// Lifecycle state
+	stateMu  sync.RWMutex
+	state    State
+	exitCode int
+	started  time.Time
+
+	// Host health probes and their last results
+	host hostHealth
+
+	// Health checks of the readiness and their dependencies
+	health healthChecks
+
+	// Components started on registration, stopped during shutdown
+	components components
+
+	// Temp directories removed during shutdown
+	temp tempDirs
+
+	// Remediation run on matching records
+	recoveries recoveries
+
+	// Resources closed by tiers during shutdown
+	releaseHooks releaseHooks
+
+	// Problems of the infrastructure, see InternalErrors
+	internalErrors chan error
+
+	// Error reporting, nil when disabled
+	sentry *sentryReporter
+
+	// Writer of the file output, shared with the remote fallback
+	logFile io.Writer
+
+	// Sink of operator actions, nil to log them with the records
+	operatorLog *operatorLog
+
+	// Sink of AuditTransmit, nil when not configured
+	auditLog *auditLog
+
+	// Modules muted or solo at runtime
+	toggles moduleToggles
+
+	// Masks of sensitive data, nil when disabled
+	redactor *redactor
+
+	// Last printed stack per fingerprint, nil when disabled
+	stackDiffs *stackDiffs
+
+	// Per message rate limit, duplicate window and per module debug budget,
+	// nil when disabled
+	sampler *sampler
+	deduper *deduper
+	budgets *budgets
+	// Shared by the outputs and the archive shipping records off the host
+	shipping *bandwidthLimiter
 }
 
 func NewProjectInfrastructure(_ctx context.Context, _optionFuncs ...OptionFunc) (*ProjectInfrastructure, error) {
@@ -41,29 +164,174 @@ func NewProjectInfrastructure(_ctx context.Context, _optionFuncs ...OptionFunc)
 		ctx = _ctx
 	}
 
-	options := DefaultOptions()
-	for _, optFunc := range _optionFuncs {
-		optFunc(&options)
+	options, sources, err := resolveOptions(_optionFuncs)
+	if err != nil {
+		return nil, err
 	}
 
 	PM := &ProjectInfrastructure{
-		options:     &options,
-		releaseFunc: options.ReleaseFunc,
+		options:        &options,
+		logger:         logrus.New(),
+		optionSources:  sources,
+		releaseFunc:    options.ReleaseFunc,
+		errorOrigin:    options.ErrorOrigin,
+		internalErrors: make(chan error, options.ErrChanLen),
+		state:          StateStarting,
+		started:        time.Now(),
+		shipping:       newBandwidthLimiter(options.ShippingBandwidth),
+	}
+	PM.cancel, PM.cancelFunc = context.WithCancel(ctx)
+	PM.GoroutineCancel, PM.goroutineCancelFunc = context.WithCancel(ctx)
+	// Stop the goroutines and listeners started before a failing step.
+	started := false
+	defer func() {
+		if !started {
+			PM.goroutineCancelFunc()
+			PM.cancelFunc()
+			PM.closeResources()
+		}
+	}()
+	if options.ParentLogForwarding {
+		if PM.parent, err = openParentForwarder(); err != nil {
+			return nil, err
+		}
+		// The parent owns the files, stdout is left for a broken pipe.
+		if PM.parent != nil {
+			options.LogOut = "stdout"
+		}
 	}
 	if err := PM.initLogrus(options); err != nil {
 		return nil, err
 	}
-	PM.cancel, PM.cancelFunc = context.WithCancel(ctx)
-	PM.GoroutineCancel, PM.goroutineCancelFunc = context.WithCancel(ctx)
+	if len(options.RedactPatterns) > 0 || len(options.RedactKeys) > 0 {
+		if PM.redactor, err = newRedactor(options); err != nil {
+			return nil, err
+		}
+	}
+	if options.KnownIssuesFile != "" {
+		issues, err := loadKnownIssues(options.KnownIssuesFile)
+		if err != nil {
+			return nil, err
+		}
+		PM.knownIssues = issues
+	}
+	if options.LogRingBuffer > 0 {
+		PM.ring = newRingBuffer(options.LogRingBuffer)
+	}
+	if options.StackDiff {
+		PM.stackDiffs = newStackDiffs()
+	}
+	if options.FirstOccurrenceWindow > 0 {
+		PM.occurrences = newOccurrenceTracker(options.FirstOccurrenceWindow)
+	}
+	if options.ConfigStatePath != "" {
+		if err := PM.detectConfigDrift(options); err != nil {
+			return nil, err
+		}
+	}
+
+	PM.sweepTempDirs()
+
+	if options.ClockDriftServer != "" && options.ClockDriftInterval > 0 {
+		PM.monitorClockDrift(options.ClockDriftServer, options.ClockDriftInterval, options.ClockDriftThreshold)
+	}
+
+	if options.OperatorLogPath != "" {
+		if PM.operatorLog, err = openOperatorLog(options.OperatorLogPath); err != nil {
+			return nil, err
+		}
+		PM.registerCloser("operator log", PM.operatorLog.close)
+	}
+	if options.AuditLogPath != "" || options.AuditWriter != nil {
+		if PM.auditLog, err = openAuditLog(options); err != nil {
+			return nil, err
+		}
+		PM.registerCloser("audit log", PM.auditLog.close)
+	}
+	if options.SentryDSN != "" {
+		if err := PM.initSentry(options); err != nil {
+			return nil, err
+		}
+	}
+	if options.DedupeWindow > 0 {
+		PM.deduper = newDeduper(options.DedupeWindow)
+	}
+	if len(options.ModuleBudgets) > 0 {
+		PM.budgets = newBudgets(options.ModuleBudgets)
+	}
+	if options.SampleRate > 0 {
+		PM.sampler = newSampler(options.SampleRate)
+		PM.reportSuppressed(options.SampleSummaryInterval)
+	}
+
+	if options.HostHealthInterval > 0 {
+		PM.monitorHost(options.HostHealthInterval)
+	}
+	if options.CounterStore != nil {
+		if err := PM.restoreCounters(options.CounterStore, options.CounterSaveInterval); err != nil {
+			return nil, err
+		}
+	}
+	if options.LogArchiver != nil {
+		if err := PM.archiveLogs(options); err != nil {
+			return nil, err
+		}
+	}
+	if options.DiskMinFree > 0 {
+		PM.diskGuard = &diskGuard{dir: logDir(options), minFree: options.DiskMinFree}
+		PM.guardDisk(options.DiskGuardInterval)
+	}
+	if options.EmergencyLogPath != "" {
+		if PM.emergency, err = openEmergencyLog(options.EmergencyLogPath, options.EmergencyLogEntries); err != nil {
+			return nil, err
+		}
+		PM.registerCloser("emergency log", PM.emergency.close)
+	}
+	if options.AnomalyInterval > 0 {
+		PM.anomalies = newAnomalyDetector(options.AnomalyThreshold)
+		PM.detectAnomalies(options.AnomalyInterval)
+	}
+
+	if len(options.ShutdownSignals) > 0 && !options.LibraryMode {
+		PM.handleSignals(options)
+	}
+	if options.LogExternalRotation && !options.LibraryMode {
+		PM.handleReopenSignal()
+	}
+
+	if options.SelfTest {
+		if err := PM.SelfTest(); err != nil {
+			return nil, err
+		}
+	}
+
+	// The sockets accept commands, they are served once nothing can fail
+	// but each other.
+	if options.AdminSocket != "" {
+		if err := PM.startAdminSocket(options.AdminSocket); err != nil {
+			return nil, err
+		}
+	}
+	if options.IngestSocket != "" {
+		if err := PM.startIngestSocket(options.IngestSocket); err != nil {
+			return nil, err
+		}
+	}
+	PM.setState(StateRunning)
+	started = true
 	return PM, nil
 }
 
-// Release resources.
-func (pm *ProjectInfrastructure) ResourceRelease() {
-	pm.releaseFunc()
+// The logger records are written to, e.g. to register logrus hooks.
+func (pm *ProjectInfrastructure) Logger() *logrus.Logger {
+	return pm.logger
+}
 
-	pm.goroutineCancelFunc()
-	pm.WaitGroup.Wait()
+// Release resources. Calling it again once released only logs a warning.
+func (pm *ProjectInfrastructure) ResourceRelease() {
+	if err := pm.Shutdown(); err != nil {
+		pm.logOutput("infra", "warn", err, false, nil)
+	}
 }
 
 /*
@@ -71,53 +339,171 @@ Transmit the error chain to the exception handling module
 
 @module: project module name
 
-@severity: log level <debug/info/warn/error>
+@severity: log level <debug/info/warn/error/fatal/panic> or one added with RegisterSeverity, fatal releases resources and exits like exit_after_print, panic releases resources and panics with the error
 
 @err:	final error <error>
 
-@exit_after_print: exit main program after printing the exception log <true/false>
+@exit_after_print: release resources and exit main program after printing the exception log, in library mode the exit is left to the exit handler <true/false>
 
 @print_stack: print error chain, default severity is error <true/false>
 */
 func (pm *ProjectInfrastructure) ErrorTransmit(_module, _severity string, _err error, _exit_after_print, _print_stack bool) {
+	pm.transmit(_module, _severity, _err, nil, _exit_after_print, _print_stack)
+}
+
+/*
+Transmit the error chain like ErrorTransmit, attaching structured fields
+
+@fields: key-value pairs added to the log entry, e.g. request ID, user ID, host
+*/
+func (pm *ProjectInfrastructure) ErrorTransmitFields(_module, _severity string, _err error, _fields map[string]interface{}, _exit_after_print, _print_stack bool) {
+	pm.transmit(_module, _severity, _err, _fields, _exit_after_print, _print_stack)
+}
+
+/*
+Transmit the error chain like ErrorTransmit, attaching the IDs carried by the context
+
+@ctx: context of the request, its trace ID (WithTraceID) and request ID (ContextWithRequestID) become the "trace_id" and "request_id" fields
+*/
+func (pm *ProjectInfrastructure) ErrorTransmitCtx(_ctx context.Context, _module, _severity string, _err error, _exit_after_print, _print_stack bool) {
+	var fields logrus.Fields
+	if id := TraceID(_ctx); id != "" {
+		fields = logrus.Fields{"trace_id": id}
+	}
+	if id := RequestID(_ctx); id != "" {
+		if fields == nil {
+			fields = make(logrus.Fields, 1)
+		}
+		fields["request_id"] = id
+	}
+	pm.transmit(_module, _severity, _err, fields, _exit_after_print, _print_stack)
+}
+
+func (pm *ProjectInfrastructure) transmit(_module, _severity string, _err error, _fields logrus.Fields, _exit_after_print, _print_stack bool) {
+	// Registered first to panic after the recovery below.
+	raise := false
 	defer func() {
+		if raise {
+			panic(_err)
+		}
+	}()
+	defer func() {
+		// The panic may come from the log output, bypass it.
 		if r := recover(); r != nil {
-			logrus.Errorf("%+v", r)
+			pm.logger.WithFields(panicFields(r)).Error("panic recovered in ErrorTransmit")
 		}
 	}()
 
-	pm.WaitGroup.Add(1)
-	defer pm.WaitGroup.Done()
+	// Records are still logged during shutdown, only without tracking.
+	tracked := pm.addWork("ErrorTransmit()") == nil
+	defer func() {
+		if tracked {
+			pm.WaitGroup.Done()
+		}
+	}()
 
-	if _exit_after_print {
-		pm.logOutput(_module, _severity, _err, _print_stack)
-		pm.WaitGroup.Done()
+	exit := _exit_after_print || _severity == "fatal"
+	if exit || _severity == "panic" {
+		pm.logOutput(_module, _severity, _err, _print_stack, _fields)
+		pm.mirrorFatal(_module, _err, _print_stack)
+		pm.writeTerminationLog(_module, _err, _print_stack)
+		if tracked {
+			pm.WaitGroup.Done()
+			tracked = false
+		}
 		pm.ResourceRelease()
-		os.Exit(1)
+		if !exit {
+			raise = true
+			return
+		}
+		pm.exit(1)
+		return
+	}
+	pm.logOutput(_module, _severity, _err, _print_stack, _fields)
+	pm.triggerRecovery(_module, _err)
+}
+
+// Write the record causing the exit to stderr, without colors, so container
+// runtimes report the reason whatever the log outputs are.
+func (pm *ProjectInfrastructure) mirrorFatal(_module string, _err error, _print_stack bool) {
+	if !pm.options.FatalToStderr {
+		return
 	}
-	pm.logOutput(_module, _severity, _err, _print_stack)
+	if pm.redactor != nil {
+		_err = pm.redactor.error(_err)
+	}
+	ts := pm.timestamp(time.Now())
+	if _print_stack {
+		fmt.Fprintf(os.Stderr, formatStackHeader(ts, _module, "")+"\n%+v\n", _err)
+		return
+	}
+	fmt.Fprintln(os.Stderr, formatLogMessage(ts, errors.Cause(_err), _module, ""))
+}
+
+// Write the record causing the exit to the termination log, truncated to
+// the size Kubernetes reads from it.
+func (pm *ProjectInfrastructure) writeTerminationLog(_module string, _err error, _print_stack bool) {
+	if pm.options.TerminationLogPath == "" {
+		return
+	}
+	if pm.redactor != nil {
+		_err = pm.redactor.error(_err)
+	}
+	msg := fmt.Sprintf("%s: %s", _module, _err.Error())
+	if _print_stack {
+		msg += fmt.Sprintf("\n%+v", _err)
+	}
+	if len(msg) > terminationLogMaxSize {
+		msg = msg[:terminationLogMaxSize]
+	}
+	if err := os.WriteFile(pm.options.TerminationLogPath, []byte(msg), 0o644); err != nil {
+		pm.logger.Warnf("write termination log %s: %v", pm.options.TerminationLogPath, err)
+	}
+}
+
+// Exit the process, or hand the exit code to the exit handler. In library
+// mode without an exit handler the call returns.
+func (pm *ProjectInfrastructure) exit(_code int) {
+	if _code == 0 {
+		_code = pm.ExitCode()
+	}
+	switch {
+	case pm.options.ExitHandler != nil:
+		pm.options.ExitHandler(_code)
+	case pm.options.LibraryMode:
+	default:
+		os.Exit(_code)
+	}
+}
+
+// Timestamp of the text format messages.
+func (pm *ProjectInfrastructure) timestamp(_t time.Time) string {
+	return formatTimestamp(_t, pm.options.TimestampFormat, textTimestampFormat, pm.options.TimestampUTC)
 }
 
 // Format error information.
-func (pm *ProjectInfrastructure) logFormat(_err error, _module string) string {
+func (pm *ProjectInfrastructure) logFormat(_err error, _module, _severity string) string {
+	return formatLogMessage(pm.timestamp(time.Now()), _err, _module, pm.moduleColor(_severity))
+}
+
+func formatLogMessage(_ts string, _err error, _module, _color string) string {
 	var log string
 
 	if len(_module) > 10 {
 		_module = _module[:10]
 	}
 
-	switch pm.options.LogOut {
-	case "stdout":
+	if _color != "" {
 		log = fmt.Sprintf("%v %s %-10s %s %+v",
-			time.Now().Format("2006-01-02 15:04:05"),
-			green,
+			_ts,
+			_color,
 			_module,
 			reset,
 			_err.Error(),
 		)
-	case "file":
+	} else {
 		log = fmt.Sprintf("%v %-10s %+v",
-			time.Now().Format("2006-01-02 15:04:05"),
+			_ts,
 			_module,
 			_err.Error(),
 		)
@@ -126,24 +512,27 @@ func (pm *ProjectInfrastructure) logFormat(_err error, _module string) string {
 }
 
 // Format error chain information.
-func (pm *ProjectInfrastructure) errorStackMsg(_module string) string {
+func (pm *ProjectInfrastructure) errorStackMsg(_module, _severity string) string {
+	return formatStackHeader(pm.timestamp(time.Now()), _module, pm.moduleColor(_severity))
+}
+
+func formatStackHeader(_ts string, _module, _color string) string {
 	var log string
 
 	if len(_module) > 10 {
 		_module = _module[:10]
 	}
 
-	switch pm.options.LogOut {
-	case "stdout":
+	if _color != "" {
 		log = fmt.Sprintf("%v %s %-10s %s",
-			time.Now().Format("2006-01-02 15:04:05"),
-			green,
+			_ts,
+			_color,
 			_module,
 			reset,
 		)
-	case "file":
+	} else {
 		log = fmt.Sprintf("%v %-10s",
-			time.Now().Format("2006-01-02 15:04:05"),
+			_ts,
 			_module,
 		)
 	}
@@ -151,96 +540,331 @@ func (pm *ProjectInfrastructure) errorStackMsg(_module string) string {
 }
 
 // Print the log and determine whether to print the complete error chain.
-func (pm *ProjectInfrastructure) logOutput(_module, _severity string, _err error, _print_stack bool) {
+func (pm *ProjectInfrastructure) logOutput(_module, _severity string, _err error, _print_stack bool, _fields logrus.Fields) {
+	_severity, suppressed := pm.applyKnownIssues(_module, _severity, _err)
+	if suppressed {
+		return
+	}
+	if !pm.moduleAllowed(_module) || pm.moduleMuted(_module) || !pm.levelEnabled(_module, _severity) {
+		return
+	}
+	if pm.diskGuard != nil && !pm.diskGuard.allow(_severity) {
+		return
+	}
+	// Counted before deduplication and sampling drop the repeats of a burst.
+	if pm.anomalies != nil {
+		pm.anomalies.observe(_module, _severity)
+	}
+	if pm.deduper != nil && !pm.dedupe(_module, _severity, _err) {
+		return
+	}
+	if pm.sampler != nil && !pm.sampler.allow(_module, _severity, _err, time.Now()) {
+		return
+	}
+	if pm.budgets != nil && _severity == "debug" && !pm.withinBudget(_module, _err, _print_stack) {
+		return
+	}
+	// Matching and recovery above see the original error, only what the
+	// outputs receive is masked.
+	if pm.redactor != nil {
+		_err = pm.redactor.error(_err)
+		_fields = pm.redactor.fields(_fields)
+	}
+	if _print_stack && pm.stackDiffs != nil {
+		var refs logrus.Fields
+		_err, refs = pm.diffStack(_module, _err)
+		fields := make(logrus.Fields, len(_fields)+len(refs))
+		for k, v := range _fields {
+			fields[k] = v
+		}
+		for k, v := range refs {
+			fields[k] = v
+		}
+		_fields = fields
+	}
+	entry := pm.logEntry(_module, _err, _fields)
+	pm.stats.add(_module, _severity)
+	if pm.sentry != nil {
+		pm.sentry.capture(_module, _severity, _err, entry.Data)
+	}
+	emergency := pm.emergency != nil && severityAtLeast(_severity, logrus.ErrorLevel)
+	if pm.ring != nil || pm.stream.active() || pm.parent != nil || emergency {
+		record := newLogRecord(entry, _module, _severity, _err, _print_stack)
+		if emergency {
+			if err := pm.emergency.add(record); err != nil {
+				pm.internalError(errors.Errorf("emergency log: %v", err))
+			}
+		}
+		if pm.ring != nil {
+			pm.ring.add(record)
+		}
+		if pm.stream.active() {
+			pm.stream.publish(record)
+		}
+		// The parent writes the record, its own outputs only take over once
+		// the pipe is gone.
+		if pm.parent != nil && pm.parent.forward(record) == nil {
+			return
+		}
+	}
+	// Registered severities are written at their logrus level, under their name.
+	if isCustomSeverity(_severity) {
+		entry = entry.WithField("severity", _severity)
+	}
+	if len(pm.options.OutputFormats) > 0 {
+		record := Entry{Module: _module, Severity: _severity, Err: _err, PrintStack: _print_stack}
+		entry = entry.WithContext(context.WithValue(entry.Context, recordKey{}, record))
+	}
+	if pm.structured {
+		pm.structuredOutput(entry, _module, _severity, _err, _print_stack)
+		return
+	}
+
 	switch _severity {
 	case "debug":
 		if _print_stack {
-			logrus.Debugf(pm.errorStackMsg(_module)+"\n%+v", _err)
+			entry.Debugf(pm.errorStackMsg(_module, _severity)+"\n%+v", _err)
 		} else {
-			logrus.Debug(
+			entry.Debug(
 				pm.logFormat(
 					errors.Cause(_err),
 					_module,
+					_severity,
 				),
 			)
 		}
 	case "info":
 		if _print_stack {
-			logrus.Infof(pm.errorStackMsg(_module)+"\n%+v", _err)
+			entry.Infof(pm.errorStackMsg(_module, _severity)+"\n%+v", _err)
 		} else {
-			logrus.Info(
+			entry.Info(
 				pm.logFormat(
 					errors.Cause(_err),
 					_module,
+					_severity,
 				),
 			)
 		}
 	case "warn":
 		if _print_stack {
-			logrus.Warnf(pm.errorStackMsg(_module)+"\n%+v", _err)
+			entry.Warnf(pm.errorStackMsg(_module, _severity)+"\n%+v", _err)
 		} else {
-			logrus.Warn(
+			entry.Warn(
 				pm.logFormat(
 					errors.Cause(_err),
 					_module,
+					_severity,
 				),
 			)
 		}
 	case "error":
 		if _print_stack {
-			logrus.Errorf(pm.errorStackMsg(_module)+"\n%+v", _err)
+			entry.Errorf(pm.errorStackMsg(_module, _severity)+"\n%+v", _err)
+		} else {
+			entry.Error(
+				pm.logFormat(
+					errors.Cause(_err),
+					_module,
+					_severity,
+				),
+			)
+		}
+	case "fatal", "panic":
+		level, _ := parseLogLevel(_severity)
+		if _print_stack {
+			logAt(entry, level, fmt.Sprintf(pm.errorStackMsg(_module, _severity)+"\n%+v", _err))
 		} else {
-			logrus.Error(
+			logAt(entry, level,
 				pm.logFormat(
 					errors.Cause(_err),
 					_module,
+					_severity,
 				),
 			)
 		}
 	default:
-		logrus.Error(fmt.Sprintf("[unsupport error type: %s]", _severity) +
+		if level, err := parseLogLevel(_severity); err == nil {
+			if _print_stack {
+				logAt(entry, level, fmt.Sprintf(pm.errorStackMsg(_module, _severity)+"\n%+v", _err))
+			} else {
+				logAt(entry, level,
+					pm.logFormat(
+						errors.Cause(_err),
+						_module,
+						_severity,
+					),
+				)
+			}
+			return
+		}
+		entry.Error(fmt.Sprintf("[unsupport error type: %s]", _severity) +
 			pm.logFormat(
 				errors.Cause(_err),
 				_module,
+				_severity,
 			),
 		)
 	}
 }
 
+// Build the log entry of a record with its structured fields.
+func (pm *ProjectInfrastructure) logEntry(_module string, _err error, _fields logrus.Fields) *logrus.Entry {
+	fields := staticFields(*pm.options)
+	for k, v := range _fields {
+		fields[k] = v
+	}
+	if pm.errorOrigin {
+		if pkg, fn, ok := errorOrigin(_err); ok {
+			fields["origin_pkg"] = pkg
+			fields["origin_func"] = fn
+		}
+	}
+	if pm.options.ErrorCaller {
+		if caller, ok := callerLocation(); ok {
+			fields["caller"] = caller
+		}
+	}
+	if pm.occurrences != nil {
+		fp := Fingerprint(_module, _err)
+		fields["fingerprint"] = fp
+		if pm.occurrences.observe(fp, time.Now()) {
+			fields["first_occurrence"] = true
+		}
+	}
+	return pm.logger.WithFields(fields).WithContext(context.WithValue(context.Background(), moduleKey{}, _module))
+}
+
+func newLogRecord(_entry *logrus.Entry, _module, _severity string, _err error, _print_stack bool) LogRecord {
+	record := LogRecord{
+		Time:     time.Now(),
+		Module:   _module,
+		Severity: _severity,
+		Message:  errors.Cause(_err).Error(),
+		Fields:   _entry.Data,
+	}
+	if _print_stack {
+		record.Message = fmt.Sprintf("%+v", _err)
+	}
+	return record
+}
+
 func (pm *ProjectInfrastructure) initLogrus(_opts ProjectInfrastructureOptions) error {
-	logrus.SetFormatter(&logrus.TextFormatter{
-		DisableTimestamp: true,
-	})
-
-	switch _opts.LogOut {
-	case "stdout":
-		logrus.SetOutput(os.Stdout)
-	case "file":
-		w, err := filerotatelogs.New(
-			_opts.LogPath,
-			filerotatelogs.WithRotationCount(uint(_opts.LogMaxFileNum)),
-			filerotatelogs.WithRotationSize(int64(_opts.LogMaxFileSize)),
-		)
+	if err := validSchemaVersion(_opts.SchemaVersion); err != nil {
+		return err
+	}
+	switch _opts.LogFormat {
+	case "text":
+		pm.logger.SetFormatter(&logrus.TextFormatter{
+			DisableTimestamp: true,
+		})
+	case "json":
+		pm.structured = true
+		pm.logger.SetFormatter(&jsonFormatter{layout: _opts.TimestampFormat, utc: _opts.TimestampUTC, schema: _opts.SchemaVersion})
+	case "logfmt":
+		pm.structured = true
+		pm.logger.SetFormatter(&logfmtFormatter{layout: _opts.TimestampFormat, utc: _opts.TimestampUTC, schema: _opts.SchemaVersion})
+	case "ecs":
+		pm.structured = true
+		pm.logger.SetFormatter(newECSFormatter(_opts))
+	default:
+		return errors.Errorf("invalid log format %s, valid values are %s", _opts.LogFormat, supportLogFormats)
+	}
+
+	for _, name := range parseLogOutputs(_opts.LogOut) {
+		w, err := pm.openLogOutput(name, _opts)
 		if err != nil {
 			return err
 		}
-		logrus.SetOutput(w)
-	default:
-		logrus.Warnf("unknown log output type: %s, use default stdout", _opts.LogOut)
-		logrus.SetOutput(os.Stdout)
+		if w == nil {
+			err := errors.Errorf("unknown log output type: %s, valid values are %s", name, supportLogOutputs)
+			if _opts.StrictOptions {
+				return err
+			}
+			pm.logger.Warn(err)
+			pm.internalError(err)
+			continue
+		}
+		pm.outputs = append(pm.outputs, logOutputWriter{name: name, w: w})
+	}
+	if len(pm.outputs) == 0 {
+		err := errors.Errorf("no valid log output in %s, use default stdout", _opts.LogOut)
+		if _opts.StrictOptions {
+			return err
+		}
+		pm.logger.Warn(err)
+		pm.internalError(err)
+		pm.outputs = []logOutputWriter{{name: "stdout", w: os.Stdout}}
+	}
+	outputLevels := make(map[string][]logrus.Level, len(_opts.OutputSeverities))
+	for name, severities := range _opts.OutputSeverities {
+		for _, severity := range severities {
+			level, err := parseLogLevel(severity)
+			if err != nil {
+				return errors.Wrapf(err, "output %s", name)
+			}
+			outputLevels[name] = append(outputLevels[name], level)
+		}
+	}
+	formats := make(map[string]*outputFormat, len(_opts.OutputFormats))
+	for name, format := range _opts.OutputFormats {
+		f, err := newOutputFormat(_opts, format)
+		if err != nil {
+			return errors.Wrapf(err, "output %s", name)
+		}
+		formats[name] = f
+	}
+	for name, severity := range _opts.OutputLevels {
+		if _, ok := _opts.OutputSeverities[name]; ok {
+			return errors.Errorf("output %s has both routed severities and a minimum severity", name)
+		}
+		floor, err := parseLogLevel(severity)
+		if err != nil {
+			return errors.Wrapf(err, "output %s", name)
+		}
+		outputLevels[name] = levelsAtLeast(floor)
+	}
+	// The logger writes to the first plain output, the others are hooks.
+	// Hooks filter by severity or format again, with routing every output is one.
+	pm.out = io.Discard
+	var hooked []logOutputWriter
+	for _, o := range pm.outputs {
+		_, leveled := o.w.(leveledWriter)
+		if leveled || formats[o.name] != nil || pm.out != io.Discard || len(outputLevels) > 0 {
+			hooked = append(hooked, o)
+			continue
+		}
+		pm.out = o.w
+	}
+	stdout := pm.outputs[0].name == "stdout"
+	colors, err := colorEnabled(_opts.LogColor, os.Stdout)
+	if err != nil {
+		return err
+	}
+	pm.colored = stdout && colors
+	if pm.colored {
+		if pm.colorTheme, err = parseColorTheme(_opts.LogColors); err != nil {
+			return err
+		}
 	}
 
-	switch _opts.LogLevel {
-	case "debug":
-		logrus.SetLevel(logrus.DebugLevel)
-	case "info":
-		logrus.SetLevel(logrus.InfoLevel)
-	case "warn":
-		logrus.SetLevel(logrus.WarnLevel)
-	case "error":
-		logrus.SetLevel(logrus.ErrorLevel)
-	default:
-		return errors.Errorf("invalid log level %s, valid values are %s", _opts.LogLevel, supportLogTypes)
+	if _opts.DevMode && len(pm.outputs) == 1 && stdout {
+		pm.structured = true
+		pm.devMode = true
+		pm.logger.SetFormatter(&devFormatter{start: time.Now(), colored: pm.colored})
+	}
+
+	pm.logger.SetOutput(pm.out)
+	for _, o := range hooked {
+		pm.logger.AddHook(&outputHook{
+			w:           o.w,
+			levels:      outputLevels[o.name],
+			stripColors: pm.colored && o.name != "stdout",
+			format:      formats[o.name],
+		})
+	}
+	for _, h := range _opts.LogrusHooks {
+		pm.logger.AddHook(h)
 	}
-	return nil
+
+	return pm.initLevels(_opts)
 }
@@ -0,0 +1,74 @@
+// Package infrastructuretest compares log output of a ProjectInfrastructure
+// with golden files, routes it through t.Log and observes its shutdown
+// ordering.
+package infrastructuretest
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/just-lick-it/infrastructure"
+)
+
+// Set to regenerate the golden files instead of comparing with them.
+const UpdateEnv = "INFRA_UPDATE_GOLDEN"
+
+var (
+	// Text and dev mode "2006-01-02 15:04:05", JSON "2006-01-02T15:04:05.000Z07:00"
+	timestampPattern = regexp.MustCompile(`\d{4}-\d{2}-\d{2}[ T]\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:\d{2})?`)
+	// Dev mode time since start, e.g. "+1.250s"
+	elapsedPattern = regexp.MustCompile(`\+\d+\.\d{3}s`)
+	// Color sequences, raw or quoted by the text formatter, and their padding
+	ansiPattern = regexp.MustCompile(`(\x1b|\\x1b)\[[0-9;]*m ?`)
+)
+
+// Replace timestamps, the hostname and color sequences with stable text.
+func Normalize(_log string) string {
+	_log = ansiPattern.ReplaceAllString(_log, "")
+	_log = timestampPattern.ReplaceAllString(_log, "<timestamp>")
+	_log = elapsedPattern.ReplaceAllString(_log, "+<elapsed>")
+	if hostname, err := os.Hostname(); err == nil && hostname != "" {
+		_log = regexp.MustCompile(`\b`+regexp.QuoteMeta(hostname)+`\b`).ReplaceAllString(_log, "<hostname>")
+	}
+	return _log
+}
+
+// Write the records of the logger to a buffer until the test completes.
+func Capture(_t testing.TB, _pm *infrastructure.ProjectInfrastructure) *bytes.Buffer {
+	_t.Helper()
+
+	var buf bytes.Buffer
+	redirect(_t, _pm, &buf)
+	return &buf
+}
+
+/*
+Fail the test when the normalized log differs from the golden file
+
+Run the tests with INFRA_UPDATE_GOLDEN=1 to write the golden files.
+*/
+func AssertGolden(_t testing.TB, _captured, _golden string) {
+	_t.Helper()
+
+	got := Normalize(_captured)
+	if os.Getenv(UpdateEnv) != "" {
+		if err := os.MkdirAll(filepath.Dir(_golden), 0o755); err != nil {
+			_t.Fatalf("create golden file directory: %v", err)
+		}
+		if err := os.WriteFile(_golden, []byte(got), 0o644); err != nil {
+			_t.Fatalf("write golden file: %v", err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(_golden)
+	if err != nil {
+		_t.Fatalf("read golden file, run with %s=1 to create it: %v", UpdateEnv, err)
+	}
+	if got != string(want) {
+		_t.Errorf("log output differs from %s\n--- got\n%s\n--- want\n%s", _golden, got, want)
+	}
+}
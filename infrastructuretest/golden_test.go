@@ -0,0 +1,45 @@
+package infrastructuretest
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/just-lick-it/infrastructure"
+	"github.com/pkg/errors"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"2026-01-02 15:04:05 main boom", "<timestamp> main boom"},
+		{`{"timestamp":"2026-01-02T15:04:05.000+02:00"}`, `{"timestamp":"<timestamp>"}`},
+		{"\x1b[31m main \x1b[0m boom", "main boom"},
+		{`msg="\x1b[31m main \x1b[0m boom"`, `msg="main boom"`},
+		{"+1.250s main", "+<elapsed> main"},
+	}
+	for _, tt := range tests {
+		if got := Normalize(tt.in); got != tt.want {
+			t.Errorf("Normalize(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestCapture(t *testing.T) {
+	pm, err := infrastructure.NewProjectInfrastructure(context.Background(),
+		infrastructure.WithLibraryMode(true),
+		infrastructure.WithLogColor(false),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pm.ResourceRelease()
+
+	buf := Capture(t, pm)
+	pm.ErrorTransmit("capture", "info", errors.New("captured record"), false, false)
+	if !strings.Contains(buf.String(), "captured record") {
+		t.Fatalf("record not captured: %q", buf.String())
+	}
+}
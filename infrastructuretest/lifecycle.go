@@ -0,0 +1,157 @@
+package infrastructuretest
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/just-lick-it/infrastructure"
+)
+
+// A start or stop observed by a Harness, times are relative to its creation.
+type LifecycleEvent struct {
+	Name  string
+	Phase string // "start" or "stop"
+	Start time.Duration
+	End   time.Duration
+	Err   error
+}
+
+/*
+Instance with fake lifecycle participants recording when they start and stop
+
+The instance runs in library mode, without signal handlers or process exit,
+and logs through t.Log. Add the participants, then call Shutdown and compare
+the returned events with the expected ordering.
+*/
+type Harness struct {
+	PM *infrastructure.ProjectInfrastructure
+
+	t       testing.TB
+	created time.Time
+	mu      sync.Mutex
+	events  []LifecycleEvent
+}
+
+func NewHarness(_t testing.TB, _opts ...infrastructure.OptionFunc) *Harness {
+	_t.Helper()
+
+	opts := append([]infrastructure.OptionFunc{infrastructure.WithLibraryMode(true)}, _opts...)
+	pm, err := infrastructure.NewProjectInfrastructure(context.Background(), opts...)
+	if err != nil {
+		_t.Fatalf("create infrastructure: %v", err)
+	}
+	RedirectToTesting(_t, pm)
+	return &Harness{PM: pm, t: _t, created: time.Now()}
+}
+
+// Run the function between two timestamps and record it.
+func (h *Harness) observe(_name, _phase string, _fn func() error) error {
+	start := time.Since(h.created)
+	err := _fn()
+	event := LifecycleEvent{Name: _name, Phase: _phase, Start: start, End: time.Since(h.created), Err: err}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.events = append(h.events, event)
+	return err
+}
+
+type fakeComponent struct {
+	h         *Harness
+	name      string
+	stopDelay time.Duration
+	stopErr   error
+}
+
+func (c *fakeComponent) Name() string { return c.name }
+
+func (c *fakeComponent) Start(_ctx context.Context) error {
+	return c.h.observe(c.name, "start", func() error { return nil })
+}
+
+func (c *fakeComponent) Stop(_ctx context.Context) error {
+	return c.h.observe(c.name, "stop", func() error {
+		select {
+		case <-time.After(c.stopDelay):
+		case <-_ctx.Done():
+			return _ctx.Err()
+		}
+		return c.stopErr
+	})
+}
+
+// Register a component taking the delay to stop, failing with the error.
+func (h *Harness) Component(_name string, _stopDelay time.Duration, _stopErr error) {
+	h.t.Helper()
+	if err := h.PM.RegisterComponent(&fakeComponent{h: h, name: _name, stopDelay: _stopDelay, stopErr: _stopErr}); err != nil {
+		h.t.Fatalf("register component %s: %v", _name, err)
+	}
+}
+
+// Register a release hook taking the delay to close.
+func (h *Harness) ReleaseHook(_name string, _priority int, _parallel bool, _delay time.Duration) {
+	h.t.Helper()
+	err := h.PM.RegisterReleaseHook(infrastructure.ReleaseHook{
+		Name:     _name,
+		Priority: _priority,
+		Parallel: _parallel,
+		Release: func(ctx context.Context) error {
+			return h.observe(_name, "stop", func() error {
+				time.Sleep(_delay)
+				return nil
+			})
+		},
+	})
+	if err != nil {
+		h.t.Fatalf("register release hook %s: %v", _name, err)
+	}
+}
+
+// Start a goroutine that takes the drain time to return once cancelled.
+func (h *Harness) Goroutine(_name string, _drain time.Duration) {
+	h.t.Helper()
+	h.observe(_name, "start", func() error { return nil })
+	err := h.PM.Go(func(ctx context.Context) {
+		<-ctx.Done()
+		h.observe(_name, "stop", func() error {
+			time.Sleep(_drain)
+			return nil
+		})
+	})
+	if err != nil {
+		h.t.Fatalf("start goroutine %s: %v", _name, err)
+	}
+}
+
+// Shut the instance down and return the events ordered by their end.
+func (h *Harness) Shutdown() []LifecycleEvent {
+	h.t.Helper()
+	if err := h.PM.Shutdown(); err != nil {
+		h.t.Fatalf("shutdown: %v", err)
+	}
+	return h.Events()
+}
+
+// The events recorded so far, ordered by their end.
+func (h *Harness) Events() []LifecycleEvent {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	events := append([]LifecycleEvent(nil), h.events...)
+	sort.SliceStable(events, func(i, j int) bool { return events[i].End < events[j].End })
+	return events
+}
+
+// Names of the events of the phase, in order.
+func Order(_events []LifecycleEvent, _phase string) []string {
+	var names []string
+	for _, e := range _events {
+		if e.Phase == _phase {
+			names = append(names, e.Name)
+		}
+	}
+	return names
+}
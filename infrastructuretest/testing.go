@@ -0,0 +1,47 @@
+package infrastructuretest
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/just-lick-it/infrastructure"
+	"github.com/sirupsen/logrus"
+)
+
+// Write records through t.Log, so they are reported with the test.
+type testingWriter struct {
+	t testing.TB
+}
+
+func (w testingWriter) Write(_p []byte) (int, error) {
+	w.t.Helper()
+	line := ansiPattern.ReplaceAllString(string(_p), "")
+	w.t.Log(strings.TrimRight(line, "\n"))
+	return len(_p), nil
+}
+
+/*
+Route all log output through t.Log for the duration of the test
+
+The configured outputs and hooks are detached and restored when the test and
+its subtests complete.
+*/
+func RedirectToTesting(_t testing.TB, _pm *infrastructure.ProjectInfrastructure) {
+	_t.Helper()
+	redirect(_t, _pm, testingWriter{t: _t})
+}
+
+// Write the records of the logger to w instead of its outputs and hooks
+// until the test completes.
+func redirect(_t testing.TB, _pm *infrastructure.ProjectInfrastructure, _w io.Writer) {
+	logger := _pm.Logger()
+	out := logger.Out
+	hooks := logger.ReplaceHooks(make(logrus.LevelHooks))
+	logger.SetOutput(_w)
+
+	_t.Cleanup(func() {
+		logger.SetOutput(out)
+		logger.ReplaceHooks(hooks)
+	})
+}
@@ -0,0 +1,86 @@
+package infrastructure
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// Largest record accepted on the ingestion socket.
+const maxIngestRecord = 1 << 20
+
+/*
+Accept log records from other processes on a unix socket
+
+Every line is a JSON LogRecord, only module, severity, message and fields
+are used, for example from a shell helper:
+
+	echo '{"module":"backup","severity":"error","message":"disk full"}' | nc -U <path>
+*/
+func (pm *ProjectInfrastructure) startIngestSocket(_path string) error {
+	l, err := pm.listenUnix(_path)
+	if err != nil {
+		return errors.Wrap(err, "ingest socket")
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go pm.ingest(conn)
+		}
+	}()
+	return nil
+}
+
+func (pm *ProjectInfrastructure) ingest(_conn net.Conn) {
+	defer _conn.Close()
+	pm.ingestRecords(_conn, "ingest", nil)
+}
+
+// Log the JSON LogRecord lines of the reader with the extra fields,
+// malformed lines are reported under the module. Another process must not
+// exit or panic this one, fatal and panic records are logged as errors with
+// the original severity in the "ingested_severity" field.
+func (pm *ProjectInfrastructure) ingestRecords(_r io.Reader, _module string, _fields logrus.Fields) {
+	scanner := bufio.NewScanner(_r)
+	scanner.Buffer(make([]byte, 4096), maxIngestRecord)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var record LogRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			pm.logOutput(_module, "warn", errors.Errorf("malformed record: %v", err), false, nil)
+			continue
+		}
+		fields := make(logrus.Fields, len(record.Fields)+len(_fields)+1)
+		for k, v := range record.Fields {
+			fields[k] = v
+		}
+		for k, v := range _fields {
+			fields[k] = v
+		}
+		severity := record.Severity
+		if severityAtLeast(severity, logrus.FatalLevel) {
+			fields["ingested_severity"] = severity
+			severity = "error"
+		}
+		pm.ingestRecord(record.Module, severity, errors.New(record.Message), fields)
+	}
+}
+
+func (pm *ProjectInfrastructure) ingestRecord(_module, _severity string, _err error, _fields logrus.Fields) {
+	// A panicking output must not end the reader, as in ErrorTransmit.
+	defer func() {
+		if r := recover(); r != nil {
+			pm.logger.WithFields(panicFields(r)).Error("panic recovered in log ingestion")
+		}
+	}()
+	pm.logOutput(_module, _severity, _err, false, _fields)
+}
@@ -0,0 +1,45 @@
+package infrastructure
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestIngestRecordsNeverExit(t *testing.T) {
+	var buf bytes.Buffer
+	exited := false
+	pm, err := NewProjectInfrastructure(context.Background(),
+		WithLibraryMode(true),
+		WithLogOutput("writer"),
+		WithLogWriter(&buf),
+		WithExitHandler(func(int) { exited = true }),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pm.Shutdown()
+
+	tests := []struct {
+		line, severity, ingested string
+	}{
+		{`{"module":"backup","severity":"fatal","message":"disk gone"}`, "error", "fatal"},
+		{`{"module":"backup","severity":"panic","message":"nil map"}`, "error", "panic"},
+		{`{"module":"backup","severity":"warn","message":"slow disk"}`, "warn", ""},
+	}
+	for _, tt := range tests {
+		buf.Reset()
+		pm.ingestRecords(strings.NewReader(tt.line+"\n"), "ingest", nil)
+		out := buf.String()
+		if !strings.Contains(out, "level="+tt.severity) {
+			t.Errorf("%s: not written at %s:\n%s", tt.line, tt.severity, out)
+		}
+		if got := strings.Contains(out, "ingested_severity="+tt.ingested); tt.ingested != "" && !got {
+			t.Errorf("%s: original severity not kept:\n%s", tt.line, out)
+		}
+	}
+	if exited || pm.State() != StateRunning {
+		t.Fatalf("ingested records ended the process, state %s", pm.State())
+	}
+}
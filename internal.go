@@ -0,0 +1,15 @@
+package infrastructure
+
+// Problems of the infrastructure itself, such as a misconfigured output it
+// fell back from, buffered up to ErrChanLen. Errors are dropped while the
+// channel is full.
+func (pm *ProjectInfrastructure) InternalErrors() <-chan error {
+	return pm.internalErrors
+}
+
+func (pm *ProjectInfrastructure) internalError(_err error) {
+	select {
+	case pm.internalErrors <- _err:
+	default:
+	}
+}
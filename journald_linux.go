@@ -0,0 +1,109 @@
+//go:build linux
+
+package infrastructure
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const journaldSocket = "/run/systemd/journal/socket"
+
+var journaldPriorities = map[logrus.Level]int{
+	logrus.PanicLevel: 0,
+	logrus.FatalLevel: 2,
+	logrus.ErrorLevel: 3,
+	logrus.WarnLevel:  4,
+	logrus.InfoLevel:  6,
+	logrus.DebugLevel: 7,
+	logrus.TraceLevel: 7,
+}
+
+// Send records to journald with the native protocol, one datagram per record
+// carrying the priority, the module and the entry fields.
+type journaldWriter struct {
+	conn       *net.UnixConn
+	identifier string
+}
+
+func openJournald(_opts ProjectInfrastructureOptions) (*journaldWriter, error) {
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: journaldSocket, Net: "unixgram"})
+	if err != nil {
+		return nil, errors.Wrap(err, "connect journald")
+	}
+	return &journaldWriter{conn: conn, identifier: _opts.SyslogTag}, nil
+}
+
+func (j *journaldWriter) Write(_p []byte) (int, error) {
+	if err := j.WriteLevel(logrus.InfoLevel, _p); err != nil {
+		return 0, err
+	}
+	return len(_p), nil
+}
+
+func (j *journaldWriter) WriteLevel(_level logrus.Level, _p []byte) error {
+	return j.send(_level, string(bytes.TrimRight(_p, "\n")), "", nil)
+}
+
+func (j *journaldWriter) WriteEntry(_entry *logrus.Entry, _line []byte) error {
+	return j.send(_entry.Level, strings.TrimRight(_entry.Message, "\n"), entryModule(_entry), _entry.Data)
+}
+
+func (j *journaldWriter) send(_level logrus.Level, _msg, _module string, _fields logrus.Fields) error {
+	var buf bytes.Buffer
+	journaldField(&buf, "MESSAGE", _msg)
+	journaldField(&buf, "PRIORITY", fmt.Sprint(journaldPriorities[_level]))
+	if j.identifier != "" {
+		journaldField(&buf, "SYSLOG_IDENTIFIER", j.identifier)
+	}
+	if _module != "" {
+		journaldField(&buf, "MODULE", _module)
+	}
+	for k, v := range _fields {
+		if k == "module" {
+			continue
+		}
+		journaldField(&buf, journaldFieldName(k), fmt.Sprint(v))
+	}
+	_, err := j.conn.Write(buf.Bytes())
+	return err
+}
+
+// Append a field, values with a newline use the length prefixed form.
+func journaldField(_buf *bytes.Buffer, _name, _value string) {
+	_buf.WriteString(_name)
+	if !strings.Contains(_value, "\n") {
+		_buf.WriteByte('=')
+		_buf.WriteString(_value)
+		_buf.WriteByte('\n')
+		return
+	}
+	_buf.WriteByte('\n')
+	binary.Write(_buf, binary.LittleEndian, uint64(len(_value)))
+	_buf.WriteString(_value)
+	_buf.WriteByte('\n')
+}
+
+// Journald field names are upper case letters, digits and underscores and
+// must not start with an underscore or a digit.
+func journaldFieldName(_key string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		}
+		return '_'
+	}, _key)
+	if name == "" || name[0] == '_' || (name[0] >= '0' && name[0] <= '9') {
+		name = "F" + name
+	}
+	return name
+}
@@ -0,0 +1,28 @@
+//go:build !linux
+
+package infrastructure
+
+import (
+	"runtime"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+type journaldWriter struct{}
+
+func openJournald(_opts ProjectInfrastructureOptions) (*journaldWriter, error) {
+	return nil, errors.Errorf("journald output is not supported on %s", runtime.GOOS)
+}
+
+func (j *journaldWriter) Write(_p []byte) (int, error) {
+	return 0, errors.New("journald output is not supported")
+}
+
+func (j *journaldWriter) WriteLevel(_level logrus.Level, _p []byte) error {
+	return errors.New("journald output is not supported")
+}
+
+func (j *journaldWriter) WriteEntry(_entry *logrus.Entry, _line []byte) error {
+	return errors.New("journald output is not supported")
+}
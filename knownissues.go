@@ -0,0 +1,86 @@
+package infrastructure
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+/*
+A known issue entry loaded from the file given to WithKnownIssues
+
+@fingerprint: value returned by Fingerprint for the error
+
+@action: "suppress" drops the record, "downgrade" logs it at Severity
+
+@severity: severity used by "downgrade"
+
+@expires: after this time the entry is ignored, the error re-escalates
+*/
+type KnownIssue struct {
+	Fingerprint string    `json:"fingerprint"`
+	Action      string    `json:"action"`
+	Severity    string    `json:"severity,omitempty"`
+	Expires     time.Time `json:"expires,omitempty"`
+	Note        string    `json:"note,omitempty"`
+}
+
+// Stable identifier of an error reported by a module, built from the module
+// name and the bottom error message.
+func Fingerprint(_module string, _err error) string {
+	h := sha1.New()
+	h.Write([]byte(_module))
+	h.Write([]byte{0})
+	if _err != nil {
+		h.Write([]byte(errors.Cause(_err).Error()))
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+func loadKnownIssues(_path string) (map[string]KnownIssue, error) {
+	data, err := os.ReadFile(_path)
+	if err != nil {
+		return nil, errors.Wrap(err, "read known issues")
+	}
+
+	var list []KnownIssue
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, errors.Wrapf(err, "parse known issues %s", _path)
+	}
+
+	issues := make(map[string]KnownIssue, len(list))
+	for _, issue := range list {
+		switch issue.Action {
+		case "suppress":
+		case "downgrade":
+			if _, err := parseLogLevel(issue.Severity); err != nil {
+				return nil, errors.Wrapf(err, "known issue %s", issue.Fingerprint)
+			}
+		default:
+			return nil, errors.Errorf("known issue %s: invalid action %s, valid values are [suppress downgrade]", issue.Fingerprint, issue.Action)
+		}
+		issues[issue.Fingerprint] = issue
+	}
+	return issues, nil
+}
+
+// Apply the known issue list to a record, returning the severity to emit
+// and whether the record is dropped.
+func (pm *ProjectInfrastructure) applyKnownIssues(_module, _severity string, _err error) (string, bool) {
+	if len(pm.knownIssues) == 0 {
+		return _severity, false
+	}
+
+	issue, ok := pm.knownIssues[Fingerprint(_module, _err)]
+	if !ok || (!issue.Expires.IsZero() && time.Now().After(issue.Expires)) {
+		return _severity, false
+	}
+	if issue.Action == "suppress" {
+		return _severity, true
+	}
+	return issue.Severity, false
+}
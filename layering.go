@@ -0,0 +1,373 @@
+package infrastructure
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+// Environment variable pointing at a config file, WithConfigFile wins over it.
+const configFileEnv = "INFRA_CONFIG_FILE"
+
+// Prefix of the environment variables overriding options, e.g. INFRA_LOG_LEVEL.
+const optionEnvPrefix = "INFRA_"
+
+// Where an effective option value came from, reported by OptionSources.
+const (
+	SourceDefault = "default"
+	SourceFile    = "file"
+	SourceEnv     = "env"
+	SourceOption  = "option"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// Convert a field name into its config key, e.g. LogMaxFileNum to log_max_file_num.
+// A run of capitals is one word, so SentryDSN becomes sentry_dsn and
+// OTLPEndpoint becomes otlp_endpoint.
+func optionKey(_field string) string {
+	runes := []rune(_field)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				prev := runes[i-1]
+				nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+				if !unicode.IsUpper(prev) || nextLower {
+					b.WriteByte('_')
+				}
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// Options that can be set from a config file, functions and interfaces
+// such as os.Signal can only be set in code.
+func layerable(_field reflect.StructField) bool {
+	switch _field.Type.Kind() {
+	case reflect.Func, reflect.Interface:
+		return false
+	case reflect.Slice:
+		return _field.Type.Elem().Kind() != reflect.Interface
+	}
+	return true
+}
+
+// Options that can be set from a single environment variable.
+func scalarOption(_field reflect.StructField) bool {
+	switch _field.Type.Kind() {
+	case reflect.String, reflect.Bool, reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+func parseBoolOption(_value string) (bool, error) {
+	switch strings.ToLower(_value) {
+	case "yes", "on":
+		return true, nil
+	case "no", "off":
+		return false, nil
+	}
+	return strconv.ParseBool(_value)
+}
+
+// Set a scalar option from its text form.
+func setOptionValue(_v reflect.Value, _value string) error {
+	if _v.Type() == durationType {
+		d, err := time.ParseDuration(_value)
+		if err != nil {
+			return err
+		}
+		_v.SetInt(int64(d))
+		return nil
+	}
+
+	switch _v.Kind() {
+	case reflect.String:
+		_v.SetString(_value)
+	case reflect.Bool:
+		b, err := parseBoolOption(_value)
+		if err != nil {
+			return err
+		}
+		_v.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(_value, 10, 64)
+		if err != nil {
+			return err
+		}
+		_v.SetInt(n)
+	case reflect.Uint, reflect.Uint64:
+		n, err := strconv.ParseUint(_value, 10, 64)
+		if err != nil {
+			return err
+		}
+		_v.SetUint(n)
+	default:
+		return errors.Errorf("unsupported option type %s", _v.Type())
+	}
+	return nil
+}
+
+// Apply a JSON config file whose keys are the option keys, durations are
+// written as strings such as "5s" at any depth.
+func applyConfigFile(_opts *ProjectInfrastructureOptions, _path string, _sources map[string]string) error {
+	data, err := os.ReadFile(_path)
+	if err != nil {
+		return errors.Wrap(err, "read config file")
+	}
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return errors.Wrapf(err, "parse config file %s", _path)
+	}
+
+	v := reflect.ValueOf(_opts).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		key := optionKey(t.Field(i).Name)
+		value, ok := raw[key]
+		if !ok || !layerable(t.Field(i)) {
+			continue
+		}
+		delete(raw, key)
+
+		if err := decodeOption(v.Field(i), value); err != nil {
+			return errors.Wrapf(err, "config file %s: %s", _path, key)
+		}
+		_sources[key] = SourceFile
+	}
+	for key := range raw {
+		return errors.Errorf("config file %s: unknown option %s", _path, key)
+	}
+	return nil
+}
+
+// Whether durations are found in values of the type, at any depth.
+func hasDuration(_t reflect.Type) bool {
+	if _t == durationType {
+		return true
+	}
+	switch _t.Kind() {
+	case reflect.Slice, reflect.Map:
+		return hasDuration(_t.Elem())
+	case reflect.Struct:
+		for i := 0; i < _t.NumField(); i++ {
+			if hasDuration(_t.Field(i).Type) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Decode the JSON of an option into the value, durations are strings at any
+// depth, as described by ConfigSchema. Maps are merged into like
+// json.Unmarshal does.
+func decodeOption(_v reflect.Value, _data json.RawMessage) error {
+	t := _v.Type()
+	if !hasDuration(t) {
+		return json.Unmarshal(_data, _v.Addr().Interface())
+	}
+	switch {
+	case t == durationType:
+		var s string
+		if err := json.Unmarshal(_data, &s); err != nil {
+			return err
+		}
+		return setOptionValue(_v, s)
+	case t.Kind() == reflect.Slice:
+		var items []json.RawMessage
+		if err := json.Unmarshal(_data, &items); err != nil {
+			return err
+		}
+		slice := reflect.MakeSlice(t, len(items), len(items))
+		for i, item := range items {
+			if err := decodeOption(slice.Index(i), item); err != nil {
+				return errors.Wrapf(err, "[%d]", i)
+			}
+		}
+		_v.Set(slice)
+	case t.Kind() == reflect.Map && t.Key().Kind() == reflect.String:
+		var items map[string]json.RawMessage
+		if err := json.Unmarshal(_data, &items); err != nil {
+			return err
+		}
+		if _v.IsNil() {
+			_v.Set(reflect.MakeMapWithSize(t, len(items)))
+		}
+		for k, item := range items {
+			elem := reflect.New(t.Elem()).Elem()
+			if err := decodeOption(elem, item); err != nil {
+				return errors.Wrap(err, k)
+			}
+			_v.SetMapIndex(reflect.ValueOf(k).Convert(t.Key()), elem)
+		}
+	case t.Kind() == reflect.Struct:
+		var items map[string]json.RawMessage
+		if err := json.Unmarshal(_data, &items); err != nil {
+			return err
+		}
+		for i := 0; i < t.NumField(); i++ {
+			name := t.Field(i).Name
+			if tag := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]; tag != "" {
+				name = tag
+			}
+			item, ok := items[name]
+			if !ok {
+				continue
+			}
+			delete(items, name)
+			if err := decodeOption(_v.Field(i), item); err != nil {
+				return errors.Wrap(err, name)
+			}
+		}
+		for name := range items {
+			return errors.Errorf("unknown field %s", name)
+		}
+	default:
+		return errors.Errorf("unsupported option type %s", t)
+	}
+	return nil
+}
+
+// Apply INFRA_<OPTION_KEY> environment variables.
+func applyEnv(_opts *ProjectInfrastructureOptions, _sources map[string]string) error {
+	v := reflect.ValueOf(_opts).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if !scalarOption(t.Field(i)) {
+			continue
+		}
+		key := optionKey(t.Field(i).Name)
+		name := optionEnvPrefix + strings.ToUpper(key)
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		if err := setOptionValue(v.Field(i), value); err != nil {
+			return errors.Wrapf(err, "invalid %s", name)
+		}
+		_sources[key] = SourceEnv
+	}
+	return nil
+}
+
+/*
+Build the effective options from the layers, each one overriding the previous
+
+defaults < config file < INFRA_* environment variables < OptionFuncs
+
+The config file is the one given to WithConfigFile, or INFRA_CONFIG_FILE.
+*/
+func resolveOptions(_optionFuncs []OptionFunc) (ProjectInfrastructureOptions, map[string]string, error) {
+	// The config file path may itself come from an OptionFunc.
+	probe := DefaultOptions()
+	for _, optFunc := range _optionFuncs {
+		optFunc(&probe)
+	}
+	configFile := probe.ConfigFile
+	if configFile == "" {
+		configFile = os.Getenv(configFileEnv)
+	}
+
+	options := DefaultOptions()
+	sources := make(map[string]string)
+	t := reflect.TypeOf(options)
+	for i := 0; i < t.NumField(); i++ {
+		if layerable(t.Field(i)) {
+			sources[optionKey(t.Field(i).Name)] = SourceDefault
+		}
+	}
+
+	if configFile != "" {
+		if err := applyConfigFile(&options, configFile, sources); err != nil {
+			return options, nil, err
+		}
+	}
+	if err := applyEnv(&options, sources); err != nil {
+		return options, nil, err
+	}
+
+	// OptionFuncs may merge into the maps and slices of the layers below.
+	before := copyOptions(options)
+	for _, optFunc := range _optionFuncs {
+		optFunc(&options)
+	}
+	bv, av := reflect.ValueOf(before), reflect.ValueOf(options)
+	for i := 0; i < t.NumField(); i++ {
+		if !layerable(t.Field(i)) {
+			continue
+		}
+		if !reflect.DeepEqual(bv.Field(i).Interface(), av.Field(i).Interface()) {
+			sources[optionKey(t.Field(i).Name)] = SourceOption
+		}
+	}
+	options.ConfigFile = configFile
+	return options, sources, nil
+}
+
+// Copy of the options sharing no map or slice with them.
+func copyOptions(_opts ProjectInfrastructureOptions) ProjectInfrastructureOptions {
+	v := reflect.ValueOf(&_opts).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		if v.Field(i).CanSet() {
+			v.Field(i).Set(deepCopyValue(v.Field(i)))
+		}
+	}
+	return _opts
+}
+
+// Copy the maps and slices of the value, recursively, other values as is.
+func deepCopyValue(_v reflect.Value) reflect.Value {
+	switch _v.Kind() {
+	case reflect.Map:
+		if _v.IsNil() {
+			return _v
+		}
+		m := reflect.MakeMapWithSize(_v.Type(), _v.Len())
+		iter := _v.MapRange()
+		for iter.Next() {
+			m.SetMapIndex(iter.Key(), deepCopyValue(iter.Value()))
+		}
+		return m
+	case reflect.Slice:
+		if _v.IsNil() {
+			return _v
+		}
+		s := reflect.MakeSlice(_v.Type(), _v.Len(), _v.Len())
+		for i := 0; i < _v.Len(); i++ {
+			s.Index(i).Set(deepCopyValue(_v.Index(i)))
+		}
+		return s
+	case reflect.Struct:
+		s := reflect.New(_v.Type()).Elem()
+		s.Set(_v)
+		for i := 0; i < s.NumField(); i++ {
+			if s.Field(i).CanSet() {
+				s.Field(i).Set(deepCopyValue(s.Field(i)))
+			}
+		}
+		return s
+	}
+	return _v
+}
+
+// Report where each effective option value came from, keyed by option key
+// (e.g. "log_out") with one of SourceDefault, SourceFile, SourceEnv, SourceOption.
+func (pm *ProjectInfrastructure) OptionSources() map[string]string {
+	sources := make(map[string]string, len(pm.optionSources))
+	for k, v := range pm.optionSources {
+		sources[k] = v
+	}
+	return sources
+}
@@ -0,0 +1,115 @@
+package infrastructure
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestOptionKey(t *testing.T) {
+	tests := []struct {
+		field string
+		want  string
+	}{
+		{"LogLevel", "log_level"},
+		{"LogMaxFileNum", "log_max_file_num"},
+		{"TimestampUTC", "timestamp_utc"},
+		{"SentryDSN", "sentry_dsn"},
+		{"OTLPEndpoint", "otlp_endpoint"},
+		{"OTLPServiceName", "otlp_service_name"},
+		{"LokiURL", "loki_url"},
+		{"ErrChanLen", "err_chan_len"},
+		{"IDGenerator", "id_generator"},
+	}
+	for _, tt := range tests {
+		if got := optionKey(tt.field); got != tt.want {
+			t.Errorf("optionKey(%q) = %q, want %q", tt.field, got, tt.want)
+		}
+	}
+}
+
+func TestResolveOptionsLayers(t *testing.T) {
+	dir := t.TempDir()
+	config := filepath.Join(dir, "config.json")
+	data := `{"log_level": "warn", "log_max_file_num": 3, "flush_timeout": "7s", "otlp_endpoint": "http://file"}`
+	if err := os.WriteFile(config, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(configFileEnv, config)
+	t.Setenv("INFRA_LOG_MAX_FILE_NUM", "5")
+	t.Setenv("INFRA_OTLP_ENDPOINT", "http://env")
+
+	opts, sources, err := resolveOptions([]OptionFunc{WithLogLevel("error")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		key    string
+		got    interface{}
+		want   interface{}
+		source string
+	}{
+		{"log_level", opts.LogLevel, "error", SourceOption},
+		{"log_max_file_num", opts.LogMaxFileNum, uint(5), SourceEnv},
+		{"flush_timeout", opts.FlushTimeout, 7 * time.Second, SourceFile},
+		{"otlp_endpoint", opts.OTLPEndpoint, "http://env", SourceEnv},
+		{"log_format", opts.LogFormat, DefaultOptions().LogFormat, SourceDefault},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %v, want %v", tt.key, tt.got, tt.want)
+		}
+		if sources[tt.key] != tt.source {
+			t.Errorf("source of %s = %q, want %q", tt.key, sources[tt.key], tt.source)
+		}
+	}
+}
+
+// OptionFuncs merging into a map or slice set by a lower layer are reported
+// as its source.
+func TestResolveOptionsMergedSources(t *testing.T) {
+	config := filepath.Join(t.TempDir(), "config.json")
+	data := `{"static_labels": {"team": "core"}, "severity_retention": {"debug": "72h"}, "redact_keys": ["password"]}`
+	if err := os.WriteFile(config, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(configFileEnv, config)
+
+	opts, sources, err := resolveOptions([]OptionFunc{
+		WithStaticLabels(map[string]string{"env": "prod"}),
+		WithSeverityRetention("error", 90*24*time.Hour),
+		WithRedactKeys("token"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		key   string
+		count int
+	}{
+		{"static_labels", len(opts.StaticLabels)},
+		{"severity_retention", len(opts.SeverityRetention)},
+		{"redact_keys", len(opts.RedactKeys)},
+	}
+	for _, tt := range tests {
+		if tt.count != 2 {
+			t.Errorf("%s has %d entries, want the file and the option ones", tt.key, tt.count)
+		}
+		if sources[tt.key] != SourceOption {
+			t.Errorf("source of %s = %q, want %q", tt.key, sources[tt.key], SourceOption)
+		}
+	}
+}
+
+func TestApplyConfigFileUnknownOption(t *testing.T) {
+	config := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(config, []byte(`{"o_t_l_p_endpoint": "x"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	opts := DefaultOptions()
+	if err := applyConfigFile(&opts, config, map[string]string{}); err == nil {
+		t.Fatal("expected an error for an unknown option")
+	}
+}
@@ -0,0 +1,286 @@
+package infrastructure
+
+import (
+	"path"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+type LogLevelPattern struct {
+	Pattern string `json:"pattern"`
+	Level   string `json:"level"`
+}
+
+type levelPattern struct {
+	pattern string
+	rank    int
+}
+
+// Convert a supported severity name into a logrus level.
+func parseLogLevel(_level string) (logrus.Level, error) {
+	switch _level {
+	case "debug":
+		return logrus.DebugLevel, nil
+	case "info":
+		return logrus.InfoLevel, nil
+	case "warn":
+		return logrus.WarnLevel, nil
+	case "error":
+		return logrus.ErrorLevel, nil
+	case "fatal":
+		return logrus.FatalLevel, nil
+	case "panic":
+		return logrus.PanicLevel, nil
+	}
+	if def, ok := severityByName(_level); ok {
+		return def.level, nil
+	}
+	return logrus.PanicLevel, errors.Errorf("invalid log level %s, valid values are %s", _level, logTypes())
+}
+
+// Report whether the severity is the level or more severe, unknown
+// severities are logged as errors.
+func severityAtLeast(_severity string, _level logrus.Level) bool {
+	level, err := parseLogLevel(_severity)
+	if err != nil {
+		level = logrus.ErrorLevel
+	}
+	return level <= _level
+}
+
+// Log the message at the level. logrus panics after writing a PanicLevel
+// record, the panic is left to transmit once resources are released.
+func logAt(_entry *logrus.Entry, _level logrus.Level, _msg string) {
+	if _level == logrus.PanicLevel {
+		defer func() {
+			if r := recover(); r != nil {
+				if _, ok := r.(*logrus.Entry); !ok {
+					panic(r)
+				}
+			}
+		}()
+	}
+	_entry.Log(_level, _msg)
+}
+
+// Convert a logrus level back into its severity name.
+func logLevelName(_level logrus.Level) string {
+	if _level == logrus.WarnLevel {
+		return "warn"
+	}
+	return _level.String()
+}
+
+// Report whether a module pattern matches the module name. A pattern ending
+// in ".*" also matches its parent scope, so "app.db.*" covers "app.db".
+func matchModulePattern(_pattern, _module string) bool {
+	if ok, _ := path.Match(_pattern, _module); ok {
+		return true
+	}
+	if strings.HasSuffix(_pattern, ".*") {
+		return strings.TrimSuffix(_pattern, ".*") == _module
+	}
+	return false
+}
+
+// Report whether the module passes the include and exclude filters, an
+// empty include list lets every module through and exclusion wins.
+func (pm *ProjectInfrastructure) moduleAllowed(_module string) bool {
+	for _, pattern := range pm.options.ModuleExclude {
+		if matchModulePattern(pattern, _module) {
+			return false
+		}
+	}
+	if len(pm.options.ModuleInclude) == 0 {
+		return true
+	}
+	for _, pattern := range pm.options.ModuleInclude {
+		if matchModulePattern(pattern, _module) {
+			return true
+		}
+	}
+	return false
+}
+
+// The literal length before the first wildcard, used to rank patterns.
+func patternSpecificity(_pattern string) int {
+	if i := strings.IndexAny(_pattern, "*?["); i >= 0 {
+		return i
+	}
+	// An exact name always beats a wildcard pattern of the same prefix.
+	return len(_pattern) + 1
+}
+
+// Resolve the effective level of a module, as a severity rank. An exact
+// module level wins, then the most specific matching pattern with later
+// patterns winning ties, and the global level applies when nothing matches.
+func (pm *ProjectInfrastructure) moduleLevel(_module string) int {
+	pm.levelMu.RLock()
+	defer pm.levelMu.RUnlock()
+
+	if rank, ok := pm.moduleLevels[_module]; ok {
+		return rank
+	}
+	rank := pm.level
+	best := -1
+	for _, p := range pm.levelPatterns {
+		if !matchModulePattern(p.pattern, _module) {
+			continue
+		}
+		if s := patternSpecificity(p.pattern); s >= best {
+			best = s
+			rank = p.rank
+		}
+	}
+	return rank
+}
+
+// Report whether a record of the given severity from the module should be emitted.
+func (pm *ProjectInfrastructure) levelEnabled(_module, _severity string) bool {
+	// Unsupported severities are reported at error level.
+	return severityRankOrError(_severity) <= pm.moduleLevel(_module)
+}
+
+// Change the global level. logrus must let through the most verbose level
+// any module may use, the per-module filtering happens in logOutput.
+// Set the global level, the generation of the change is returned.
+func (pm *ProjectInfrastructure) setLevel(_rank int) uint64 {
+	pm.levelMu.Lock()
+	defer pm.levelMu.Unlock()
+	return pm.applyLevel(_rank)
+}
+
+// Restore the global level unless it changed since the change of generation
+// gen, whether it was restored is returned.
+func (pm *ProjectInfrastructure) restoreLevel(_rank int, _gen uint64) bool {
+	pm.levelMu.Lock()
+	defer pm.levelMu.Unlock()
+	if pm.levelGen != _gen {
+		return false
+	}
+	pm.applyLevel(_rank)
+	return true
+}
+
+// Apply the global level, levelMu held.
+func (pm *ProjectInfrastructure) applyLevel(_rank int) uint64 {
+	pm.level = _rank
+	pm.levelGen++
+	loggerRank := _rank
+	for _, p := range pm.levelPatterns {
+		if p.rank > loggerRank {
+			loggerRank = p.rank
+		}
+	}
+	for _, r := range pm.moduleLevels {
+		if r > loggerRank {
+			loggerRank = r
+		}
+	}
+	pm.logger.SetLevel(rankLoggerLevel(loggerRank))
+	return pm.levelGen
+}
+
+// Change the global log level at runtime, for subsequent records. Module
+// levels and level patterns keep precedence.
+func (pm *ProjectInfrastructure) SetLogLevel(_level string) error {
+	rank, err := severityRank(_level)
+	if err != nil {
+		return err
+	}
+	pm.setLevel(rank)
+	return nil
+}
+
+// The current global log level.
+func (pm *ProjectInfrastructure) LogLevel() string {
+	return severityName(pm.currentLevel())
+}
+
+func (pm *ProjectInfrastructure) currentLevel() int {
+	pm.levelMu.RLock()
+	defer pm.levelMu.RUnlock()
+	return pm.level
+}
+
+func (pm *ProjectInfrastructure) initLevels(_opts ProjectInfrastructureOptions) error {
+	level, err := severityRank(_opts.LogLevel)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range _opts.LogLevelPatterns {
+		if _, err := path.Match(p.Pattern, ""); err != nil {
+			return errors.Wrapf(err, "invalid module pattern %s", p.Pattern)
+		}
+		rank, err := severityRank(p.Level)
+		if err != nil {
+			return errors.Wrapf(err, "module pattern %s", p.Pattern)
+		}
+		pm.levelPatterns = append(pm.levelPatterns, levelPattern{pattern: p.Pattern, rank: rank})
+	}
+	for _, pattern := range append(append([]string(nil), _opts.ModuleInclude...), _opts.ModuleExclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return errors.Wrapf(err, "invalid module filter %s", pattern)
+		}
+	}
+	pm.moduleLevels = make(map[string]int, len(_opts.ModuleLogLevels))
+	for module, l := range _opts.ModuleLogLevels {
+		rank, err := severityRank(l)
+		if err != nil {
+			return errors.Wrapf(err, "module %s", module)
+		}
+		pm.moduleLevels[module] = rank
+	}
+	pm.setLevel(level)
+	return nil
+}
+
+/*
+Temporarily change the global log level during a time window
+
+@level: log level <debug/info/warn/error> applied from "from" until "to"
+
+@from: start of the window, a time in the past applies the level immediately
+
+@to: end of the window, the level in effect at the start is restored unless
+the level was changed during the window
+*/
+func (pm *ProjectInfrastructure) ScheduleLogLevel(_level string, _from, _to time.Time) error {
+	if st := pm.State(); st != StateRunning {
+		return &StateError{Op: "ScheduleLogLevel()", State: st}
+	}
+	level, err := severityRank(_level)
+	if err != nil {
+		return err
+	}
+	if !_to.After(_from) || !_to.After(time.Now()) {
+		return errors.Errorf("invalid log level window %s - %s", _from.Format(time.RFC3339), _to.Format(time.RFC3339))
+	}
+
+	go func() {
+		select {
+		case <-time.After(time.Until(_from)):
+		case <-pm.cancel.Done():
+			return
+		}
+		previous := pm.currentLevel()
+		gen := pm.setLevel(level)
+		pm.logOutput("infra", "info", errors.Errorf("log level set to %s until %s", _level, _to.Format(time.RFC3339)), false, nil)
+
+		select {
+		case <-time.After(time.Until(_to)):
+		case <-pm.cancel.Done():
+			return
+		}
+		if pm.restoreLevel(previous, gen) {
+			pm.logOutput("infra", "info", errors.Errorf("log level window for %s ended, restoring %s", _level, severityName(previous)), false, nil)
+			return
+		}
+		pm.logOutput("infra", "info", errors.Errorf("log level window for %s ended, keeping %s set during it", _level, pm.LogLevel()), false, nil)
+	}()
+	return nil
+}
@@ -0,0 +1,48 @@
+package infrastructure
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestScheduleLogLevel(t *testing.T) {
+	tests := []struct {
+		name string
+		// Level set during the window, none when empty
+		set  string
+		want string
+	}{
+		{"restored", "", "info"},
+		{"changed during the window", "error", "error"},
+	}
+	for _, tt := range tests {
+		pm, err := NewProjectInfrastructure(context.Background(),
+			WithLibraryMode(true),
+			WithLogOutput("writer"),
+			WithLogWriter(io.Discard),
+			WithLogLevel("info"),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		to := time.Now().Add(300 * time.Millisecond)
+		if err := pm.ScheduleLogLevel("debug", time.Now(), to); err != nil {
+			t.Fatal(err)
+		}
+		for deadline := time.Now().Add(time.Second); pm.LogLevel() != "debug"; time.Sleep(5 * time.Millisecond) {
+			if time.Now().After(deadline) {
+				t.Fatalf("%s: window level not applied", tt.name)
+			}
+		}
+		if tt.set != "" {
+			pm.SetLogLevel(tt.set)
+		}
+		time.Sleep(time.Until(to) + 200*time.Millisecond)
+		if got := pm.LogLevel(); got != tt.want {
+			t.Errorf("%s: level %s after the window, want %s", tt.name, got, tt.want)
+		}
+		pm.Shutdown()
+	}
+}
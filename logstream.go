@@ -0,0 +1,124 @@
+package infrastructure
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// A log record as emitted by ErrorTransmit, independent of the output format.
+type LogRecord struct {
+	Time     time.Time              `json:"time"`
+	Module   string                 `json:"module"`
+	Severity string                 `json:"severity"`
+	Message  string                 `json:"message"`
+	Fields   map[string]interface{} `json:"fields,omitempty"`
+}
+
+// Capacity of a live stream subscriber, records are dropped for slow readers.
+const logStreamBuffer = 256
+
+// Fan out emitted records to live stream subscribers.
+type logBroadcaster struct {
+	mu   sync.RWMutex
+	subs map[chan LogRecord]struct{}
+}
+
+func (b *logBroadcaster) subscribe() chan LogRecord {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.subs == nil {
+		b.subs = make(map[chan LogRecord]struct{})
+	}
+	ch := make(chan LogRecord, logStreamBuffer)
+	b.subs[ch] = struct{}{}
+	return ch
+}
+
+func (b *logBroadcaster) unsubscribe(_ch chan LogRecord) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.subs, _ch)
+}
+
+func (b *logBroadcaster) active() bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.subs) > 0
+}
+
+func (b *logBroadcaster) publish(_record LogRecord) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for ch := range b.subs {
+		select {
+		case ch <- _record:
+		default:
+		}
+	}
+}
+
+/*
+Server-Sent Events handler streaming log records in real time, meant to be
+mounted at "/debug/logs/stream" of an admin server. Every event carries a
+LogRecord as JSON.
+
+@severity: query parameter, minimum severity to stream <debug/info/warn/error>
+
+@module: query parameter, module name or glob pattern to stream
+*/
+func (pm *ProjectInfrastructure) LogStreamHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+			return
+		}
+
+		minRank := math.MaxInt
+		if s := r.URL.Query().Get("severity"); s != "" {
+			rank, err := severityRank(s)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			minRank = rank
+		}
+		module := r.URL.Query().Get("module")
+
+		ch := pm.stream.subscribe()
+		defer pm.stream.unsubscribe(ch)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		for {
+			select {
+			case record := <-ch:
+				if rank, err := severityRank(record.Severity); err == nil && rank > minRank {
+					continue
+				}
+				if module != "" && !matchModulePattern(module, record.Module) {
+					continue
+				}
+				data, err := json.Marshal(record)
+				if err != nil {
+					continue
+				}
+				fmt.Fprintf(w, "data: %s\n\n", data)
+				flusher.Flush()
+			case <-r.Context().Done():
+				return
+			case <-pm.cancel.Done():
+				return
+			}
+		}
+	})
+}
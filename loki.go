@@ -0,0 +1,245 @@
+package infrastructure
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const _lokiPushPath = "/loki/api/v1/push"
+
+var _defaultLokiLabels = []string{"module", "severity", "hostname"}
+
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+// Push records to Grafana Loki in batches, grouped into streams by labels.
+type lokiWriter struct {
+	sink      *batchSink
+	labels    []string
+	hostname  string
+	batchSize int
+	// Push protobuf bodies, the snappy codec only exists for them
+	protobuf bool
+
+	mu      sync.Mutex
+	streams map[string]*lokiStream
+	count   int
+}
+
+func (pm *ProjectInfrastructure) openLoki(_opts ProjectInfrastructureOptions) (*lokiWriter, error) {
+	if _opts.LokiURL == "" {
+		return nil, errors.New("loki output needs a push url, see WithLoki")
+	}
+	labels := _opts.LokiLabels
+	if len(labels) == 0 {
+		labels = _defaultLokiLabels
+	}
+	static := make([]string, 0, len(_opts.StaticLabels))
+	for k := range _opts.StaticLabels {
+		static = append(static, k)
+	}
+	sort.Strings(static)
+	labels = append(append([]string(nil), labels...), static...)
+	wait := _opts.LokiBatchWait
+	if wait <= 0 {
+		wait = _defaultLokiBatchWait
+	}
+	codec, err := newCodec(_opts.LokiCompression, _opts.LokiCompressionLevel, _opts.CompressionCodecs)
+	if err != nil {
+		return nil, errors.Wrap(err, "loki output")
+	}
+	if codec != nil && !codecAccepted(codec.Name(), lokiCompressions) {
+		return nil, errors.Errorf("loki output: invalid compression %s, valid values are %s", codec.Name(), lokiCompressions)
+	}
+	hostname, _ := os.Hostname()
+
+	l := &lokiWriter{
+		labels:    labels,
+		hostname:  hostname,
+		batchSize: _opts.LokiBatchSize,
+		protobuf:  codec != nil && codec.Name() == "snappy",
+		streams:   make(map[string]*lokiStream),
+	}
+	l.sink = newBatchSink(pm, "loki", "push", strings.TrimSuffix(_opts.LokiURL, "/")+_lokiPushPath, l.take)
+	l.sink.codec = codec
+	ext := ".json"
+	if l.protobuf {
+		l.sink.contentType = "application/x-protobuf"
+		l.sink.implicitCodec = true
+		ext = ".pb"
+	}
+	if err := l.sink.openSpool(_opts, ext); err != nil {
+		return nil, err
+	}
+	l.sink.start(wait)
+	return l, nil
+}
+
+// Records waiting for the next push.
+func (l *lokiWriter) queued() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.count
+}
+
+func (l *lokiWriter) Write(_p []byte) (int, error) {
+	if err := l.WriteLevel(logrus.InfoLevel, _p); err != nil {
+		return 0, err
+	}
+	return len(_p), nil
+}
+
+func (l *lokiWriter) WriteLevel(_level logrus.Level, _p []byte) error {
+	l.add(l.streamLabels(logLevelName(_level), "", nil), time.Now(), _p)
+	return nil
+}
+
+func (l *lokiWriter) WriteEntry(_entry *logrus.Entry, _line []byte) error {
+	l.add(l.streamLabels(entrySeverity(_entry), entryModule(_entry), _entry.Data), _entry.Time, _line)
+	return nil
+}
+
+// Label values of a record, labels other than module, severity and hostname
+// are taken from the entry fields.
+func (l *lokiWriter) streamLabels(_severity, _module string, _fields logrus.Fields) map[string]string {
+	labels := make(map[string]string, len(l.labels))
+	for _, name := range l.labels {
+		var value string
+		switch name {
+		case "module":
+			value = _module
+		case "severity":
+			value = _severity
+		case "hostname":
+			value = l.hostname
+		default:
+			if v, ok := _fields[name]; ok {
+				value = strings.TrimSpace(stripANSI(toString(v)))
+			}
+		}
+		if value != "" {
+			labels[name] = value
+		}
+	}
+	return labels
+}
+
+func toString(_v interface{}) string {
+	if s, ok := _v.(string); ok {
+		return s
+	}
+	b, _ := json.Marshal(_v)
+	return string(b)
+}
+
+func (l *lokiWriter) add(_labels map[string]string, _t time.Time, _line []byte) {
+	names := make([]string, 0, len(_labels))
+	for k := range _labels {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var key strings.Builder
+	for _, k := range names {
+		key.WriteString(k + "=" + _labels[k] + "\x00")
+	}
+
+	l.mu.Lock()
+	s, ok := l.streams[key.String()]
+	if !ok {
+		s = &lokiStream{Stream: _labels}
+		l.streams[key.String()] = s
+	}
+	s.Values = append(s.Values, [2]string{strconv.FormatInt(_t.UnixNano(), 10), string(bytes.TrimRight(_line, "\n"))})
+	l.count++
+	full := l.count >= l.batchSize
+	l.mu.Unlock()
+
+	if full {
+		l.sink.notifyFull()
+	}
+}
+
+// Take the pending records as the body of a push.
+func (l *lokiWriter) take() ([]byte, int, error) {
+	l.mu.Lock()
+	streams := make([]*lokiStream, 0, len(l.streams))
+	for _, s := range l.streams {
+		streams = append(streams, s)
+	}
+	count := l.count
+	l.streams = make(map[string]*lokiStream)
+	l.count = 0
+	l.mu.Unlock()
+
+	if count == 0 {
+		return nil, 0, nil
+	}
+	if l.protobuf {
+		body, err := lokiProtobuf(streams)
+		return body, count, err
+	}
+	body, err := json.Marshal(map[string]interface{}{"streams": streams})
+	return body, count, err
+}
+
+/*
+Encode the streams as a logproto.PushRequest, the body of the snappy pushes
+
+	PushRequest   { repeated StreamAdapter streams = 1; }
+	StreamAdapter { string labels = 1; repeated EntryAdapter entries = 2; }
+	EntryAdapter  { google.protobuf.Timestamp timestamp = 1; string line = 2; }
+*/
+func lokiProtobuf(_streams []*lokiStream) ([]byte, error) {
+	var req []byte
+	for _, s := range _streams {
+		names := make([]string, 0, len(s.Stream))
+		for k := range s.Stream {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+		pairs := make([]string, len(names))
+		for i, k := range names {
+			pairs[i] = k + "=" + strconv.Quote(s.Stream[k])
+		}
+
+		stream := protoBytes(nil, 1, []byte("{"+strings.Join(pairs, ", ")+"}"))
+		for _, v := range s.Values {
+			ns, err := strconv.ParseInt(v[0], 10, 64)
+			if err != nil {
+				return nil, err
+			}
+			ts := protoVarint(nil, 1, uint64(ns/int64(time.Second)))
+			ts = protoVarint(ts, 2, uint64(ns%int64(time.Second)))
+			entry := protoBytes(nil, 1, ts)
+			entry = protoBytes(entry, 2, []byte(v[1]))
+			stream = protoBytes(stream, 2, entry)
+		}
+		req = protoBytes(req, 1, stream)
+	}
+	return req, nil
+}
+
+// Append a varint field, wire type 0.
+func protoVarint(_b []byte, _field int, _v uint64) []byte {
+	_b = binary.AppendUvarint(_b, uint64(_field)<<3)
+	return binary.AppendUvarint(_b, _v)
+}
+
+// Append a length-delimited field, wire type 2.
+func protoBytes(_b []byte, _field int, _v []byte) []byte {
+	_b = binary.AppendUvarint(_b, uint64(_field)<<3|2)
+	_b = binary.AppendUvarint(_b, uint64(len(_v)))
+	return append(_b, _v...)
+}
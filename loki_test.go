@@ -0,0 +1,136 @@
+package infrastructure
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/pkg/errors"
+)
+
+func TestOpenLokiCompression(t *testing.T) {
+	tests := []struct {
+		codec string
+		ok    bool
+	}{
+		{"", true},
+		{"gzip", true},
+		{"snappy", true},
+		{"zstd", false},
+		{"lz4", false},
+	}
+	for _, tt := range tests {
+		pm := &ProjectInfrastructure{}
+		pm.cancel, pm.cancelFunc = context.WithCancel(context.Background())
+		opts := DefaultOptions()
+		opts.LokiURL = "http://127.0.0.1:3100"
+		opts.LokiCompression = tt.codec
+		_, err := pm.openLoki(opts)
+		pm.cancelFunc()
+		if (err == nil) != tt.ok {
+			t.Errorf("compression %q: err = %v", tt.codec, err)
+		}
+	}
+}
+
+// Push one record through a loki output with the compression.
+func lokiPush(_t *testing.T, _codec string) capturedRequest {
+	srv, requests := captureServer(_t)
+	pm, err := NewProjectInfrastructure(context.Background(),
+		WithLibraryMode(true),
+		WithLogOutput("loki"),
+		WithLoki(srv.URL, "module", "severity"),
+		WithLokiCompression(_codec, ""),
+	)
+	if err != nil {
+		_t.Fatal(err)
+	}
+	defer pm.Shutdown()
+	pm.ErrorTransmit("db", "error", errors.New("connection refused"), false, false)
+	if errs := pm.Flush(); errs["loki"] != nil {
+		_t.Fatal(errs["loki"])
+	}
+	return nextRequest(_t, requests)
+}
+
+func TestLokiJSONPush(t *testing.T) {
+	tests := []struct {
+		codec, encoding string
+	}{
+		{"", ""},
+		{"gzip", "gzip"},
+	}
+	for _, tt := range tests {
+		r := lokiPush(t, tt.codec)
+		if r.path != _lokiPushPath || r.header.Get("Content-Type") != "application/json" || r.header.Get("Content-Encoding") != tt.encoding {
+			t.Fatalf("%q: push to %s as %s encoded %q", tt.codec, r.path, r.header.Get("Content-Type"), r.header.Get("Content-Encoding"))
+		}
+		body := r.body
+		if tt.encoding == "gzip" {
+			zr, err := gzip.NewReader(bytes.NewReader(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if body, err = io.ReadAll(zr); err != nil {
+				t.Fatal(err)
+			}
+		}
+		var push struct {
+			Streams []lokiStream `json:"streams"`
+		}
+		if err := json.Unmarshal(body, &push); err != nil {
+			t.Fatalf("%q: %v", tt.codec, err)
+		}
+		if len(push.Streams) != 1 || len(push.Streams[0].Values) != 1 {
+			t.Fatalf("%q: streams %+v", tt.codec, push.Streams)
+		}
+		s := push.Streams[0]
+		if s.Stream["module"] != "db" || s.Stream["severity"] != "error" || !strings.Contains(s.Values[0][1], "connection refused") {
+			t.Errorf("%q: stream %+v", tt.codec, s)
+		}
+	}
+}
+
+func TestLokiSnappyPush(t *testing.T) {
+	r := lokiPush(t, "snappy")
+	if r.header.Get("Content-Type") != "application/x-protobuf" || r.header.Get("Content-Encoding") != "" {
+		t.Fatalf("push as %s encoded %q", r.header.Get("Content-Type"), r.header.Get("Content-Encoding"))
+	}
+	body, err := snappy.Decode(nil, r.body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	streams := parseProto(t, body)
+	if len(streams) != 1 || streams[0].num != 1 {
+		t.Fatalf("push request %+v", streams)
+	}
+	var labels, line string
+	var seconds uint64
+	for _, f := range parseProto(t, streams[0].bytes) {
+		switch f.num {
+		case 1:
+			labels = string(f.bytes)
+		case 2:
+			for _, e := range parseProto(t, f.bytes) {
+				switch e.num {
+				case 1:
+					seconds = parseProto(t, e.bytes)[0].varint
+				case 2:
+					line = string(e.bytes)
+				}
+			}
+		}
+	}
+	if labels != `{module="db", severity="error"}` {
+		t.Errorf("labels %s", labels)
+	}
+	if !strings.Contains(line, "connection refused") || seconds == 0 {
+		t.Errorf("entry at %d: %s", seconds, line)
+	}
+}
@@ -0,0 +1,102 @@
+package infrastructure
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Mute and solo toggles last this long unless a TTL is given.
+var _defaultToggleTTL = 10 * time.Minute
+
+// Runtime module toggles, each expiring at its time.
+type moduleToggles struct {
+	mu    sync.RWMutex
+	muted map[string]time.Time
+	solo  map[string]time.Time
+}
+
+// Suppress the output of the module, a name or glob pattern, for the TTL,
+// 0 for the default of 10 minutes.
+func (pm *ProjectInfrastructure) MuteModule(_module string, _ttl time.Duration) error {
+	return pm.toggleModule(_module, _ttl, false)
+}
+
+// Suppress the output of every module but this one, a name or glob pattern,
+// for the TTL, 0 for the default of 10 minutes. Several modules can be solo.
+func (pm *ProjectInfrastructure) SoloModule(_module string, _ttl time.Duration) error {
+	return pm.toggleModule(_module, _ttl, true)
+}
+
+// Remove the mute and solo toggles of the module, of every module when empty.
+func (pm *ProjectInfrastructure) UnmuteModule(_module string) {
+	t := &pm.toggles
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _module == "" {
+		t.muted, t.solo = nil, nil
+		return
+	}
+	delete(t.muted, _module)
+	delete(t.solo, _module)
+}
+
+func (pm *ProjectInfrastructure) toggleModule(_module string, _ttl time.Duration, _solo bool) error {
+	if _module == "" {
+		return errors.New("module toggle needs a module")
+	}
+	if _ttl <= 0 {
+		_ttl = _defaultToggleTTL
+	}
+
+	t := &pm.toggles
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	toggles := &t.muted
+	if _solo {
+		toggles = &t.solo
+	}
+	if *toggles == nil {
+		*toggles = make(map[string]time.Time)
+	}
+	now := time.Now()
+	for module, expiry := range *toggles {
+		if !now.Before(expiry) {
+			delete(*toggles, module)
+		}
+	}
+	(*toggles)[_module] = now.Add(_ttl)
+	return nil
+}
+
+// Report whether a runtime toggle suppresses the module, expired toggles
+// are ignored.
+func (pm *ProjectInfrastructure) moduleMuted(_module string) bool {
+	t := &pm.toggles
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	if len(t.muted) == 0 && len(t.solo) == 0 {
+		return false
+	}
+	now := time.Now()
+	for pattern, expiry := range t.muted {
+		if now.Before(expiry) && matchModulePattern(pattern, _module) {
+			return true
+		}
+	}
+	soloActive := false
+	for pattern, expiry := range t.solo {
+		if !now.Before(expiry) {
+			continue
+		}
+		if matchModulePattern(pattern, _module) {
+			return false
+		}
+		soloActive = true
+	}
+	return soloActive
+}
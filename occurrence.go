@@ -0,0 +1,43 @@
+package infrastructure
+
+import (
+	"sync"
+	"time"
+)
+
+// Remember when each error fingerprint was last seen, to flag errors that
+// did not occur within the window.
+type occurrenceTracker struct {
+	mu        sync.Mutex
+	window    time.Duration
+	lastSeen  map[string]time.Time
+	lastSweep time.Time
+}
+
+func newOccurrenceTracker(_window time.Duration) *occurrenceTracker {
+	return &occurrenceTracker{
+		window:    _window,
+		lastSeen:  make(map[string]time.Time),
+		lastSweep: time.Now(),
+	}
+}
+
+// Record an occurrence and report whether it is the first one in the window.
+func (t *occurrenceTracker) observe(_fingerprint string, _now time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// Forget fingerprints that fell out of the window, at most once per window.
+	if _now.Sub(t.lastSweep) > t.window {
+		for fp, seen := range t.lastSeen {
+			if _now.Sub(seen) > t.window {
+				delete(t.lastSeen, fp)
+			}
+		}
+		t.lastSweep = _now
+	}
+
+	seen, ok := t.lastSeen[_fingerprint]
+	t.lastSeen[_fingerprint] = _now
+	return !ok || _now.Sub(seen) > t.window
+}
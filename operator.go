@@ -0,0 +1,89 @@
+package infrastructure
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// Operator actions, written to their own file when OperatorLogPath is set.
+type operatorLog struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+type operatorRecord struct {
+	Time   string `json:"timestamp"`
+	User   string `json:"user"`
+	Action string `json:"action"`
+	Target string `json:"target"`
+	Result string `json:"result"`
+	OK     bool   `json:"ok"`
+}
+
+func openOperatorLog(_path string) (*operatorLog, error) {
+	f, err := os.OpenFile(_path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, errors.Wrap(err, "open operator log")
+	}
+	return &operatorLog{file: f}, nil
+}
+
+func (o *operatorLog) close() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.file.Close()
+}
+
+/*
+Record an action taken by an operator, e.g. through the admin console
+
+@user: who acted, required
+
+@action: what was done, e.g. "admin.level", required
+
+@target: what it was applied to, required
+
+@result: the error of the action, nil on success
+*/
+func (pm *ProjectInfrastructure) OperatorAction(_user, _action, _target string, _result error) error {
+	if _user == "" || _action == "" || _target == "" {
+		return errors.Errorf("operator action needs a user, an action and a target, got %q %q %q", _user, _action, _target)
+	}
+	record := operatorRecord{
+		Time:   time.Now().Format(jsonTimestampFormat),
+		User:   _user,
+		Action: _action,
+		Target: _target,
+		Result: "ok",
+		OK:     _result == nil,
+	}
+	if _result != nil {
+		record.Result = _result.Error()
+	}
+
+	if pm.operatorLog == nil {
+		severity := "info"
+		if _result != nil {
+			severity = "warn"
+		}
+		pm.logOutput("operator", severity, errors.Errorf("%s %s %s: %s", _user, _action, _target, record.Result), false,
+			logrus.Fields{"user": _user, "action": _action, "target": _target, "ok": record.OK})
+		return nil
+	}
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return errors.Wrap(err, "marshal operator action")
+	}
+	pm.operatorLog.mu.Lock()
+	defer pm.operatorLog.mu.Unlock()
+	if _, err := pm.operatorLog.file.Write(append(line, '\n')); err != nil {
+		return errors.Wrap(err, "write operator log")
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package infrastructure
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestOperatorLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "operator.log")
+	pm, err := NewProjectInfrastructure(context.Background(), WithLibraryMode(true), WithOperatorLog(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		user, action, target string
+		result               error
+		ok                   bool
+	}{
+		{"alice", "admin.level", "debug", nil, true},
+		{"bob", "admin.restart", "db", errors.New("busy"), true},
+		{"", "admin.level", "debug", nil, false},
+	}
+	for _, tt := range tests {
+		if err := pm.OperatorAction(tt.user, tt.action, tt.target, tt.result); (err == nil) != tt.ok {
+			t.Errorf("OperatorAction(%q, %q, %q) err = %v", tt.user, tt.action, tt.target, err)
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d records, want 2", len(lines))
+	}
+	var record operatorRecord
+	if err := json.Unmarshal([]byte(lines[1]), &record); err != nil {
+		t.Fatal(err)
+	}
+	if record.User != "bob" || record.OK || record.Result != "busy" {
+		t.Errorf("unexpected record %+v", record)
+	}
+
+	if err := pm.Shutdown(); err != nil {
+		t.Fatal(err)
+	}
+	if err := pm.OperatorAction("alice", "admin.level", "info", nil); err == nil {
+		t.Error("operator log still open after shutdown")
+	}
+}
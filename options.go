@@ -1,36 +1,246 @@
 package infrastructure
 
+import (
+	"io"
+	"os"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
 var (
 	_defaultLogLevel    = "debug"
 	_defaultLogOut      = "stdout"
+	_defaultLogFormat   = "text"
+	_defaultLogColor    = "auto"
 	_defaultLogPath     = "./project.log"
 	_defaultMaxFileNum  = 10
 	_defaultMaxFileSize = 10485760
 	_defaultErrChanLen  = 20
+
+	_defaultSyslogFacility    = "user"
+	_defaultRemoteBufferSize  = 10000
+	_defaultRemoteMaxFailures = 5
+	_defaultSentrySeverity    = "warn"
+	_defaultGelfNetwork       = "udp"
+	_defaultLokiBatchSize     = 1000
+	_defaultLokiBatchWait     = time.Second
+	_defaultOTLPBatchSize     = 512
+	_defaultOTLPBatchWait     = time.Second
+	_defaultSpoolMaxSize      = int64(64 << 20)
+
+	_defaultFlushTimeout  = 5 * time.Second
+	_defaultSampleSummary = 10 * time.Second
+	_defaultWatchDebounce = 100 * time.Millisecond
+
+	_defaultAnomalyThreshold = 3.0
+
+	_defaultArchiveInterval     = time.Minute
+	_defaultCounterSaveInterval = time.Minute
+	_defaultDiskGuardInterval   = 10 * time.Second
+	_defaultEmergencyLogEntries = 100
+
+	_defaultShutdownTimeout  = 30 * time.Second
+	_defaultForceExitSignals = 2
 )
 
+// Default terminationMessagePath of Kubernetes containers.
+const KubernetesTerminationLog = "/dev/termination-log"
+
+// Kubernetes reads at most 4096 bytes of the termination log.
+const terminationLogMaxSize = 4096
+
 type OptionFunc func(*ProjectInfrastructureOptions)
 
 type ProjectInfrastructureOptions struct {
 	LogLevel       string
 	LogOut         string
+	LogFormat      string
+	SchemaVersion  int
 	LogPath        string
 	LogMaxFileNum  uint
 	LogMaxFileSize uint
+	LogWriter      io.Writer
+
+	OutputSeverities map[string][]string
+	OutputLevels     map[string]string
+	OutputFormats    map[string]string
+	LogRingBuffer    int
+
+	ParentLogForwarding bool
+
+	TimestampFormat string
+	TimestampUTC    bool
+	LogColor        string
+	LogColors       map[string]string
+
+	LogRotationPattern  string
+	LogRotationTime     time.Duration
+	LogMaxAge           time.Duration
+	LogMaxTotalSize     uint64
+	SeverityRetention   map[string]time.Duration
+	LogExternalRotation bool
+
+	LogArchiver        Archiver
+	ArchivePrefix      string
+	ArchiveCompression string
+	ArchiveInterval    time.Duration
+	ArchiveWindowStart string
+	ArchiveWindowEnd   string
+
+	ShippingBandwidth int
+
+	SpoolDir     string
+	SpoolMaxSize int64
+
+	CounterStore        CounterStore
+	CounterSaveInterval time.Duration
+
+	DiskMinFree         uint64
+	DiskGuardInterval   time.Duration
+	EmergencyLogPath    string
+	EmergencyLogEntries int
+
+	ModuleLogLevels  map[string]string
+	LogLevelPatterns []LogLevelPattern
+	ModuleInclude    []string
+	ModuleExclude    []string
+	StaticLabels     map[string]string
+	RedactPatterns   []string
+	RedactKeys       []string
+
+	Environment string
+	Region      string
+	Cluster     string
+
+	SyslogNetwork  string
+	SyslogAddress  string
+	SyslogFacility string
+	SyslogTag      string
+
+	EventLogSource string
+
+	RemoteNetwork     string
+	RemoteAddress     string
+	RemoteBufferSize  int
+	RemoteMaxFailures int
+
+	SentryDSN         string
+	SentryMinSeverity string
+
+	GelfNetwork string
+	GelfAddress string
+
+	LokiURL       string
+	LokiLabels    []string
+	LokiBatchSize int
+	LokiBatchWait time.Duration
+
+	LokiCompression      string
+	LokiCompressionLevel string
+	CompressionCodecs    []Codec
+
+	ServiceName string
+
+	OTLPEndpoint    string
+	OTLPServiceName string
+	OTLPHeaders     map[string]string
+	OTLPBatchSize   int
+	OTLPBatchWait   time.Duration
+
+	OTLPCompression      string
+	OTLPCompressionLevel string
+
+	DevMode bool
+
+	ErrorOrigin bool
+	ErrorCaller bool
+	StackDiff   bool
+
+	KnownIssuesFile string
+
+	FirstOccurrenceWindow time.Duration
+
+	SampleRate            int
+	SampleSummaryInterval time.Duration
+	DedupeWindow          time.Duration
+	ModuleBudgets         map[string]ModuleBudget
+
+	SelfTest bool
+
+	ConfigStatePath string
+
+	ConfigFile string
+
+	AdminSocket     string
+	IngestSocket    string
+	OperatorLogPath string
+
+	AuditLogPath     string
+	AuditMaxFileSize uint
+	AuditWriter      io.Writer
+
+	FlushTimeout time.Duration
+
+	ClockDriftServer    string
+	ClockDriftInterval  time.Duration
+	ClockDriftThreshold time.Duration
+	WatchDebounce       time.Duration
+	HostHealthInterval  time.Duration
+	AnomalyInterval     time.Duration
+	AnomalyThreshold    float64
+
+	ShutdownSignals []os.Signal
+	ShutdownTimeout time.Duration
+
+	ReleaseFailureExitCode int
+	ForceExitSignals       uint
+
+	LibraryMode   bool
+	ExitHandler   func(code int)
+	FatalToStderr bool
+
+	TerminationLogPath string
 
 	ErrChanLen uint
 
+	StrictOptions bool
+
+	IDGenerator func() string
+	LogrusHooks []logrus.Hook
+
 	ReleaseFunc func() error
 }
 
 func DefaultOptions() ProjectInfrastructureOptions {
 	return ProjectInfrastructureOptions{
-		LogLevel:       _defaultLogLevel,
-		LogOut:         _defaultLogOut,
-		LogPath:        _defaultLogPath,
-		LogMaxFileNum:  uint(_defaultMaxFileNum),
-		LogMaxFileSize: uint(_defaultMaxFileSize),
-		ErrChanLen:     uint(_defaultErrChanLen),
+		LogLevel:              _defaultLogLevel,
+		LogOut:                _defaultLogOut,
+		LogFormat:             _defaultLogFormat,
+		LogColor:              _defaultLogColor,
+		SampleSummaryInterval: _defaultSampleSummary,
+		SyslogFacility:        _defaultSyslogFacility,
+		RemoteBufferSize:      _defaultRemoteBufferSize,
+		RemoteMaxFailures:     _defaultRemoteMaxFailures,
+		SentryMinSeverity:     _defaultSentrySeverity,
+		GelfNetwork:           _defaultGelfNetwork,
+		LokiBatchSize:         _defaultLokiBatchSize,
+		LokiBatchWait:         _defaultLokiBatchWait,
+		OTLPBatchSize:         _defaultOTLPBatchSize,
+		OTLPBatchWait:         _defaultOTLPBatchWait,
+		LogPath:               _defaultLogPath,
+		LogMaxFileNum:         uint(_defaultMaxFileNum),
+		LogMaxFileSize:        uint(_defaultMaxFileSize),
+		ErrChanLen:            uint(_defaultErrChanLen),
+		FlushTimeout:          _defaultFlushTimeout,
+		WatchDebounce:         _defaultWatchDebounce,
+		AnomalyThreshold:      _defaultAnomalyThreshold,
+		ArchiveInterval:       _defaultArchiveInterval,
+		CounterSaveInterval:   _defaultCounterSaveInterval,
+		DiskGuardInterval:     _defaultDiskGuardInterval,
+		EmergencyLogEntries:   _defaultEmergencyLogEntries,
+		ShutdownTimeout:       _defaultShutdownTimeout,
+		ForceExitSignals:      uint(_defaultForceExitSignals),
 		ReleaseFunc: func() error {
 			return nil
 		},
@@ -43,31 +253,296 @@ func WithLogLevel(_level string) OptionFunc {
 	}
 }
 
-// Default output of logs to "stdout", or you can specify "file"
+// Default output of logs to "stdout", or you can specify "stderr", "file",
+// "syslog", "journald", "eventlog", "loki", "otlp", "gelf", "remote" or
+// "writer", or several outputs as "stdout,file" ("both" for short)
 func WithLogOutput(_out string) OptionFunc {
 	return func(o *ProjectInfrastructureOptions) {
 		o.LogOut = _out
 	}
 }
 
+// Write logs to the writer, e.g. a test buffer or a custom rotator. It is
+// the "writer" output, combine it with others as "stdout,writer".
+func WithLogWriter(_w io.Writer) OptionFunc {
+	return func(o *ProjectInfrastructureOptions) {
+		o.LogWriter = _w
+		o.LogOut = "writer"
+	}
+}
+
+// Route only the severities to the output, e.g. "warn" and "error" to
+// "stderr" and "debug" and "info" to "stdout". Outputs without routing get
+// every record.
+func WithOutputSeverities(_output string, _severities ...string) OptionFunc {
+	return func(o *ProjectInfrastructureOptions) {
+		if o.OutputSeverities == nil {
+			o.OutputSeverities = make(map[string][]string)
+		}
+		o.OutputSeverities[_output] = _severities
+	}
+}
+
+// Write only the records of the severity and the more severe ones to the
+// output, e.g. "debug" for "file", "info" for "loki" and "error" for
+// "stderr". It applies on top of the log level, records below it are never
+// emitted. Severities are compared by their logrus level. An output has
+// either routed severities or a minimum one.
+func WithSinkLevel(_output, _severity string) OptionFunc {
+	return func(o *ProjectInfrastructureOptions) {
+		if o.OutputLevels == nil {
+			o.OutputLevels = make(map[string]string)
+		}
+		o.OutputLevels[_output] = _severity
+	}
+}
+
+// Write the output in its own format instead of the log format, e.g. "text"
+// to "file" and "json" to "stdout" while parsers migrate from one to the
+// other. The text format of the output is never colored.
+func WithOutputFormat(_output, _format string) OptionFunc {
+	return func(o *ProjectInfrastructureOptions) {
+		if o.OutputFormats == nil {
+			o.OutputFormats = make(map[string]string)
+		}
+		o.OutputFormats[_output] = _format
+	}
+}
+
+// Write the json and logfmt records in an older schema version, so parsers
+// written for it keep working while the fleet upgrades, see
+// LogSchemaVersion and ConvertLogRecord. 0 follows the latest version. The
+// ECS format follows the Elastic Common Schema whatever the version.
+func WithSchemaVersion(_version int) OptionFunc {
+	return func(o *ProjectInfrastructureOptions) {
+		o.SchemaVersion = _version
+	}
+}
+
+// Keep the last size records in memory for TailLogs and TailHandler.
+func WithLogRingBuffer(_size int) OptionFunc {
+	return func(o *ProjectInfrastructureOptions) {
+		o.LogRingBuffer = _size
+	}
+}
+
+// Send the records to the supervising process over the pipe it passed in
+// INFRA_LOG_FD, see RestartPolicy.ForwardLogs, instead of writing the outputs.
+// Without the pipe the outputs are used as configured.
+func WithParentLogForwarding(_enable bool) OptionFunc {
+	return func(o *ProjectInfrastructureOptions) {
+		o.ParentLogForwarding = _enable
+	}
+}
+
+// Default format of logs is "text", or you can specify "json" with the
+// timestamp, severity, module and error fields, "logfmt" with the same
+// fields as key=value pairs, or "ecs" for the Elastic Common Schema.
+func WithLogFormat(_format string) OptionFunc {
+	return func(o *ProjectInfrastructureOptions) {
+		o.LogFormat = _format
+	}
+}
+
+// Layout of the record timestamps, e.g. time.RFC3339Nano, by default
+// "2006-01-02 15:04:05" for text and milliseconds RFC 3339 for json.
+func WithTimestampFormat(_layout string) OptionFunc {
+	return func(o *ProjectInfrastructureOptions) {
+		o.TimestampFormat = _layout
+	}
+}
+
+// Write the record timestamps in UTC instead of local time.
+func WithTimestampUTC(_utc bool) OptionFunc {
+	return func(o *ProjectInfrastructureOptions) {
+		o.TimestampUTC = _utc
+	}
+}
+
+// Color the module of stdout records or not, by default only when stdout is
+// a terminal.
+func WithLogColor(_enabled bool) OptionFunc {
+	return func(o *ProjectInfrastructureOptions) {
+		o.LogColor = "never"
+		if _enabled {
+			o.LogColor = "always"
+		}
+	}
+}
+
+// Color the module by severity, the colors are SGR parameters such as "31"
+// for red text or "97;41" for white on red, e.g. {"error": "97;41"}.
+func WithLogColorTheme(_colors map[string]string) OptionFunc {
+	return func(o *ProjectInfrastructureOptions) {
+		if o.LogColors == nil {
+			o.LogColors = make(map[string]string, len(_colors))
+		}
+		for k, v := range _colors {
+			o.LogColors[k] = v
+		}
+	}
+}
+
 func WithLogPath(_path string) OptionFunc {
 	return func(o *ProjectInfrastructureOptions) {
 		o.LogPath = _path
 	}
 }
 
+// Name the rotated files after a strftime pattern such as
+// "./project.%Y%m%d.log", the log path then links to the current file.
+func WithLogRotationPattern(_pattern string) OptionFunc {
+	return func(o *ProjectInfrastructureOptions) {
+		o.LogRotationPattern = _pattern
+	}
+}
+
+// Rotate the log file every interval, e.g. 24 * time.Hour for daily files,
+// in addition to the size limit. It needs a rotation pattern with a date.
+func WithLogRotationTime(_d time.Duration) OptionFunc {
+	return func(o *ProjectInfrastructureOptions) {
+		o.LogRotationTime = _d
+	}
+}
+
 func WithLogMaxFileNum(_num uint) OptionFunc {
 	return func(o *ProjectInfrastructureOptions) {
 		o.LogMaxFileNum = _num
 	}
 }
 
+// Remove rotated files older than the max age, in addition to keeping at
+// most LogMaxFileNum files.
+func WithLogMaxAge(_d time.Duration) OptionFunc {
+	return func(o *ProjectInfrastructureOptions) {
+		o.LogMaxAge = _d
+	}
+}
+
 func WithLogMaxFileSize(_size uint) OptionFunc {
 	return func(o *ProjectInfrastructureOptions) {
 		o.LogMaxFileSize = _size
 	}
 }
 
+// Write the records of the severity to their own file, named after the log
+// file with the severity as prefix, e.g. "./error.project.log", and remove
+// its rotated files older than the max age, e.g. error kept for 90 days and
+// debug for 3 days.
+func WithSeverityRetention(_severity string, _maxAge time.Duration) OptionFunc {
+	return func(o *ProjectInfrastructureOptions) {
+		if o.SeverityRetention == nil {
+			o.SeverityRetention = make(map[string]time.Duration)
+		}
+		o.SeverityRetention[_severity] = _maxAge
+	}
+}
+
+// Cap the disk usage of all log files, including the files split by
+// severity, the oldest rotated files are removed first on each rotation.
+// The current files are never removed.
+func WithLogMaxTotalSize(_bytes uint64) OptionFunc {
+	return func(o *ProjectInfrastructureOptions) {
+		o.LogMaxTotalSize = _bytes
+	}
+}
+
+// Leave the rotation of the log file to an external tool such as logrotate.
+// The file output writes a plain file at LogPath, the rotation options are
+// ignored, and the file is reopened on SIGHUP or by ReopenLogs.
+func WithExternalLogRotation(_enable bool) OptionFunc {
+	return func(o *ProjectInfrastructureOptions) {
+		o.LogExternalRotation = _enable
+	}
+}
+
+// Upload the rotated log files to the archiver under the prefix, e.g.
+// "logs/host-1", and remove them locally once stored. Files not compressed
+// yet are compressed with the codec, the best level, unless it is empty.
+func WithLogArchive(_archiver Archiver, _prefix, _compression string) OptionFunc {
+	return func(o *ProjectInfrastructureOptions) {
+		o.LogArchiver = _archiver
+		o.ArchivePrefix = _prefix
+		o.ArchiveCompression = _compression
+	}
+}
+
+// Time between two sweeps of the rotated log files to archive, 1m by default.
+func WithArchiveInterval(_interval time.Duration) OptionFunc {
+	return func(o *ProjectInfrastructureOptions) {
+		o.ArchiveInterval = _interval
+	}
+}
+
+// Keep the batches of the loki and otlp outputs on disk under the directory
+// until the sink accepted them, so devices offline for hours lose no records
+// and replay them in order, also after a restart. Beyond maxSize bytes, 64MiB
+// when 0, the oldest batches are dropped. Without spool up to 4MiB of batches
+// are kept in memory until accepted. The remote output has its fallback file
+// instead, see WithLogRemote.
+func WithSinkSpool(_dir string, _maxSize int64) OptionFunc {
+	return func(o *ProjectInfrastructureOptions) {
+		o.SpoolDir = _dir
+		o.SpoolMaxSize = _maxSize
+	}
+}
+
+// Upload the rotated log files only between start and end, local "15:04"
+// times, e.g. "22:00" to "06:00" for the off-peak hours of a metered link.
+// The files wait on disk until the window opens.
+func WithArchiveWindow(_start, _end string) OptionFunc {
+	return func(o *ProjectInfrastructureOptions) {
+		o.ArchiveWindowStart = _start
+		o.ArchiveWindowEnd = _end
+	}
+}
+
+// Cap the bytes per second shipped off the host by the loki, otlp and
+// remote outputs and the log archive together, 0 for no cap. Records wait in
+// the buffers of the outputs meanwhile. The gelf output writes within the
+// logging call and is not capped, as were its callers slowed down.
+func WithShippingBandwidth(_bytesPerSecond int) OptionFunc {
+	return func(o *ProjectInfrastructureOptions) {
+		o.ShippingBandwidth = _bytesPerSecond
+	}
+}
+
+// Restore the error total, the accumulated uptime and the restart count of
+// the previous runs from the store, e.g. NewFileCounterStore, and save them
+// every interval, 1m by default, and at shutdown. See PersistentCounters.
+func WithCounterPersistence(_store CounterStore, _interval time.Duration) OptionFunc {
+	return func(o *ProjectInfrastructureOptions) {
+		o.CounterStore = _store
+		if _interval > 0 {
+			o.CounterSaveInterval = _interval
+		}
+	}
+}
+
+// Check the free space of the log directory every interval, 10s if not
+// positive. Below minFree records under warn are dropped, below half of it
+// records under error, and the number dropped is logged once space is back.
+func WithDiskGuard(_minFree uint64, _interval time.Duration) OptionFunc {
+	return func(o *ProjectInfrastructureOptions) {
+		o.DiskMinFree = _minFree
+		if _interval > 0 {
+			o.DiskGuardInterval = _interval
+		}
+	}
+}
+
+// Keep the last entries error records, 100 if not positive, deflated in a
+// file preallocated at startup, so they survive a full disk. Read them with
+// ReadEmergencyLog.
+func WithEmergencyLog(_path string, _entries int) OptionFunc {
+	return func(o *ProjectInfrastructureOptions) {
+		o.EmergencyLogPath = _path
+		if _entries > 0 {
+			o.EmergencyLogEntries = _entries
+		}
+	}
+}
+
 func WithResourceRleaseFunc(_func func() error) OptionFunc {
 	return func(o *ProjectInfrastructureOptions) {
 		o.ReleaseFunc = _func
@@ -79,3 +554,478 @@ func WithErrChanLen(_len uint) OptionFunc {
 		o.ErrChanLen = _len
 	}
 }
+
+// Fail NewProjectInfrastructure on an unknown log output instead of warning
+// and falling back to stdout. An invalid log level is always an error.
+func WithStrictOptions(_enable bool) OptionFunc {
+	return func(o *ProjectInfrastructureOptions) {
+		o.StrictOptions = _enable
+	}
+}
+
+// Set the level of one module, e.g. "db" at debug while the global level is
+// warn. Takes precedence over WithLogLevelPattern.
+func WithModuleLogLevel(_module, _level string) OptionFunc {
+	return func(o *ProjectInfrastructureOptions) {
+		if o.ModuleLogLevels == nil {
+			o.ModuleLogLevels = make(map[string]string)
+		}
+		o.ModuleLogLevels[_module] = _level
+	}
+}
+
+// Set the level of every module matching the glob pattern, e.g. "app.db.*".
+// The most specific matching pattern wins, modules matching no pattern use
+// the global log level.
+func WithLogLevelPattern(_pattern, _level string) OptionFunc {
+	return func(o *ProjectInfrastructureOptions) {
+		o.LogLevelPatterns = append(o.LogLevelPatterns, LogLevelPattern{
+			Pattern: _pattern,
+			Level:   _level,
+		})
+	}
+}
+
+// Replace the matches of the regular expression in error messages and field
+// values with "[REDACTED]" before any output, e.g. `(?i)bearer [a-z0-9._-]+`.
+func WithRedactPattern(_pattern string) OptionFunc {
+	return func(o *ProjectInfrastructureOptions) {
+		o.RedactPatterns = append(o.RedactPatterns, _pattern)
+	}
+}
+
+// Replace the value of the fields with these names, in any case, with
+// "[REDACTED]", e.g. "password" or "token".
+func WithRedactKeys(_keys ...string) OptionFunc {
+	return func(o *ProjectInfrastructureOptions) {
+		o.RedactKeys = append(o.RedactKeys, _keys...)
+	}
+}
+
+// Only log the modules matching an include pattern, when any, and none of
+// the modules matching an exclude pattern. Patterns are names or globs such
+// as "app.db.*".
+func WithModuleFilter(_include, _exclude []string) OptionFunc {
+	return func(o *ProjectInfrastructureOptions) {
+		o.ModuleInclude = _include
+		o.ModuleExclude = _exclude
+	}
+}
+
+// Human-friendly stdout output for local development, can also be turned on
+// with INFRA_DEV_MODE=1. Ignored when logging to a file.
+func WithDevMode(_enable bool) OptionFunc {
+	return func(o *ProjectInfrastructureOptions) {
+		o.DevMode = _enable
+	}
+}
+
+// Attach the package and function where the error was created as
+// "origin_pkg" and "origin_func" fields, also when the stack is not printed.
+func WithErrorOrigin(_enable bool) OptionFunc {
+	return func(o *ProjectInfrastructureOptions) {
+		o.ErrorOrigin = _enable
+	}
+}
+
+// Attach the file and line calling ErrorTransmit as a "caller" field, so the
+// call site is found without printing the error chain.
+func WithErrorCaller(_enable bool) OptionFunc {
+	return func(o *ProjectInfrastructureOptions) {
+		o.ErrorCaller = _enable
+	}
+}
+
+// Print a recurring stack of the same module and error as the frames that
+// changed since it was last printed, with a "stack_ref" field naming the
+// full print it refers to.
+func WithStackDiff(_enable bool) OptionFunc {
+	return func(o *ProjectInfrastructureOptions) {
+		o.StackDiff = _enable
+	}
+}
+
+// Load a JSON list of KnownIssue entries whose errors are downgraded or
+// suppressed until they expire.
+func WithKnownIssues(_file string) OptionFunc {
+	return func(o *ProjectInfrastructureOptions) {
+		o.KnownIssuesFile = _file
+	}
+}
+
+// Add a "fingerprint" field to every record and flag with "first_occurrence"
+// the ones whose fingerprint was not seen within the window, e.g. 24h.
+func WithFirstOccurrence(_window time.Duration) OptionFunc {
+	return func(o *ProjectInfrastructureOptions) {
+		o.FirstOccurrenceWindow = _window
+	}
+}
+
+// Run SelfTest during NewProjectInfrastructure and fail on any error.
+func WithSelfTest(_enable bool) OptionFunc {
+	return func(o *ProjectInfrastructureOptions) {
+		o.SelfTest = _enable
+	}
+}
+
+// Persist the effective configuration to the file and log the differences
+// to the previous run at startup.
+func WithConfigStatePath(_path string) OptionFunc {
+	return func(o *ProjectInfrastructureOptions) {
+		o.ConfigStatePath = _path
+	}
+}
+
+// Serve a local admin console on the unix socket path, usable with
+// `nc -U <path>`. Send "help" for the list of commands.
+func WithAdminSocket(_path string) OptionFunc {
+	return func(o *ProjectInfrastructureOptions) {
+		o.AdminSocket = _path
+	}
+}
+
+// Total time allowed for flushing the registered providers on release.
+func WithFlushTimeout(_timeout time.Duration) OptionFunc {
+	return func(o *ProjectInfrastructureOptions) {
+		o.FlushTimeout = _timeout
+	}
+}
+
+// Shut down gracefully on the signals, e.g. os.Interrupt and syscall.SIGTERM.
+// No signal handler is installed unless this option is given.
+func WithShutdownSignals(_signals ...os.Signal) OptionFunc {
+	return func(o *ProjectInfrastructureOptions) {
+		o.ShutdownSignals = _signals
+	}
+}
+
+// Exit with the code instead of 0 after a shutdown in which a release hook
+// failed, and log a summary error of the failed hooks. The code is also
+// returned by ExitCode for programs that exit on their own.
+func WithReleaseFailureExitCode(_code int) OptionFunc {
+	return func(o *ProjectInfrastructureOptions) {
+		o.ReleaseFailureExitCode = _code
+	}
+}
+
+// Time allowed for the graceful shutdown started by a signal before forcing
+// exit, 0 waits for the shutdown without a limit.
+func WithShutdownTimeout(_timeout time.Duration) OptionFunc {
+	return func(o *ProjectInfrastructureOptions) {
+		o.ShutdownTimeout = _timeout
+	}
+}
+
+// Number of shutdown signals, the first one included, that forces an
+// immediate exit, 0 never forces it.
+func WithForceExitSignals(_count uint) OptionFunc {
+	return func(o *ProjectInfrastructureOptions) {
+		o.ForceExitSignals = _count
+	}
+}
+
+// Embed the package in an application that owns the process: no signal
+// handler is installed and os.Exit is never called, exits are only reported
+// to the exit handler.
+func WithLibraryMode(_enable bool) OptionFunc {
+	return func(o *ProjectInfrastructureOptions) {
+		o.LibraryMode = _enable
+	}
+}
+
+// Also write the records that exit the process to stderr, whatever the log
+// outputs are.
+func WithFatalToStderr(_enabled bool) OptionFunc {
+	return func(o *ProjectInfrastructureOptions) {
+		o.FatalToStderr = _enabled
+	}
+}
+
+// Write the error causing the exit to the file, e.g. KubernetesTerminationLog
+// so the pod status shows the failure reason.
+func WithTerminationLog(_path string) OptionFunc {
+	return func(o *ProjectInfrastructureOptions) {
+		o.TerminationLogPath = _path
+	}
+}
+
+// Called with the exit code instead of os.Exit, after resources are released.
+func WithExitHandler(_handler func(code int)) OptionFunc {
+	return func(o *ProjectInfrastructureOptions) {
+		o.ExitHandler = _handler
+	}
+}
+
+// Load option values from a JSON file keyed like "log_level", overridden by
+// INFRA_* environment variables and OptionFuncs.
+func WithConfigFile(_path string) OptionFunc {
+	return func(o *ProjectInfrastructureOptions) {
+		o.ConfigFile = _path
+	}
+}
+
+// Accept JSON log records from sidecars and child processes on the unix
+// socket path and route them through the same pipeline.
+func WithIngestSocket(_path string) OptionFunc {
+	return func(o *ProjectInfrastructureOptions) {
+		o.IngestSocket = _path
+	}
+}
+
+// Quiet period after the last change of a file before WatchPath handlers run.
+func WithWatchDebounce(_interval time.Duration) OptionFunc {
+	return func(o *ProjectInfrastructureOptions) {
+		o.WatchDebounce = _interval
+	}
+}
+
+// Check the system clock against the NTP server every interval and warn when
+// it drifts more than the threshold, e.g. ("pool.ntp.org", time.Hour, time.Second).
+func WithClockDriftMonitor(_server string, _interval, _threshold time.Duration) OptionFunc {
+	return func(o *ProjectInfrastructureOptions) {
+		o.ClockDriftServer = _server
+		o.ClockDriftInterval = _interval
+		o.ClockDriftThreshold = _threshold
+	}
+}
+
+// Learn the errors each module logs per interval and log a warning with the
+// "event" field "anomaly" when an interval exceeds the baseline by more than
+// threshold standard deviations, default 3.
+func WithAnomalyDetection(_interval time.Duration, _threshold float64) OptionFunc {
+	return func(o *ProjectInfrastructureOptions) {
+		o.AnomalyInterval = _interval
+		o.AnomalyThreshold = _threshold
+	}
+}
+
+// Syslog daemon used by the "syslog" output. An empty network and address
+// use the local daemon, facility is one of "user", "daemon", "local0".."local7"...
+// and an empty tag uses the program name.
+func WithSyslog(_network, _address, _facility, _tag string) OptionFunc {
+	return func(o *ProjectInfrastructureOptions) {
+		o.SyslogNetwork = _network
+		o.SyslogAddress = _address
+		o.SyslogFacility = _facility
+		o.SyslogTag = _tag
+	}
+}
+
+// Event source of the "eventlog" output on Windows, registered when the
+// process may do so. An empty source uses the program name.
+func WithEventLog(_source string) OptionFunc {
+	return func(o *ProjectInfrastructureOptions) {
+		o.EventLogSource = _source
+	}
+}
+
+// Run the registered host probes every interval and log their summaries.
+func WithHostHealthInterval(_interval time.Duration) OptionFunc {
+	return func(o *ProjectInfrastructureOptions) {
+		o.HostHealthInterval = _interval
+	}
+}
+
+// Loki server used by the "loki" output, e.g. "http://loki:3100". Records are
+// grouped into streams by the labels, "module", "severity", "hostname" or the
+// name of a record field, by default module, severity and hostname.
+func WithLoki(_url string, _labels ...string) OptionFunc {
+	return func(o *ProjectInfrastructureOptions) {
+		o.LokiURL = _url
+		o.LokiLabels = _labels
+	}
+}
+
+// Compress the Loki batches with "gzip" or "snappy", or a "deflate" codec added
+// with WithCompressionCodec. Snappy batches are pushed as protobuf, the others
+// as JSON, Loki accepts no other encoding and rejects "zstd". The level is
+// "fastest", "default" or "best", higher levels save bandwidth at the cost of
+// CPU, snappy has a single level.
+func WithLokiCompression(_codec, _level string) OptionFunc {
+	return func(o *ProjectInfrastructureOptions) {
+		o.LokiCompression = _codec
+		o.LokiCompressionLevel = _level
+	}
+}
+
+// Make a codec available to the sinks by its name, replacing the built-in
+// codec of the same name.
+func WithCompressionCodec(_codec Codec) OptionFunc {
+	return func(o *ProjectInfrastructureOptions) {
+		o.CompressionCodecs = append(o.CompressionCodecs, _codec)
+	}
+}
+
+// Push a Loki batch once it holds size records or wait has passed.
+func WithLokiBatch(_size int, _wait time.Duration) OptionFunc {
+	return func(o *ProjectInfrastructureOptions) {
+		o.LokiBatchSize = _size
+		o.LokiBatchWait = _wait
+	}
+}
+
+// Name of the service in the "ecs" format and the OTLP resource, the program
+// name when empty.
+func WithServiceName(_name string) OptionFunc {
+	return func(o *ProjectInfrastructureOptions) {
+		o.ServiceName = _name
+	}
+}
+
+// OpenTelemetry collector used by the "otlp" output, e.g. "http://otel:4318".
+// Records are exported as LogRecords of the service, ServiceName when
+// empty, with the headers added to every request, e.g. for authentication.
+func WithOTLP(_endpoint, _service string, _headers map[string]string) OptionFunc {
+	return func(o *ProjectInfrastructureOptions) {
+		o.OTLPEndpoint = _endpoint
+		o.OTLPServiceName = _service
+		o.OTLPHeaders = _headers
+	}
+}
+
+// Export an OTLP batch once it holds size records or wait has passed.
+func WithOTLPBatch(_size int, _wait time.Duration) OptionFunc {
+	return func(o *ProjectInfrastructureOptions) {
+		o.OTLPBatchSize = _size
+		o.OTLPBatchWait = _wait
+	}
+}
+
+// Compress the OTLP batches with "gzip" or "zstd", the encodings of the
+// OTLP/HTTP receivers, "snappy" is rejected. The level is "fastest",
+// "default" or "best", as for WithLokiCompression.
+func WithOTLPCompression(_codec, _level string) OptionFunc {
+	return func(o *ProjectInfrastructureOptions) {
+		o.OTLPCompression = _codec
+		o.OTLPCompressionLevel = _level
+	}
+}
+
+// Graylog input used by the "gelf" output, network is "udp" or "tcp".
+func WithGelf(_network, _address string) OptionFunc {
+	return func(o *ProjectInfrastructureOptions) {
+		o.GelfNetwork = _network
+		o.GelfAddress = _address
+	}
+}
+
+// Collector used by the "remote" output, network is "tcp" or "udp".
+func WithLogRemote(_network, _address string) OptionFunc {
+	return func(o *ProjectInfrastructureOptions) {
+		o.RemoteNetwork = _network
+		o.RemoteAddress = _address
+	}
+}
+
+// Lines buffered while the collector is unreachable, and the consecutive
+// failures after which lines go to the log file, 0 to never fall back.
+func WithLogRemoteBuffer(_size, _maxFailures int) OptionFunc {
+	return func(o *ProjectInfrastructureOptions) {
+		o.RemoteBufferSize = _size
+		o.RemoteMaxFailures = _maxFailures
+	}
+}
+
+// Labels added as fields to every record, e.g. service, env and instance, so
+// promtail can map them to Loki labels with a logfmt or json stage. The
+// "loki" output uses them as stream labels.
+func WithStaticLabels(_labels map[string]string) OptionFunc {
+	return func(o *ProjectInfrastructureOptions) {
+		if o.StaticLabels == nil {
+			o.StaticLabels = make(map[string]string, len(_labels))
+		}
+		for k, v := range _labels {
+			o.StaticLabels[k] = v
+		}
+	}
+}
+
+// Stamp every record with the env, region and cluster fields, empty values
+// are left out.
+func WithEnvironment(_env, _region, _cluster string) OptionFunc {
+	return func(o *ProjectInfrastructureOptions) {
+		o.Environment = _env
+		o.Region = _region
+		o.Cluster = _cluster
+	}
+}
+
+// Generate request IDs with the function, e.g. UUIDv7, ULID or snowflake IDs,
+// instead of random UUIDs.
+func WithIDGenerator(_fn func() string) OptionFunc {
+	return func(o *ProjectInfrastructureOptions) {
+		o.IDGenerator = _fn
+	}
+}
+
+// Log at most rate records per second of the same module and error, the
+// suppressed ones are counted in a summary every interval.
+func WithLogSampling(_rate int, _summaryInterval time.Duration) OptionFunc {
+	return func(o *ProjectInfrastructureOptions) {
+		o.SampleRate = _rate
+		o.SampleSummaryInterval = _summaryInterval
+	}
+}
+
+// Log identical module, severity and error records once per window, with the
+// number of repetitions when the window closes.
+func WithDedupeWindow(_window time.Duration) OptionFunc {
+	return func(o *ProjectInfrastructureOptions) {
+		o.DedupeWindow = _window
+	}
+}
+
+// Limit the debug output of a chatty module, e.g. "proto-decoder" at
+// ModuleBudget{Bytes: 1 << 20, Interval: time.Minute}. Other severities and
+// modules are not affected.
+func WithModuleBudget(_module string, _budget ModuleBudget) OptionFunc {
+	return func(o *ProjectInfrastructureOptions) {
+		if o.ModuleBudgets == nil {
+			o.ModuleBudgets = make(map[string]ModuleBudget)
+		}
+		o.ModuleBudgets[_module] = _budget
+	}
+}
+
+// Add a logrus hook to the logger, e.g. an existing Sentry or Elasticsearch
+// hook, it fires after the configured outputs.
+func WithLogrusHook(_hook logrus.Hook) OptionFunc {
+	return func(o *ProjectInfrastructureOptions) {
+		o.LogrusHooks = append(o.LogrusHooks, _hook)
+	}
+}
+
+// Report records at or above the severity, by default warn, to Sentry with
+// the module as tag and the error stack as stacktrace.
+func WithSentry(_dsn, _minSeverity string) OptionFunc {
+	return func(o *ProjectInfrastructureOptions) {
+		o.SentryDSN = _dsn
+		o.SentryMinSeverity = _minSeverity
+	}
+}
+
+// Write the events of AuditTransmit to their own file, rotated at the max
+// size independently of the log file, 0 to rotate daily only. Rotated audit
+// files are never removed.
+func WithAuditLog(_path string, _maxFileSize uint) OptionFunc {
+	return func(o *ProjectInfrastructureOptions) {
+		o.AuditLogPath = _path
+		o.AuditMaxFileSize = _maxFileSize
+	}
+}
+
+// Write the events of AuditTransmit to the writer instead of a file, e.g. a
+// connection to a dedicated audit collector.
+func WithAuditWriter(_w io.Writer) OptionFunc {
+	return func(o *ProjectInfrastructureOptions) {
+		o.AuditWriter = _w
+	}
+}
+
+// Write operator actions, such as admin console commands, as JSON lines to
+// their own file instead of the log outputs.
+func WithOperatorLog(_path string) OptionFunc {
+	return func(o *ProjectInfrastructureOptions) {
+		o.OperatorLogPath = _path
+	}
+}
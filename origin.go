@@ -0,0 +1,57 @@
+package infrastructure
+
+import (
+	"reflect"
+	"runtime"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Import path of this package, its frames are never reported as origin.
+var infraPkgPath = reflect.TypeOf(ProjectInfrastructure{}).PkgPath()
+
+type stackTracer interface {
+	StackTrace() errors.StackTrace
+}
+
+// Find the package and function where the error chain was created, taken
+// from the first non-infrastructure frame of the innermost pkg/errors stack.
+func errorOrigin(_err error) (pkg, fn string, ok bool) {
+	var st errors.StackTrace
+	for err := _err; err != nil; {
+		if tracer, isTracer := err.(stackTracer); isTracer {
+			st = tracer.StackTrace()
+		}
+		cause, isCauser := err.(interface{ Cause() error })
+		if !isCauser {
+			break
+		}
+		err = cause.Cause()
+	}
+
+	for _, frame := range st {
+		f := runtime.FuncForPC(uintptr(frame) - 1)
+		if f == nil {
+			continue
+		}
+		name := f.Name()
+		if strings.HasPrefix(name, "runtime.") || strings.HasPrefix(name, infraPkgPath+".") {
+			continue
+		}
+		pkg, fn = splitFuncName(name)
+		return pkg, fn, true
+	}
+	return "", "", false
+}
+
+// Split "github.com/a/b.(*T).Method" into "github.com/a/b" and "(*T).Method".
+func splitFuncName(_name string) (string, string) {
+	slash := strings.LastIndex(_name, "/")
+	dot := strings.Index(_name[slash+1:], ".")
+	if dot < 0 {
+		return "", _name
+	}
+	dot += slash + 1
+	return _name[:dot], _name[dot+1:]
+}
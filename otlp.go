@@ -0,0 +1,252 @@
+package infrastructure
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	_otlpLogsPath = "/v1/logs"
+	_otlpScope    = "github.com/just-lick-it/infrastructure"
+)
+
+// OTLP SeverityNumber of the levels, the first of each range.
+var otlpSeverities = map[logrus.Level]int{
+	logrus.TraceLevel: 1,
+	logrus.DebugLevel: 5,
+	logrus.InfoLevel:  9,
+	logrus.WarnLevel:  13,
+	logrus.ErrorLevel: 17,
+	logrus.FatalLevel: 21,
+	logrus.PanicLevel: 24,
+}
+
+type otlpValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpLogRecord struct {
+	TimeUnixNano         string          `json:"timeUnixNano"`
+	ObservedTimeUnixNano string          `json:"observedTimeUnixNano"`
+	SeverityNumber       int             `json:"severityNumber"`
+	SeverityText         string          `json:"severityText"`
+	Body                 otlpValue       `json:"body"`
+	Attributes           []otlpAttribute `json:"attributes,omitempty"`
+	TraceID              string          `json:"traceId,omitempty"`
+	SpanID               string          `json:"spanId,omitempty"`
+}
+
+// Export records as OpenTelemetry LogRecords to a collector, in batches over
+// OTLP/HTTP with the JSON encoding.
+type otlpWriter struct {
+	sink      *batchSink
+	resource  []otlpAttribute
+	batchSize int
+
+	mu      sync.Mutex
+	records []otlpLogRecord
+}
+
+func (pm *ProjectInfrastructure) openOTLP(_opts ProjectInfrastructureOptions) (*otlpWriter, error) {
+	if _opts.OTLPEndpoint == "" {
+		return nil, errors.New("otlp output needs a collector endpoint, see WithOTLP")
+	}
+	service := _opts.OTLPServiceName
+	if service == "" {
+		service = _opts.ServiceName
+	}
+	if service == "" {
+		service = filepath.Base(os.Args[0])
+	}
+	resource := []otlpAttribute{otlpAttr("service.name", service)}
+	if hostname, err := os.Hostname(); err == nil {
+		resource = append(resource, otlpAttr("host.name", hostname))
+	}
+	size := _opts.OTLPBatchSize
+	if size <= 0 {
+		size = _defaultOTLPBatchSize
+	}
+	wait := _opts.OTLPBatchWait
+	if wait <= 0 {
+		wait = _defaultOTLPBatchWait
+	}
+
+	codec, err := newCodec(_opts.OTLPCompression, _opts.OTLPCompressionLevel, _opts.CompressionCodecs)
+	if err != nil {
+		return nil, errors.Wrap(err, "otlp output")
+	}
+	if codec != nil && !codecAccepted(codec.Name(), otlpCompressions) {
+		return nil, errors.Errorf("otlp output: invalid compression %s, valid values are %s", codec.Name(), otlpCompressions)
+	}
+
+	o := &otlpWriter{
+		resource:  resource,
+		batchSize: size,
+	}
+	o.sink = newBatchSink(pm, "otlp", "export", strings.TrimSuffix(_opts.OTLPEndpoint, "/")+_otlpLogsPath, o.take)
+	o.sink.headers = _opts.OTLPHeaders
+	o.sink.codec = codec
+	if err := o.sink.openSpool(_opts, ".json"); err != nil {
+		return nil, err
+	}
+	o.sink.retryable = otlpRetryable
+	o.sink.start(wait)
+	return o, nil
+}
+
+func otlpAttr(_key string, _v interface{}) otlpAttribute {
+	var v otlpValue
+	switch x := _v.(type) {
+	case string:
+		v.StringValue = &x
+	case bool:
+		v.BoolValue = &x
+	case int:
+		s := strconv.Itoa(x)
+		v.IntValue = &s
+	case int64:
+		s := strconv.FormatInt(x, 10)
+		v.IntValue = &s
+	case uint64:
+		s := strconv.FormatUint(x, 10)
+		v.IntValue = &s
+	case float64:
+		v.DoubleValue = &x
+	default:
+		s := toString(x)
+		v.StringValue = &s
+	}
+	return otlpAttribute{Key: _key, Value: v}
+}
+
+// Trace and span IDs are taken from the "trace_id" and "span_id" fields when
+// they are hex strings of the OTLP length, otherwise they stay attributes.
+func otlpID(_v interface{}, _size int) (string, bool) {
+	s, ok := _v.(string)
+	if !ok || len(s) != 2*_size {
+		return "", false
+	}
+	if _, err := hex.DecodeString(s); err != nil {
+		return "", false
+	}
+	return strings.ToLower(s), true
+}
+
+// Records waiting for the next export.
+func (o *otlpWriter) queued() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return len(o.records)
+}
+
+func (o *otlpWriter) Write(_p []byte) (int, error) {
+	if err := o.WriteLevel(logrus.InfoLevel, _p); err != nil {
+		return 0, err
+	}
+	return len(_p), nil
+}
+
+func (o *otlpWriter) WriteLevel(_level logrus.Level, _p []byte) error {
+	o.add(o.record(_level, logLevelName(_level), time.Now(), string(bytes.TrimRight(_p, "\n")), "", nil))
+	return nil
+}
+
+func (o *otlpWriter) WriteEntry(_entry *logrus.Entry, _line []byte) error {
+	o.add(o.record(_entry.Level, entrySeverity(_entry), _entry.Time, stripANSI(_entry.Message), entryModule(_entry), _entry.Data))
+	return nil
+}
+
+func (o *otlpWriter) record(_level logrus.Level, _severity string, _t time.Time, _body, _module string, _fields logrus.Fields) otlpLogRecord {
+	r := otlpLogRecord{
+		TimeUnixNano:         strconv.FormatInt(_t.UnixNano(), 10),
+		ObservedTimeUnixNano: strconv.FormatInt(time.Now().UnixNano(), 10),
+		SeverityNumber:       otlpSeverities[_level],
+		SeverityText:         strings.ToUpper(_severity),
+		Body:                 otlpAttr("", _body).Value,
+	}
+	if _module != "" {
+		r.Attributes = append(r.Attributes, otlpAttr("module", _module))
+	}
+	keys := make([]string, 0, len(_fields))
+	for k := range _fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		switch k {
+		case "module", "severity":
+			continue
+		case "trace_id":
+			if id, ok := otlpID(_fields[k], 16); ok {
+				r.TraceID = id
+				continue
+			}
+		case "span_id":
+			if id, ok := otlpID(_fields[k], 8); ok {
+				r.SpanID = id
+				continue
+			}
+		}
+		r.Attributes = append(r.Attributes, otlpAttr(k, _fields[k]))
+	}
+	return r
+}
+
+func (o *otlpWriter) add(_record otlpLogRecord) {
+	o.mu.Lock()
+	o.records = append(o.records, _record)
+	full := len(o.records) >= o.batchSize
+	o.mu.Unlock()
+
+	if full {
+		o.sink.notifyFull()
+	}
+}
+
+// Take the pending records as the body of an export.
+func (o *otlpWriter) take() ([]byte, int, error) {
+	o.mu.Lock()
+	records := o.records
+	o.records = nil
+	o.mu.Unlock()
+
+	if len(records) == 0 {
+		return nil, 0, nil
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"resourceLogs": []interface{}{map[string]interface{}{
+			"resource": map[string]interface{}{"attributes": o.resource},
+			"scopeLogs": []interface{}{map[string]interface{}{
+				"scope":      map[string]string{"name": _otlpScope},
+				"logRecords": records,
+			}},
+		}},
+	})
+	return body, len(records), err
+}
+
+// Retryable statuses of the OTLP/HTTP specification
+func otlpRetryable(_status int) bool {
+	return _status == http.StatusTooManyRequests || _status == http.StatusBadGateway ||
+		_status == http.StatusServiceUnavailable || _status == http.StatusGatewayTimeout
+}
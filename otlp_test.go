@@ -0,0 +1,109 @@
+package infrastructure
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pkg/errors"
+)
+
+func TestOpenOTLPCompression(t *testing.T) {
+	tests := []struct {
+		codec string
+		ok    bool
+	}{
+		{"", true},
+		{"gzip", true},
+		{"zstd", true},
+		{"snappy", false},
+		{"lz4", false},
+	}
+	for _, tt := range tests {
+		pm := &ProjectInfrastructure{}
+		pm.cancel, pm.cancelFunc = context.WithCancel(context.Background())
+		opts := DefaultOptions()
+		opts.OTLPEndpoint = "http://127.0.0.1:4318"
+		opts.OTLPCompression = tt.codec
+		_, err := pm.openOTLP(opts)
+		pm.cancelFunc()
+		if (err == nil) != tt.ok {
+			t.Errorf("compression %q: err = %v", tt.codec, err)
+		}
+	}
+}
+
+// The body of an OTLP/HTTP JSON export.
+type otlpExport struct {
+	ResourceLogs []struct {
+		Resource struct {
+			Attributes []otlpAttribute `json:"attributes"`
+		} `json:"resource"`
+		ScopeLogs []struct {
+			Scope struct {
+				Name string `json:"name"`
+			} `json:"scope"`
+			LogRecords []otlpLogRecord `json:"logRecords"`
+		} `json:"scopeLogs"`
+	} `json:"resourceLogs"`
+}
+
+func TestOTLPCompressedExport(t *testing.T) {
+	tests := []struct {
+		codec      string
+		decompress func([]byte) ([]byte, error)
+	}{
+		{"", func(b []byte) ([]byte, error) { return b, nil }},
+		{"gzip", func(b []byte) ([]byte, error) {
+			zr, err := gzip.NewReader(bytes.NewReader(b))
+			if err != nil {
+				return nil, err
+			}
+			return io.ReadAll(zr)
+		}},
+		{"zstd", func(b []byte) ([]byte, error) {
+			dec, err := zstd.NewReader(nil)
+			if err != nil {
+				return nil, err
+			}
+			defer dec.Close()
+			return dec.DecodeAll(b, nil)
+		}},
+	}
+	for _, tt := range tests {
+		srv, requests := captureServer(t)
+		pm, err := NewProjectInfrastructure(context.Background(),
+			WithLibraryMode(true),
+			WithLogOutput("otlp"),
+			WithOTLP(srv.URL, "billing", nil),
+			WithOTLPCompression(tt.codec, "best"),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pm.ErrorTransmit("db", "warn", errors.New("slow query"), false, false)
+		pm.Flush()
+		r := nextRequest(t, requests)
+		pm.Shutdown()
+
+		if r.path != _otlpLogsPath || r.header.Get("Content-Encoding") != tt.codec {
+			t.Fatalf("%q: export to %s encoded %q", tt.codec, r.path, r.header.Get("Content-Encoding"))
+		}
+		body, err := tt.decompress(r.body)
+		if err != nil {
+			t.Fatalf("%q: %v", tt.codec, err)
+		}
+		var export otlpExport
+		if err := json.Unmarshal(body, &export); err != nil {
+			t.Fatalf("%q: %v", tt.codec, err)
+		}
+		records := export.ResourceLogs[0].ScopeLogs[0].LogRecords
+		if len(records) != 1 || records[0].SeverityText != "WARN" || records[0].SeverityNumber != 13 {
+			t.Errorf("%q: records %+v", tt.codec, records)
+		}
+	}
+}
@@ -0,0 +1,367 @@
+package infrastructure
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	filerotatelogs "github.com/lestrrat-go/file-rotatelogs"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+var supportLogOutputs = []string{"stdout", "stderr", "file", "syslog", "journald", "eventlog", "loki", "otlp", "gelf", "remote", "writer"}
+
+// A configured log destination.
+type logOutputWriter struct {
+	name string
+	w    io.Writer
+}
+
+// Outputs that map severities natively, they are always written by a hook.
+type leveledWriter interface {
+	io.Writer
+	WriteLevel(level logrus.Level, p []byte) error
+}
+
+// Outputs that keep the entry fields, such as the module, apart from the line.
+type entryWriter interface {
+	WriteEntry(entry *logrus.Entry, line []byte) error
+}
+
+type moduleKey struct{}
+
+// The record of an entry, carried in its context for the outputs with their
+// own format.
+type recordKey struct{}
+
+// An output written in another format than the logger one, see WithOutputFormat.
+type outputFormat struct {
+	opts     ProjectInfrastructureOptions
+	hostname string
+}
+
+func newOutputFormat(_opts ProjectInfrastructureOptions, _format string) (*outputFormat, error) {
+	valid := false
+	for _, f := range supportLogFormats {
+		valid = valid || f == _format
+	}
+	if !valid {
+		return nil, errors.Errorf("invalid log format %s, valid values are %s", _format, supportLogFormats)
+	}
+	opts := _opts
+	opts.LogFormat = _format
+	opts.LogColor = "never"
+	if opts.ServiceName == "" {
+		opts.ServiceName = filepath.Base(os.Args[0])
+	}
+	hostname, _ := os.Hostname()
+	return &outputFormat{opts: opts, hostname: hostname}, nil
+}
+
+// Format the entry from its record, false for a nil format and for entries
+// without a record, such as those of the logger itself, left to the logger
+// formatter.
+func (f *outputFormat) format(_entry *logrus.Entry) ([]byte, bool, error) {
+	if f == nil || _entry.Context == nil {
+		return nil, false, nil
+	}
+	record, ok := _entry.Context.Value(recordKey{}).(Entry)
+	if !ok {
+		return nil, false, nil
+	}
+	record.Time = _entry.Time
+	record.Hostname = f.hostname
+	// The structured formats add the module themselves, text has it in the message.
+	data := make(logrus.Fields, len(_entry.Data))
+	for k, v := range _entry.Data {
+		if k != "module" {
+			data[k] = v
+		}
+	}
+	line, err := formatRecord(f.opts, record, data)
+	return line, true, err
+}
+
+// Module of an entry, a field of the structured formats or otherwise carried
+// in the entry context.
+func entryModule(_entry *logrus.Entry) string {
+	if m, ok := _entry.Data["module"].(string); ok {
+		return m
+	}
+	if _entry.Context != nil {
+		if m, ok := _entry.Context.Value(moduleKey{}).(string); ok {
+			return m
+		}
+	}
+	return ""
+}
+
+// Split the LogOut value, a single output, a comma separated list such as
+// "stdout,file", or "both" for stdout and file. stdout always comes first.
+func parseLogOutputs(_out string) []string {
+	if _out == "both" {
+		_out = "stdout,file"
+	}
+
+	var outputs []string
+	seen := make(map[string]bool)
+	for _, o := range strings.Split(_out, ",") {
+		o = strings.TrimSpace(o)
+		if o == "" || seen[o] {
+			continue
+		}
+		seen[o] = true
+		if o == "stdout" {
+			outputs = append([]string{o}, outputs...)
+		} else {
+			outputs = append(outputs, o)
+		}
+	}
+	return outputs
+}
+
+// Open the writer of an output, nil for an unknown output.
+func (pm *ProjectInfrastructure) openLogOutput(_name string, _opts ProjectInfrastructureOptions) (io.Writer, error) {
+	switch _name {
+	case "stdout":
+		return os.Stdout, nil
+	case "stderr":
+		return os.Stderr, nil
+	case "file":
+		return pm.openFileOutput(_opts)
+	case "syslog":
+		return openSyslog(_opts)
+	case "journald":
+		return openJournald(_opts)
+	case "eventlog":
+		return openEventLog(_opts)
+	case "loki":
+		return pm.openLoki(_opts)
+	case "otlp":
+		return pm.openOTLP(_opts)
+	case "gelf":
+		return openGelf(_opts)
+	case "remote":
+		return pm.openRemote(_opts)
+	case "writer":
+		if _opts.LogWriter == nil {
+			return nil, errors.New("writer output needs a writer, see WithLogWriter")
+		}
+		return _opts.LogWriter, nil
+	}
+	return nil, nil
+}
+
+// Open the writer of the file output once, the remote fallback shares it so
+// that a single writer appends to and rotates LogPath.
+func (pm *ProjectInfrastructure) openFileOutput(_opts ProjectInfrastructureOptions) (io.Writer, error) {
+	if pm.logFile != nil {
+		return pm.logFile, nil
+	}
+	var w io.Writer
+	var err error
+	if len(_opts.SeverityRetention) > 0 {
+		w, err = openSeverityFiles(_opts)
+	} else {
+		w, err = openLogFile(_opts)
+	}
+	if err != nil {
+		return nil, err
+	}
+	pm.logFile = w
+	return w, nil
+}
+
+// Open the rotated log file. With a rotation pattern the files are named
+// after it, e.g. "./project.%Y%m%d.log", and LogPath links to the current one.
+// With an external rotation it is a plain file at LogPath.
+func openLogFile(_opts ProjectInfrastructureOptions) (io.Writer, error) {
+	return openRotatedFile(_opts, logFileGlobs(_opts))
+}
+
+// Open a rotated log file whose rotations cap the total size of the files
+// matching the globs, those of the log file and of the files split by severity.
+func openRotatedFile(_opts ProjectInfrastructureOptions, _capGlobs []string) (io.Writer, error) {
+	if _opts.LogExternalRotation {
+		return openReopenFile(_opts.LogPath)
+	}
+	pattern := _opts.LogPath
+	rotateOpts := []filerotatelogs.Option{
+		filerotatelogs.WithRotationCount(uint(_opts.LogMaxFileNum)),
+		filerotatelogs.WithRotationSize(int64(_opts.LogMaxFileSize)),
+	}
+	if _opts.LogRotationPattern != "" {
+		pattern = _opts.LogRotationPattern
+		rotateOpts = append(rotateOpts, filerotatelogs.WithLinkName(_opts.LogPath))
+	}
+	if _opts.LogRotationTime > 0 {
+		rotateOpts = append(rotateOpts, filerotatelogs.WithRotationTime(_opts.LogRotationTime))
+	}
+	// rotatelogs refuses a max age along with a rotation count, prune by age
+	// on each rotation instead.
+	pruneAge := _opts.LogMaxAge > 0 && _opts.LogMaxFileNum > 0
+	if _opts.LogMaxAge > 0 && !pruneAge {
+		rotateOpts = append(rotateOpts, filerotatelogs.WithMaxAge(_opts.LogMaxAge))
+	}
+	if pruneAge || _opts.LogMaxTotalSize > 0 {
+		glob := logFileGlob(_opts)
+		prune := func(_current string) {
+			if pruneAge {
+				pruneLogFiles(glob, _current, _opts.LogMaxAge)
+			}
+			if _opts.LogMaxTotalSize > 0 {
+				capLogFiles(_capGlobs, _current, _opts.LogMaxTotalSize)
+			}
+		}
+		rotateOpts = append(rotateOpts, filerotatelogs.WithHandler(filerotatelogs.HandlerFunc(func(e filerotatelogs.Event) {
+			if r, ok := e.(*filerotatelogs.FileRotatedEvent); ok {
+				prune(r.CurrentFile())
+			}
+		})))
+		prune("")
+	}
+	return filerotatelogs.New(pattern, rotateOpts...)
+}
+
+var strftimeVerb = regexp.MustCompile(`%[%+A-Za-z]`)
+
+// Remove the files last modified before the max age, but the current one.
+func pruneLogFiles(_glob, _current string, _maxAge time.Duration) {
+	matches, err := filepath.Glob(_glob)
+	if err != nil {
+		return
+	}
+	cutoff := time.Now().Add(-_maxAge)
+	for _, path := range matches {
+		if path == _current || strings.HasSuffix(path, "_lock") || strings.HasSuffix(path, "_symlink") {
+			continue
+		}
+		fi, err := os.Lstat(path)
+		if err != nil || !fi.Mode().IsRegular() || fi.ModTime().After(cutoff) {
+			continue
+		}
+		os.Remove(path)
+	}
+}
+
+// Remove the oldest files, but the current ones, until all of them fit in
+// the total size. The newest file of a glob other than the rotating one is
+// the current file of its writer.
+func capLogFiles(_globs []string, _current string, _maxTotal uint64) {
+	type logFile struct {
+		path string
+		info os.FileInfo
+	}
+	var files []logFile
+	var total uint64
+	for _, glob := range _globs {
+		matches, err := filepath.Glob(glob)
+		if err != nil {
+			continue
+		}
+		var globFiles []logFile
+		rotating := false
+		for _, path := range matches {
+			if strings.HasSuffix(path, "_lock") || strings.HasSuffix(path, "_symlink") {
+				continue
+			}
+			fi, err := os.Lstat(path)
+			if err != nil || !fi.Mode().IsRegular() {
+				continue
+			}
+			total += uint64(fi.Size())
+			if path == _current {
+				rotating = true
+				continue
+			}
+			globFiles = append(globFiles, logFile{path: path, info: fi})
+		}
+		sort.Slice(globFiles, func(i, j int) bool { return globFiles[i].info.ModTime().Before(globFiles[j].info.ModTime()) })
+		if !rotating && len(globFiles) > 0 {
+			globFiles = globFiles[:len(globFiles)-1]
+		}
+		files = append(files, globFiles...)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].info.ModTime().Before(files[j].info.ModTime()) })
+	for _, f := range files {
+		if total <= _maxTotal {
+			return
+		}
+		if os.Remove(f.path) == nil {
+			total -= uint64(f.info.Size())
+		}
+	}
+}
+
+// The logrus levels of the level and the more severe ones.
+func levelsAtLeast(_floor logrus.Level) []logrus.Level {
+	var levels []logrus.Level
+	for _, l := range logrus.AllLevels {
+		if l <= _floor {
+			levels = append(levels, l)
+		}
+	}
+	return levels
+}
+
+func (pm *ProjectInfrastructure) hasLogOutput(_name string) bool {
+	for _, o := range pm.outputs {
+		if o.name == _name {
+			return true
+		}
+	}
+	return false
+}
+
+// A color sequence and the space padding it, so stripped text lines up
+// like the plain file format.
+var ansiEscape = regexp.MustCompile("\x1b\\[[0-9;]*m ?")
+
+func stripANSI(_s string) string {
+	return ansiEscape.ReplaceAllString(_s, "")
+}
+
+// Write every entry to an additional output, or the entries of its levels
+// when it is routed by severity. The logger formats for its own output only,
+// so the entry is formatted again here, without colors.
+type outputHook struct {
+	w           io.Writer
+	levels      []logrus.Level
+	stripColors bool
+	// Format of the output when it differs from the logger one
+	format *outputFormat
+}
+
+func (h *outputHook) Levels() []logrus.Level {
+	if len(h.levels) > 0 {
+		return h.levels
+	}
+	return logrus.AllLevels
+}
+
+func (h *outputHook) Fire(_entry *logrus.Entry) error {
+	entry := *_entry
+	if h.stripColors {
+		entry.Message = stripANSI(entry.Message)
+	}
+	line, formatted, err := h.format.format(&entry)
+	if !formatted {
+		line, err = entry.Logger.Formatter.Format(&entry)
+	}
+	if err != nil {
+		return err
+	}
+	if ew, ok := h.w.(entryWriter); ok {
+		return ew.WriteEntry(&entry, line)
+	}
+	if lw, ok := h.w.(leveledWriter); ok {
+		return lw.WriteLevel(entry.Level, line)
+	}
+	_, err = h.w.Write(line)
+	return err
+}
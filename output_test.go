@@ -0,0 +1,203 @@
+package infrastructure
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func writeAgedFile(_t *testing.T, _path string, _size int, _age time.Duration) {
+	if err := os.WriteFile(_path, make([]byte, _size), 0644); err != nil {
+		_t.Fatal(err)
+	}
+	mtime := time.Now().Add(-_age)
+	if err := os.Chtimes(_path, mtime, mtime); err != nil {
+		_t.Fatal(err)
+	}
+}
+
+func remainingFiles(_t *testing.T, _dir string) map[string]bool {
+	entries, err := os.ReadDir(_dir)
+	if err != nil {
+		_t.Fatal(err)
+	}
+	files := make(map[string]bool)
+	for _, e := range entries {
+		files[e.Name()] = true
+	}
+	return files
+}
+
+func TestParseLogOutputs(t *testing.T) {
+	tests := []struct {
+		out  string
+		want []string
+	}{
+		{"stdout", []string{"stdout"}},
+		{"both", []string{"stdout", "file"}},
+		{"file, stdout", []string{"stdout", "file"}},
+		{"remote,file,remote", []string{"remote", "file"}},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		got := parseLogOutputs(tt.out)
+		if len(got) != len(tt.want) {
+			t.Errorf("parseLogOutputs(%q) = %v, want %v", tt.out, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("parseLogOutputs(%q) = %v, want %v", tt.out, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestPruneLogFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeAgedFile(t, filepath.Join(dir, "project.log.1"), 10, 48*time.Hour)
+	writeAgedFile(t, filepath.Join(dir, "project.log.2"), 10, time.Hour)
+	writeAgedFile(t, filepath.Join(dir, "project.log"), 10, 72*time.Hour)
+	writeAgedFile(t, filepath.Join(dir, "project.log_lock"), 0, 72*time.Hour)
+
+	pruneLogFiles(filepath.Join(dir, "project.log*"), filepath.Join(dir, "project.log"), 24*time.Hour)
+
+	files := remainingFiles(t, dir)
+	want := map[string]bool{"project.log": true, "project.log.2": true, "project.log_lock": true}
+	for name := range files {
+		if !want[name] {
+			t.Errorf("%s not removed", name)
+		}
+	}
+	for name := range want {
+		if !files[name] {
+			t.Errorf("%s removed", name)
+		}
+	}
+}
+
+func TestCapLogFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeAgedFile(t, filepath.Join(dir, "project.log.1"), 100, 5*time.Hour)
+	writeAgedFile(t, filepath.Join(dir, "project.log.2"), 100, 3*time.Hour)
+	writeAgedFile(t, filepath.Join(dir, "project.log"), 100, time.Minute)
+	writeAgedFile(t, filepath.Join(dir, "error.project.log.1"), 100, 4*time.Hour)
+	writeAgedFile(t, filepath.Join(dir, "error.project.log"), 100, 2*time.Hour)
+
+	globs := []string{filepath.Join(dir, "project.log*"), filepath.Join(dir, "error.project.log*")}
+	capLogFiles(globs, filepath.Join(dir, "project.log"), 250)
+
+	// The oldest rotated files of all globs go first, the current file of
+	// each glob stays.
+	files := remainingFiles(t, dir)
+	want := map[string]bool{"project.log": true, "error.project.log": true}
+	if len(files) != len(want) {
+		t.Errorf("remaining files %v, want %v", files, want)
+	}
+	for name := range want {
+		if !files[name] {
+			t.Errorf("%s removed", name)
+		}
+	}
+}
+
+func TestSinkLevel(t *testing.T) {
+	tests := []struct {
+		floor string
+		want  map[string]bool
+	}{
+		{"debug", map[string]bool{"verbose detail": true, "disk nearly full": true, "write failed": true}},
+		{"warn", map[string]bool{"verbose detail": false, "disk nearly full": true, "write failed": true}},
+		{"error", map[string]bool{"verbose detail": false, "disk nearly full": false, "write failed": true}},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		pm, err := NewProjectInfrastructure(context.Background(),
+			WithLibraryMode(true),
+			WithLogOutput("writer"),
+			WithLogWriter(&buf),
+			WithLogLevel("debug"),
+			WithSinkLevel("writer", tt.floor),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pm.ErrorTransmit("disk", "debug", errors.New("verbose detail"), false, false)
+		pm.ErrorTransmit("disk", "warn", errors.New("disk nearly full"), false, false)
+		pm.ErrorTransmit("disk", "error", errors.New("write failed"), false, false)
+		for msg, want := range tt.want {
+			if got := strings.Contains(buf.String(), msg); got != want {
+				t.Errorf("floor %s: %q written = %v, want %v", tt.floor, msg, got, want)
+			}
+		}
+		pm.Shutdown()
+	}
+}
+
+func TestSinkLevelConflict(t *testing.T) {
+	_, err := NewProjectInfrastructure(context.Background(),
+		WithLibraryMode(true),
+		WithLogOutput("writer"),
+		WithLogWriter(io.Discard),
+		WithOutputSeverities("writer", "error"),
+		WithSinkLevel("writer", "warn"),
+	)
+	if err == nil {
+		t.Fatal("both routed severities and a minimum severity accepted")
+	}
+}
+
+func TestOutputFormatTee(t *testing.T) {
+	var buf bytes.Buffer
+	path := filepath.Join(t.TempDir(), "project.log")
+	pm, err := NewProjectInfrastructure(context.Background(),
+		WithLibraryMode(true),
+		WithLogWriter(&buf),
+		WithLogOutput("writer,file"),
+		WithLogPath(path),
+		WithOutputFormat("file", "json"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pm.ErrorTransmitFields("db", "warn", errors.New("slow query"), map[string]interface{}{"table": "users"}, false, false)
+
+	text := buf.String()
+	if !strings.Contains(text, "db") || !strings.Contains(text, "slow query") || strings.HasPrefix(text, "{") {
+		t.Errorf("writer not in the text format:\n%s", text)
+	}
+	var from time.Time
+	var out bytes.Buffer
+	if err := pm.ExportLogs(from, time.Now().Add(time.Minute), &out); err != nil {
+		t.Fatal(err)
+	}
+	var record map[string]interface{}
+	if err := json.Unmarshal(out.Bytes(), &record); err != nil {
+		t.Fatalf("file not in the json format: %v\n%s", err, out.String())
+	}
+	want := map[string]interface{}{"module": "db", "severity": "warn", "error": "slow query", "table": "users"}
+	for k, v := range want {
+		if record[k] != v {
+			t.Errorf("%s = %v, want %v", k, record[k], v)
+		}
+	}
+	pm.Shutdown()
+
+	_, err = NewProjectInfrastructure(context.Background(),
+		WithLibraryMode(true),
+		WithLogWriter(io.Discard),
+		WithOutputFormat("writer", "xml"),
+	)
+	if err == nil {
+		t.Fatal("invalid output format accepted")
+	}
+}
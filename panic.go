@@ -0,0 +1,55 @@
+package infrastructure
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"runtime/debug"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// Describe a recovered panic value as fields: its type, its message and, for
+// structs and maps, their content, along with the stack of the goroutine.
+func panicFields(_r interface{}) logrus.Fields {
+	fields := logrus.Fields{
+		"panic_type": fmt.Sprintf("%T", _r),
+		"stack":      string(debug.Stack()),
+	}
+
+	switch v := _r.(type) {
+	case error:
+		fields["panic_value"] = v.Error()
+		if cause := errors.Cause(v); cause != v {
+			fields["panic_cause"] = cause.Error()
+		}
+	case string:
+		fields["panic_value"] = v
+	case fmt.Stringer:
+		fields["panic_value"] = v.String()
+	default:
+		fields["panic_value"] = fmt.Sprintf("%+v", v)
+		if content, ok := panicContent(v); ok {
+			fields["panic_fields"] = content
+		}
+	}
+	return fields
+}
+
+// The exported content of a struct or map panic value.
+func panicContent(_v interface{}) (map[string]interface{}, bool) {
+	kind := reflect.Indirect(reflect.ValueOf(_v)).Kind()
+	if kind != reflect.Struct && kind != reflect.Map {
+		return nil, false
+	}
+	b, err := json.Marshal(_v)
+	if err != nil {
+		return nil, false
+	}
+	var content map[string]interface{}
+	if err := json.Unmarshal(b, &content); err != nil || len(content) == 0 {
+		return nil, false
+	}
+	return content, true
+}
@@ -0,0 +1,34 @@
+//go:build linux
+
+package infrastructure
+
+import (
+	"net"
+	"os/user"
+	"strconv"
+	"syscall"
+)
+
+// Name of the user on the other end of a unix socket.
+func peerUser(_conn net.Conn) string {
+	uc, ok := _conn.(*net.UnixConn)
+	if !ok {
+		return "unknown"
+	}
+	raw, err := uc.SyscallConn()
+	if err != nil {
+		return "unknown"
+	}
+	var cred *syscall.Ucred
+	raw.Control(func(fd uintptr) {
+		cred, err = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	})
+	if err != nil || cred == nil {
+		return "unknown"
+	}
+	uid := strconv.Itoa(int(cred.Uid))
+	if u, err := user.LookupId(uid); err == nil {
+		return u.Username
+	}
+	return "uid " + uid
+}
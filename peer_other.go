@@ -0,0 +1,10 @@
+//go:build !linux
+
+package infrastructure
+
+import "net"
+
+// Peer credentials are only read on Linux.
+func peerUser(_conn net.Conn) string {
+	return "unknown"
+}
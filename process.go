@@ -0,0 +1,249 @@
+package infrastructure
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// Restart modes of a managed process.
+const (
+	RestartNever     = "never"
+	RestartOnFailure = "on-failure"
+	RestartAlways    = "always"
+)
+
+var (
+	_defaultRestartBackoff = time.Second
+	_defaultStopTimeout    = 10 * time.Second
+)
+
+/*
+Restart policy of a process started with ManageProcess
+
+@Restart: restart mode <never/on-failure/always>
+
+@MaxRestarts: restarts allowed before giving up, 0 means unlimited
+
+@Backoff: delay before a restart, default 1s
+
+@StopTimeout: time between SIGTERM and SIGKILL at shutdown, default 10s
+
+@ForwardLogs: hand the child a pipe for its records, a child built on this package with WithParentLogForwarding logs through the parent, its fatal and panic records are logged as errors
+*/
+type RestartPolicy struct {
+	Restart     string
+	MaxRestarts uint
+	Backoff     time.Duration
+	StopTimeout time.Duration
+	ForwardLogs bool
+}
+
+/*
+Start a child process supervised until resources are released
+
+Its stdout and stderr lines are logged at info and warn with the name as
+module, it is restarted according to the policy and terminated gracefully
+(SIGTERM, then SIGKILL after StopTimeout) when project goroutines are asked
+to stop. The command must not have Stdout or Stderr set. Its Stdin is given
+to every run, a reader that is not a file is only read by the runs until
+it is exhausted.
+*/
+func (pm *ProjectInfrastructure) ManageProcess(_name string, _cmd *exec.Cmd, _policy RestartPolicy) error {
+	switch _policy.Restart {
+	case "":
+		_policy.Restart = RestartNever
+	case RestartNever, RestartOnFailure, RestartAlways:
+	default:
+		return errors.Errorf("invalid restart mode %s, valid values are [%s %s %s]", _policy.Restart, RestartNever, RestartOnFailure, RestartAlways)
+	}
+	if _policy.Backoff <= 0 {
+		_policy.Backoff = _defaultRestartBackoff
+	}
+	if _policy.StopTimeout <= 0 {
+		_policy.StopTimeout = _defaultStopTimeout
+	}
+	if _cmd.Stdout != nil || _cmd.Stderr != nil {
+		return errors.Errorf("process %s: Stdout and Stderr are managed by the infrastructure", _name)
+	}
+
+	// The first start is done here so that errors reach the caller.
+	proc, err := pm.startProcess(_name, _cmd, _policy.ForwardLogs)
+	if err != nil {
+		return err
+	}
+	err = pm.Go(func(ctx context.Context) {
+		pm.superviseProcess(ctx, _name, _cmd, _policy, proc)
+	})
+	if err != nil {
+		// Nothing supervises the process, do not leave it running.
+		proc.cmd.Process.Kill()
+		<-proc.done
+	}
+	return err
+}
+
+type managedProcess struct {
+	cmd    *exec.Cmd
+	done   chan error
+	stdout *lineWriter
+	stderr *lineWriter
+}
+
+func (pm *ProjectInfrastructure) startProcess(_name string, _template *exec.Cmd, _forward bool) (*managedProcess, error) {
+	// An exec.Cmd can only be started once, every run uses a copy.
+	cmd := &exec.Cmd{
+		Path:        _template.Path,
+		Args:        _template.Args,
+		Env:         _template.Env,
+		Dir:         _template.Dir,
+		Stdin:       _template.Stdin,
+		ExtraFiles:  _template.ExtraFiles,
+		SysProcAttr: _template.SysProcAttr,
+		Err:         _template.Err,
+	}
+	p := &managedProcess{
+		cmd:    cmd,
+		done:   make(chan error, 1),
+		stdout: &lineWriter{pm: pm, module: _name, severity: "info", stream: "stdout"},
+		stderr: &lineWriter{pm: pm, module: _name, severity: "warn", stream: "stderr"},
+	}
+	cmd.Stdout, cmd.Stderr = p.stdout, p.stderr
+
+	var records *os.File
+	if _forward {
+		var err error
+		if records, err = forwardProcessLogs(cmd); err != nil {
+			return nil, errors.Wrapf(err, "start process %s", _name)
+		}
+	}
+	err := cmd.Start()
+	if records != nil {
+		// The child holds the write end now, the pipe ends when it exits.
+		cmd.ExtraFiles[len(cmd.ExtraFiles)-1].Close()
+		if err != nil {
+			records.Close()
+		} else {
+			go pm.receiveProcessLogs(_name, records)
+		}
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "start process %s", _name)
+	}
+	pm.logOutput(_name, "info", errors.Errorf("process started, pid %d", cmd.Process.Pid), false, nil)
+
+	go func() {
+		err := cmd.Wait()
+		p.stdout.flush()
+		p.stderr.flush()
+		p.done <- err
+	}()
+	return p, nil
+}
+
+func (pm *ProjectInfrastructure) superviseProcess(_ctx context.Context, _name string, _template *exec.Cmd, _policy RestartPolicy, _proc *managedProcess) {
+	var restarts uint
+	for {
+		var err error
+		select {
+		case err = <-_proc.done:
+		case <-_ctx.Done():
+			pm.stopProcess(_name, _proc, _policy.StopTimeout)
+			return
+		}
+
+		if err != nil {
+			pm.logOutput(_name, "error", errors.Errorf("process exited: %v", err), false, nil)
+		} else {
+			pm.logOutput(_name, "info", errors.New("process exited"), false, nil)
+		}
+
+		if _policy.Restart == RestartNever || (_policy.Restart == RestartOnFailure && err == nil) {
+			return
+		}
+		if _policy.MaxRestarts > 0 && restarts >= _policy.MaxRestarts {
+			pm.logOutput(_name, "error", errors.Errorf("process restarted %d times, giving up", restarts), false, nil)
+			return
+		}
+
+		select {
+		case <-time.After(_policy.Backoff):
+		case <-_ctx.Done():
+			return
+		}
+		restarts++
+		proc, err := pm.startProcess(_name, _template, _policy.ForwardLogs)
+		if err != nil {
+			pm.logOutput(_name, "error", err, false, nil)
+			return
+		}
+		_proc = proc
+	}
+}
+
+// Ask the process to terminate with SIGTERM and kill it after the timeout.
+func (pm *ProjectInfrastructure) stopProcess(_name string, _proc *managedProcess, _timeout time.Duration) {
+	// Windows only supports Kill.
+	if err := _proc.cmd.Process.Signal(syscall.SIGTERM); err != nil {
+		_proc.cmd.Process.Kill()
+	}
+
+	select {
+	case <-_proc.done:
+		pm.logOutput(_name, "info", errors.New("process stopped"), false, nil)
+	case <-time.After(_timeout):
+		pm.logOutput(_name, "warn", errors.Errorf("process did not stop within %s, killing it", _timeout), false, nil)
+		_proc.cmd.Process.Kill()
+		<-_proc.done
+	}
+}
+
+// Log every line written by a child process.
+type lineWriter struct {
+	pm       *ProjectInfrastructure
+	module   string
+	severity string
+	stream   string
+
+	mu  sync.Mutex
+	buf []byte
+}
+
+func (w *lineWriter) Write(_p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.buf = append(w.buf, _p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.emit(w.buf[:i])
+		w.buf = w.buf[i+1:]
+	}
+	return len(_p), nil
+}
+
+// Log what is left of an unterminated last line.
+func (w *lineWriter) flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.buf) > 0 {
+		w.emit(w.buf)
+		w.buf = nil
+	}
+}
+
+func (w *lineWriter) emit(_line []byte) {
+	line := string(bytes.TrimRight(_line, "\r"))
+	w.pm.logOutput(w.module, w.severity, errors.New(line), false, logrus.Fields{"stream": w.stream})
+}
@@ -0,0 +1,124 @@
+package infrastructure
+
+import (
+	"context"
+	"os/exec"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestManageProcessRestart(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a shell")
+	}
+	tests := []struct {
+		name    string
+		script  string
+		policy  RestartPolicy
+		started int
+		last    string
+	}{
+		{
+			"failing restarted up to the limit",
+			`echo run; echo oops >&2; echo '{"module":"job","severity":"info","message":"forwarded"}' >&$INFRA_LOG_FD; exit 1`,
+			RestartPolicy{Restart: RestartOnFailure, MaxRestarts: 2, Backoff: 10 * time.Millisecond, ForwardLogs: true},
+			3,
+			"giving up",
+		},
+		{
+			"succeeding not restarted on failure",
+			`echo run; echo oops >&2; echo '{"module":"job","severity":"info","message":"forwarded"}' >&$INFRA_LOG_FD`,
+			RestartPolicy{Restart: RestartOnFailure, Backoff: 10 * time.Millisecond, ForwardLogs: true},
+			1,
+			"process exited",
+		},
+		{
+			"succeeding restarted always",
+			`echo run; echo oops >&2; echo '{"module":"job","severity":"info","message":"forwarded"}' >&$INFRA_LOG_FD`,
+			RestartPolicy{Restart: RestartAlways, MaxRestarts: 1, Backoff: 10 * time.Millisecond, ForwardLogs: true},
+			2,
+			"giving up",
+		},
+	}
+	for _, tt := range tests {
+		var buf syncBuffer
+		pm, err := NewProjectInfrastructure(context.Background(),
+			WithLibraryMode(true),
+			WithLogOutput("writer"),
+			WithLogWriter(&buf),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := pm.ManageProcess("worker", exec.Command("sh", "-c", tt.script), tt.policy); err != nil {
+			t.Fatal(err)
+		}
+		buf.waitFor(t, tt.last)
+		// Longer than the backoff, a restart would have happened
+		time.Sleep(100 * time.Millisecond)
+		pm.Shutdown()
+
+		out := buf.String()
+		lines := strings.Split(out, "\n")
+		count := func(_texts ...string) int {
+			n := 0
+			for _, line := range lines {
+				all := true
+				for _, text := range _texts {
+					all = all && strings.Contains(line, text)
+				}
+				if all {
+					n++
+				}
+			}
+			return n
+		}
+		if n := count("process started"); n != tt.started {
+			t.Errorf("%s: started %d times, want %d:\n%s", tt.name, n, tt.started, out)
+		}
+		// Every run has its stdout, stderr and forwarded records logged
+		checks := []struct {
+			what  string
+			texts []string
+		}{
+			{"stdout", []string{"level=info", "stream=stdout", "run"}},
+			{"stderr", []string{"level=warning", "stream=stderr", "oops"}},
+			{"forwarded", []string{"process=worker", "forwarded"}},
+		}
+		for _, c := range checks {
+			if n := count(c.texts...); n != tt.started {
+				t.Errorf("%s: %d %s lines, want %d:\n%s", tt.name, n, c.what, tt.started, out)
+			}
+		}
+	}
+}
+
+func TestManageProcessStop(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a shell")
+	}
+	var buf syncBuffer
+	pm, err := NewProjectInfrastructure(context.Background(),
+		WithLibraryMode(true),
+		WithLogOutput("writer"),
+		WithLogWriter(&buf),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Ignores SIGTERM, killed after the stop timeout
+	cmd := exec.Command("sh", "-c", `trap '' TERM; echo ready; while :; do sleep 0.05; done`)
+	if err := pm.ManageProcess("stubborn", cmd, RestartPolicy{Restart: RestartAlways, StopTimeout: 200 * time.Millisecond}); err != nil {
+		t.Fatal(err)
+	}
+	buf.waitFor(t, "ready")
+	// Release waits for the supervisor, which kills the process
+	pm.Shutdown()
+	buf.waitFor(t, "did not stop within 200ms")
+	if strings.Count(buf.String(), "process started") != 1 {
+		t.Errorf("process restarted while stopping:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,17 @@
+//go:build !windows
+
+package infrastructure
+
+import (
+	"os"
+	"syscall"
+)
+
+func processAlive(_pid int) bool {
+	p, err := os.FindProcess(_pid)
+	if err != nil {
+		return false
+	}
+	err = p.Signal(syscall.Signal(0))
+	return err == nil || err == syscall.EPERM
+}
@@ -0,0 +1,15 @@
+//go:build windows
+
+package infrastructure
+
+import "os"
+
+// FindProcess opens the process, it fails once the process exited.
+func processAlive(_pid int) bool {
+	p, err := os.FindProcess(_pid)
+	if err != nil {
+		return false
+	}
+	p.Release()
+	return true
+}
@@ -0,0 +1,107 @@
+package infrastructure
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var _defaultRecoveryInterval = time.Minute
+
+/*
+Automated remediation of a class of errors
+
+@Name: action name used as module of its records
+
+@Match: whether a transmitted record triggers the action
+
+@Run: the remediation, it receives GoroutineCancel and the triggering record
+
+@MinInterval: time between two runs, default 1m, triggers in between are skipped
+*/
+type RecoveryAction struct {
+	Name        string
+	Match       func(module string, err error) bool
+	Run         func(ctx context.Context, module string, err error) error
+	MinInterval time.Duration
+}
+
+type recoveryState struct {
+	action  RecoveryAction
+	lastRun time.Time
+	running bool
+}
+
+type recoveries struct {
+	mu      sync.Mutex
+	actions []*recoveryState
+}
+
+// Match errors whose message contains one of the substrings, e.g.
+// "too many open files".
+func MatchErrorText(_substrings ...string) func(string, error) bool {
+	return func(_module string, _err error) bool {
+		msg := _err.Error()
+		for _, s := range _substrings {
+			if strings.Contains(msg, s) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Run the action when a transmitted record matches it.
+func (pm *ProjectInfrastructure) RegisterRecovery(_action RecoveryAction) error {
+	if _action.Name == "" || _action.Match == nil || _action.Run == nil {
+		return errors.New("recovery action needs a name, a match and a run function")
+	}
+	if _action.MinInterval <= 0 {
+		_action.MinInterval = _defaultRecoveryInterval
+	}
+
+	pm.recoveries.mu.Lock()
+	defer pm.recoveries.mu.Unlock()
+	pm.recoveries.actions = append(pm.recoveries.actions, &recoveryState{action: _action})
+	return nil
+}
+
+// Start the actions matching a record, at most once per interval each.
+func (pm *ProjectInfrastructure) triggerRecovery(_module string, _err error) {
+	pm.recoveries.mu.Lock()
+	defer pm.recoveries.mu.Unlock()
+
+	now := time.Now()
+	for _, r := range pm.recoveries.actions {
+		if !r.action.Match(_module, _err) {
+			continue
+		}
+		if r.running || now.Sub(r.lastRun) < r.action.MinInterval {
+			pm.logOutput(r.action.Name, "debug", errors.Errorf("recovery skipped, last run at %s", r.lastRun.Format(jsonTimestampFormat)), false, nil)
+			continue
+		}
+
+		r := r
+		r.running, r.lastRun = true, now
+		err := pm.Go(func(ctx context.Context) {
+			pm.logOutput(r.action.Name, "warn", errors.Errorf("recovery triggered by %s: %v", _module, errors.Cause(_err)), false, nil)
+			runErr := r.action.Run(ctx, _module, _err)
+
+			pm.recoveries.mu.Lock()
+			r.running = false
+			pm.recoveries.mu.Unlock()
+
+			if runErr != nil {
+				pm.logOutput(r.action.Name, "error", errors.Errorf("recovery failed: %v", runErr), false, nil)
+				return
+			}
+			pm.logOutput(r.action.Name, "info", errors.New("recovery done"), false, nil)
+		})
+		if err != nil {
+			r.running = false
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package infrastructure
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const redactedText = "[REDACTED]"
+
+// Masks applied to the records before they reach any output.
+type redactor struct {
+	patterns []*regexp.Regexp
+	keys     map[string]bool
+}
+
+func newRedactor(_opts ProjectInfrastructureOptions) (*redactor, error) {
+	r := &redactor{keys: make(map[string]bool, len(_opts.RedactKeys))}
+	for _, p := range _opts.RedactPatterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid redact pattern %s", p)
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	for _, k := range _opts.RedactKeys {
+		r.keys[strings.ToLower(k)] = true
+	}
+	return r, nil
+}
+
+func (r *redactor) text(_s string) string {
+	for _, re := range r.patterns {
+		_s = re.ReplaceAllString(_s, redactedText)
+	}
+	return _s
+}
+
+// Mask the fields named by a key, and the patterns in the text of the others.
+func (r *redactor) fields(_fields logrus.Fields) logrus.Fields {
+	if len(_fields) == 0 {
+		return _fields
+	}
+	fields := make(logrus.Fields, len(_fields))
+	for k, v := range _fields {
+		switch {
+		case r.keys[strings.ToLower(k)]:
+			v = redactedText
+		case len(r.patterns) > 0:
+			switch value := v.(type) {
+			case string:
+				v = r.text(value)
+			case error:
+				v = r.text(value.Error())
+			case fmt.Stringer:
+				v = r.text(value.String())
+			}
+		}
+		fields[k] = v
+	}
+	return fields
+}
+
+// The error with its message, bottom cause and printed chain masked. The
+// stack of the chain is kept for the origin and error reporting.
+func (r *redactor) error(_err error) error {
+	if len(r.patterns) == 0 || _err == nil {
+		return _err
+	}
+	switch _err.(type) {
+	case *redactedError, *redactedCause:
+		return _err
+	}
+
+	// The innermost stack of the chain, for both so that the origin is
+	// found whichever of them is inspected.
+	var stack errors.StackTrace
+	for c := _err; c != nil; {
+		if tracer, ok := c.(stackTracer); ok {
+			stack = tracer.StackTrace()
+		}
+		causer, ok := c.(interface{ Cause() error })
+		if !ok {
+			break
+		}
+		c = causer.Cause()
+	}
+
+	cause := &redactedCause{
+		orig:  errors.Cause(_err),
+		msg:   r.text(errors.Cause(_err).Error()),
+		chain: r.text(fmt.Sprintf("%+v", errors.Cause(_err))),
+		stack: stack,
+	}
+	if errors.Cause(_err) == _err {
+		return cause
+	}
+	return &redactedError{
+		orig:  _err,
+		msg:   r.text(_err.Error()),
+		chain: r.text(fmt.Sprintf("%+v", _err)),
+		cause: cause,
+		stack: stack,
+	}
+}
+
+// The original error stays reachable through Unwrap, so errors.Is and
+// errors.As still match it.
+type redactedError struct {
+	orig  error
+	msg   string
+	chain string
+	cause *redactedCause
+	stack errors.StackTrace
+}
+
+func (e *redactedError) Error() string { return e.msg }
+
+func (e *redactedError) Cause() error { return e.cause }
+
+func (e *redactedError) StackTrace() errors.StackTrace { return e.stack }
+
+func (e *redactedError) Unwrap() error { return e.orig }
+
+func (e *redactedError) Format(_s fmt.State, _verb rune) {
+	formatRedacted(_s, _verb, e.msg, e.chain)
+}
+
+// The bottom of a redacted chain, it has no cause.
+type redactedCause struct {
+	orig  error
+	msg   string
+	chain string
+	stack errors.StackTrace
+}
+
+func (e *redactedCause) Error() string { return e.msg }
+
+func (e *redactedCause) StackTrace() errors.StackTrace { return e.stack }
+
+func (e *redactedCause) Unwrap() error { return e.orig }
+
+func (e *redactedCause) Format(_s fmt.State, _verb rune) {
+	formatRedacted(_s, _verb, e.msg, e.chain)
+}
+
+func formatRedacted(_s fmt.State, _verb rune, _msg, _chain string) {
+	if _verb == 'v' && _s.Flag('+') {
+		fmt.Fprint(_s, _chain)
+		return
+	}
+	fmt.Fprint(_s, _msg)
+}
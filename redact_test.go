@@ -0,0 +1,88 @@
+package infrastructure
+
+import (
+	"fmt"
+	"io/fs"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+func TestRedactorText(t *testing.T) {
+	r, err := newRedactor(ProjectInfrastructureOptions{RedactPatterns: []string{`password=\S+`, `\d{4}-\d{4}`}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"login password=hunter2 failed", "login [REDACTED] failed"},
+		{"card 1234-5678", "card [REDACTED]"},
+		{"nothing to hide", "nothing to hide"},
+	}
+	for _, tt := range tests {
+		if got := r.text(tt.in); got != tt.want {
+			t.Errorf("text(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestRedactorFields(t *testing.T) {
+	r, err := newRedactor(ProjectInfrastructureOptions{
+		RedactPatterns: []string{`secret`},
+		RedactKeys:     []string{"Token"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := r.fields(logrus.Fields{
+		"token": "abc",
+		"note":  "a secret note",
+		"cause": errors.New("secret cause"),
+		"count": 3,
+	})
+	want := logrus.Fields{
+		"token": redactedText,
+		"note":  "a [REDACTED] note",
+		"cause": "[REDACTED] cause",
+		"count": 3,
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s = %v, want %v", k, got[k], v)
+		}
+	}
+}
+
+func TestRedactorErrorKeepsChain(t *testing.T) {
+	r, err := newRedactor(ProjectInfrastructureOptions{RedactPatterns: []string{`secret`}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := fmt.Errorf("open secret: %w", fs.ErrNotExist)
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{"bottom", base},
+		{"wrapped", errors.Wrap(base, "load secret")},
+	}
+	for _, tt := range tests {
+		redacted := r.error(tt.err)
+		if strings.Contains(redacted.Error(), "secret") {
+			t.Errorf("%s: message not masked: %s", tt.name, redacted.Error())
+		}
+		if strings.Contains(fmt.Sprintf("%+v", redacted), "secret") {
+			t.Errorf("%s: chain not masked", tt.name)
+		}
+		if !errors.Is(redacted, fs.ErrNotExist) {
+			t.Errorf("%s: errors.Is lost the original error", tt.name)
+		}
+		if r.error(redacted) != redacted {
+			t.Errorf("%s: redacted twice", tt.name)
+		}
+	}
+}
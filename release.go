@@ -0,0 +1,149 @@
+package infrastructure
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+/*
+A resource closed during ResourceRelease
+
+@Name: hook name used as module of its records
+
+@Priority: tiers are released in ascending order, a tier starts once the previous one is done
+
+@Parallel: the hook is independent of the others of its tier and closes concurrently with them
+
+@Release: closes the resource, it receives a context bounded by the shutdown timeout, unbounded when it is 0
+*/
+type ReleaseHook struct {
+	Name     string
+	Priority int
+	Parallel bool
+	Release  func(ctx context.Context) error
+}
+
+type releaseHooks struct {
+	mu    sync.Mutex
+	hooks []ReleaseHook
+}
+
+// Call the hook when resources are released, after the release function of
+// WithResourceRleaseFunc and before the components are stopped.
+func (pm *ProjectInfrastructure) RegisterReleaseHook(_hook ReleaseHook) error {
+	if _hook.Name == "" || _hook.Release == nil {
+		return errors.New("release hook needs a name and a release function")
+	}
+
+	pm.releaseHooks.mu.Lock()
+	defer pm.releaseHooks.mu.Unlock()
+	pm.releaseHooks.hooks = append(pm.releaseHooks.hooks, _hook)
+	return nil
+}
+
+// Run the hooks tier by tier within the shutdown timeout. In a tier the
+// parallel hooks run together first, then the others in registration order.
+// Errors are logged and the names of the failed hooks returned.
+func (pm *ProjectInfrastructure) runReleaseHooks() []string {
+	pm.releaseHooks.mu.Lock()
+	hooks := append([]ReleaseHook(nil), pm.releaseHooks.hooks...)
+	pm.releaseHooks.mu.Unlock()
+	if len(hooks) == 0 {
+		return nil
+	}
+	sort.SliceStable(hooks, func(i, j int) bool {
+		return hooks[i].Priority < hooks[j].Priority
+	})
+
+	ctx, cancel := pm.shutdownContext()
+	defer cancel()
+	var mu sync.Mutex
+	var failed []string
+	release := func(h ReleaseHook) {
+		if !pm.releaseHook(ctx, h) {
+			mu.Lock()
+			failed = append(failed, h.Name)
+			mu.Unlock()
+		}
+	}
+	for start := 0; start < len(hooks); {
+		end := start
+		for end < len(hooks) && hooks[end].Priority == hooks[start].Priority {
+			end++
+		}
+		tier := hooks[start:end]
+		start = end
+
+		var wg sync.WaitGroup
+		for _, h := range tier {
+			if !h.Parallel {
+				continue
+			}
+			wg.Add(1)
+			go func(h ReleaseHook) {
+				defer wg.Done()
+				release(h)
+			}(h)
+		}
+		wg.Wait()
+		for _, h := range tier {
+			if !h.Parallel {
+				release(h)
+			}
+		}
+	}
+	return failed
+}
+
+// Context of the release steps, bounded by the shutdown timeout unless it
+// is 0, which waits without a limit.
+func (pm *ProjectInfrastructure) shutdownContext() (context.Context, context.CancelFunc) {
+	if pm.options.ShutdownTimeout <= 0 {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithTimeout(context.Background(), pm.options.ShutdownTimeout)
+}
+
+// Report whether the hook released its resource.
+func (pm *ProjectInfrastructure) releaseHook(_ctx context.Context, _hook ReleaseHook) (ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			pm.logOutput(_hook.Name, "error", errors.New("panic recovered in release hook"), false, panicFields(r))
+			ok = false
+		}
+	}()
+	if err := _hook.Release(_ctx); err != nil {
+		pm.logOutput(_hook.Name, "error", errors.Errorf("release: %v", err), false, nil)
+		return false
+	}
+	return true
+}
+
+// Exit code of a shutdown through a signal or the admin console, non-zero
+// when release hooks failed and WithReleaseFailureExitCode is set.
+func (pm *ProjectInfrastructure) ExitCode() int {
+	pm.stateMu.RLock()
+	defer pm.stateMu.RUnlock()
+	return pm.exitCode
+}
+
+// Record a dirty shutdown, logged once the hooks are done so the summary
+// reaches the providers before they are flushed.
+func (pm *ProjectInfrastructure) reportReleaseFailures(_failed []string) {
+	code := pm.options.ReleaseFailureExitCode
+	if len(_failed) == 0 || code == 0 {
+		return
+	}
+	pm.logOutput("infra", "error",
+		errors.Errorf("dirty shutdown, %d release hooks failed: %s", len(_failed), strings.Join(_failed, ", ")),
+		false, logrus.Fields{"failed_hooks": _failed, "exit_code": code})
+
+	pm.stateMu.Lock()
+	pm.exitCode = code
+	pm.stateMu.Unlock()
+}
@@ -0,0 +1,45 @@
+package infrastructure
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestReleaseHookContext(t *testing.T) {
+	tests := []struct {
+		timeout  time.Duration
+		deadline bool
+	}{
+		{0, false},
+		{time.Minute, true},
+	}
+	for _, tt := range tests {
+		pm, err := NewProjectInfrastructure(context.Background(),
+			WithLibraryMode(true),
+			WithLogOutput("writer"),
+			WithLogWriter(io.Discard),
+			WithShutdownTimeout(tt.timeout),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ctxErr error
+		var deadline bool
+		pm.RegisterReleaseHook(ReleaseHook{Name: "db", Release: func(ctx context.Context) error {
+			ctxErr = ctx.Err()
+			_, deadline = ctx.Deadline()
+			return nil
+		}})
+		if err := pm.Shutdown(); err != nil {
+			t.Fatalf("timeout %s: %v", tt.timeout, err)
+		}
+		if ctxErr != nil {
+			t.Errorf("timeout %s: hook context already done: %v", tt.timeout, ctxErr)
+		}
+		if deadline != tt.deadline {
+			t.Errorf("timeout %s: deadline %v, want %v", tt.timeout, deadline, tt.deadline)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package infrastructure
+
+import (
+	"context"
+	"io"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	_remoteDialTimeout = 5 * time.Second
+	_remoteMaxBackoff  = 30 * time.Second
+)
+
+/*
+Ship lines to a remote collector over TCP or UDP
+
+Lines are buffered and sent by a goroutine which reconnects with backoff when
+the collector goes away. After MaxFailures consecutive failures lines go to
+the fallback file until the collector is back. Lines are dropped when the
+buffer is full. Delivery is best effort, the protocol has no receipts: a
+line written to a TCP connection the collector then drops is lost.
+*/
+type remoteWriter struct {
+	network     string
+	address     string
+	maxFailures int
+	fallback    io.Writer
+	// The fallback is the file output, reopened with it
+	sharedFallback bool
+
+	limiter *bandwidthLimiter
+
+	lines   chan []byte
+	dropped uint64
+	pending int64
+}
+
+func (pm *ProjectInfrastructure) openRemote(_opts ProjectInfrastructureOptions) (*remoteWriter, error) {
+	if _opts.RemoteNetwork != "tcp" && _opts.RemoteNetwork != "udp" {
+		return nil, errors.Errorf("invalid remote network %s, valid values are tcp and udp", _opts.RemoteNetwork)
+	}
+	if _opts.RemoteBufferSize < 0 {
+		return nil, errors.Errorf("invalid remote buffer size %d", _opts.RemoteBufferSize)
+	}
+	r := &remoteWriter{
+		network:     _opts.RemoteNetwork,
+		address:     _opts.RemoteAddress,
+		maxFailures: _opts.RemoteMaxFailures,
+		limiter:     pm.shipping,
+		lines:       make(chan []byte, _opts.RemoteBufferSize),
+	}
+	if r.maxFailures > 0 {
+		fallback, err := pm.openFileOutput(_opts)
+		if err != nil {
+			return nil, errors.Wrap(err, "open remote fallback file")
+		}
+		r.fallback = fallback
+		for _, o := range parseLogOutputs(_opts.LogOut) {
+			r.sharedFallback = r.sharedFallback || o == "file"
+		}
+	}
+
+	go r.run(pm.cancel)
+	pm.RegisterFlusher("remote", r.flush)
+	return r, nil
+}
+
+// Lines buffered but not sent yet.
+func (r *remoteWriter) queued() int {
+	return int(atomic.LoadInt64(&r.pending))
+}
+
+// Reopen the fallback file, unless the file output does.
+func (r *remoteWriter) Reopen() error {
+	if r.sharedFallback {
+		return nil
+	}
+	return reopenWriter(r.fallback)
+}
+
+func (r *remoteWriter) Write(_p []byte) (int, error) {
+	line := append([]byte(nil), _p...)
+	atomic.AddInt64(&r.pending, 1)
+	select {
+	case r.lines <- line:
+	default:
+		atomic.AddInt64(&r.pending, -1)
+		atomic.AddUint64(&r.dropped, 1)
+	}
+	return len(_p), nil
+}
+
+// Wait until the buffered lines are sent or written to the fallback file.
+func (r *remoteWriter) flush(_ctx context.Context) error {
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for atomic.LoadInt64(&r.pending) > 0 {
+		select {
+		case <-ticker.C:
+		case <-_ctx.Done():
+			return errors.Errorf("%d lines not sent to %s", atomic.LoadInt64(&r.pending), r.address)
+		}
+	}
+	if dropped := atomic.SwapUint64(&r.dropped, 0); dropped > 0 {
+		return errors.Errorf("dropped %d lines, the buffer was full", dropped)
+	}
+	return nil
+}
+
+func (r *remoteWriter) run(_ctx context.Context) {
+	var conn net.Conn
+	var failures int
+	var nextDial time.Time
+	backoff := time.Second
+	defer func() {
+		if conn != nil {
+			conn.Close()
+		}
+	}()
+
+	for {
+		var line []byte
+		select {
+		case line = <-r.lines:
+		case <-_ctx.Done():
+			return
+		}
+
+		for {
+			if conn == nil && !time.Now().Before(nextDial) {
+				c, err := net.DialTimeout(r.network, r.address, _remoteDialTimeout)
+				if err == nil {
+					conn, failures, backoff = c, 0, time.Second
+					if r.network == "tcp" {
+						// Notice a collector closing the connection before
+						// the next write instead of losing that line.
+						go func() {
+							io.Copy(io.Discard, c)
+							c.Close()
+						}()
+					}
+				} else {
+					failures++
+					nextDial = time.Now().Add(backoff)
+					if backoff *= 2; backoff > _remoteMaxBackoff {
+						backoff = _remoteMaxBackoff
+					}
+				}
+			}
+			if conn != nil {
+				if r.limiter.wait(_ctx, len(line)) != nil {
+					return
+				}
+				if _, err := conn.Write(line); err == nil {
+					break
+				}
+				conn.Close()
+				conn = nil
+				failures++
+				continue
+			}
+
+			// Collector unreachable, fall back or wait for the next dial.
+			if r.fallback != nil && failures >= r.maxFailures {
+				r.fallback.Write(line)
+				break
+			}
+			select {
+			case <-time.After(time.Until(nextDial)):
+			case <-_ctx.Done():
+				return
+			}
+		}
+		atomic.AddInt64(&r.pending, -1)
+	}
+}
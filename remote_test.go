@@ -0,0 +1,114 @@
+package infrastructure
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func remoteOutput(_t *testing.T, _opts ...OptionFunc) *ProjectInfrastructure {
+	_t.Helper()
+	opts := append([]OptionFunc{
+		WithLibraryMode(true),
+		WithLogOutput("remote"),
+		WithLogPath(filepath.Join(_t.TempDir(), "project.log")),
+	}, _opts...)
+	pm, err := NewProjectInfrastructure(context.Background(), opts...)
+	if err != nil {
+		_t.Fatal(err)
+	}
+	_t.Cleanup(func() { pm.Shutdown() })
+	return pm
+}
+
+func TestRemoteTCP(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	pm := remoteOutput(t, WithLogRemote("tcp", l.Addr().String()))
+
+	pm.ErrorTransmit("db", "error", errors.New("connection refused"), false, false)
+	pm.ErrorTransmit("api", "warn", errors.New("slow"), false, false)
+	conn, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	// One formatted line per record, in order
+	lines := bufio.NewScanner(conn)
+	for _, want := range []string{"connection refused", "slow"} {
+		if !lines.Scan() {
+			t.Fatalf("no line for %q: %v", want, lines.Err())
+		}
+		if !strings.Contains(lines.Text(), want) {
+			t.Errorf("line %q, want %q in it", lines.Text(), want)
+		}
+	}
+	if errs := pm.Flush(); errs["remote"] != nil {
+		t.Error(errs["remote"])
+	}
+}
+
+func TestRemoteUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	pm := remoteOutput(t, WithLogRemote("udp", conn.LocalAddr().String()))
+
+	// One datagram per record
+	pm.ErrorTransmit("db", "error", errors.New("connection refused"), false, false)
+	pm.ErrorTransmit("api", "warn", errors.New("slow"), false, false)
+	buf := make([]byte, 65536)
+	for _, want := range []string{"connection refused", "slow"} {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		line := string(buf[:n])
+		if !strings.Contains(line, want) || strings.Count(line, "\n") != 1 {
+			t.Errorf("datagram %q, want the line of %q", line, want)
+		}
+	}
+}
+
+func TestRemoteFallback(t *testing.T) {
+	// A port nothing listens on
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := l.Addr().String()
+	l.Close()
+
+	path := filepath.Join(t.TempDir(), "fallback.log")
+	pm := remoteOutput(t,
+		WithLogPath(path),
+		WithLogRemote("tcp", address),
+		WithLogRemoteBuffer(16, 1),
+	)
+	pm.ErrorTransmit("db", "error", errors.New("connection refused"), false, false)
+	if errs := pm.Flush(); errs["remote"] != nil {
+		t.Fatal(errs["remote"])
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "connection refused") {
+		t.Errorf("fallback file %q, want the record", data)
+	}
+}
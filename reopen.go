@@ -0,0 +1,75 @@
+package infrastructure
+
+import (
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Outputs writing to files that an external rotation can move away.
+type reopener interface {
+	Reopen() error
+}
+
+// A plain log file rotated by an external tool such as logrotate, reopened
+// at its path on request so writes stop going to the moved file.
+type reopenFile struct {
+	mu   sync.Mutex
+	path string
+	f    *os.File
+}
+
+func openReopenFile(_path string) (*reopenFile, error) {
+	f, err := os.OpenFile(_path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &reopenFile{path: _path, f: f}, nil
+}
+
+func (r *reopenFile) Write(_p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.f.Write(_p)
+}
+
+func (r *reopenFile) Reopen() error {
+	f, err := os.OpenFile(r.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return errors.Wrapf(err, "reopen %s", r.path)
+	}
+	r.mu.Lock()
+	old := r.f
+	r.f = f
+	r.mu.Unlock()
+	return old.Close()
+}
+
+func reopenWriter(_w io.Writer) error {
+	if r, ok := _w.(reopener); ok {
+		return r.Reopen()
+	}
+	return nil
+}
+
+/*
+Reopen the log files at their paths, e.g. after logrotate moved them
+
+Only files of WithExternalLogRotation are reopened, the files created by the
+built-in rotation are left as they are. SIGHUP calls it unless in library mode.
+*/
+func (pm *ProjectInfrastructure) ReopenLogs() error {
+	var failures []string
+	for _, o := range pm.outputs {
+		if err := reopenWriter(o.w); err != nil {
+			failures = append(failures, err.Error())
+		}
+	}
+	if len(failures) > 0 {
+		return errors.Errorf("reopen logs: %s", strings.Join(failures, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package infrastructure
+
+import (
+	"io"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// File output split by severity, the severities with a retention go to their
+// own files, e.g. "./error.project.log", rotated and removed independently.
+// The other severities go to the log file. Files are keyed by severity name,
+// registered severities sharing a level with a built-in one keep their own.
+// LogMaxTotalSize caps all of the files together.
+type severityFiles struct {
+	files    map[string]io.Writer
+	fallback io.Writer
+}
+
+func openSeverityFiles(_opts ProjectInfrastructureOptions) (*severityFiles, error) {
+	main, err := openLogFile(_opts)
+	if err != nil {
+		return nil, err
+	}
+	s := &severityFiles{files: make(map[string]io.Writer), fallback: main}
+	capGlobs := logFileGlobs(_opts)
+	for severity, maxAge := range _opts.SeverityRetention {
+		if _, err := severityRank(severity); err != nil {
+			return nil, errors.Wrap(err, "severity retention")
+		}
+		opts := _opts
+		opts.LogPath = severityLogPath(_opts.LogPath, severity)
+		if _opts.LogRotationPattern != "" {
+			opts.LogRotationPattern = severityLogPath(_opts.LogRotationPattern, severity)
+		}
+		// Retention is by age only, a file count would remove recent files
+		// of busy severities.
+		opts.LogMaxAge = maxAge
+		opts.LogMaxFileNum = 0
+		f, err := openRotatedFile(opts, capGlobs)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s log file", severity)
+		}
+		s.files[severity] = f
+	}
+	return s, nil
+}
+
+// Prefix the file name with the severity, the glob of the log file
+// rotation never matches it.
+func severityLogPath(_path, _severity string) string {
+	return filepath.Join(filepath.Dir(_path), _severity+"."+filepath.Base(_path))
+}
+
+func (s *severityFiles) Reopen() error {
+	for _, w := range s.files {
+		if err := reopenWriter(w); err != nil {
+			return err
+		}
+	}
+	return reopenWriter(s.fallback)
+}
+
+func (s *severityFiles) Write(_p []byte) (int, error) {
+	return s.fallback.Write(_p)
+}
+
+func (s *severityFiles) WriteEntry(_entry *logrus.Entry, _p []byte) error {
+	w, ok := s.files[entrySeverity(_entry)]
+	if !ok {
+		w = s.fallback
+	}
+	_, err := w.Write(_p)
+	return err
+}
+
+// Entries are written by WriteEntry, see outputHook.
+func (s *severityFiles) WriteLevel(_level logrus.Level, _p []byte) error {
+	return s.WriteEntry(&logrus.Entry{Level: _level}, _p)
+}
@@ -0,0 +1,60 @@
+package infrastructure
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestSeverityFilesByName(t *testing.T) {
+	// Severities are process wide, the test may run more than once.
+	if _, ok := severityByName("retained"); !ok {
+		if err := RegisterSeverity(Severity{Name: "retained", Relative: "error", Above: true}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dir := t.TempDir()
+	opts := DefaultOptions()
+	opts.LogPath = filepath.Join(dir, "project.log")
+	opts.LogExternalRotation = true
+	opts.SeverityRetention = map[string]time.Duration{"error": time.Hour, "retained": 24 * time.Hour}
+	s, err := openSeverityFiles(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		entry *logrus.Entry
+		file  string
+	}{
+		{&logrus.Entry{Level: logrus.ErrorLevel, Data: logrus.Fields{}}, "error.project.log"},
+		{&logrus.Entry{Level: logrus.ErrorLevel, Data: logrus.Fields{"severity": "retained"}}, "retained.project.log"},
+		{&logrus.Entry{Level: logrus.InfoLevel, Data: logrus.Fields{}}, "project.log"},
+	}
+	for _, tt := range tests {
+		line := tt.file + "\n"
+		if err := s.WriteEntry(tt.entry, []byte(line)); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(filepath.Join(dir, tt.file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != line {
+			t.Errorf("%s holds %q, want %q", tt.file, data, line)
+		}
+	}
+}
+
+func TestSeverityFilesInvalidSeverity(t *testing.T) {
+	opts := DefaultOptions()
+	opts.LogPath = filepath.Join(t.TempDir(), "project.log")
+	opts.LogExternalRotation = true
+	opts.SeverityRetention = map[string]time.Duration{"nope": time.Hour}
+	if _, err := openSeverityFiles(opts); err == nil {
+		t.Fatal("expected an error for an unknown severity")
+	}
+}
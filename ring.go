@@ -0,0 +1,111 @@
+package infrastructure
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+// The last records emitted, oldest overwritten first.
+type ringBuffer struct {
+	mu      sync.Mutex
+	records []LogRecord
+	next    int
+	full    bool
+}
+
+func newRingBuffer(_size int) *ringBuffer {
+	return &ringBuffer{records: make([]LogRecord, _size)}
+}
+
+func (b *ringBuffer) add(_record LogRecord) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.records[b.next] = _record
+	b.next = (b.next + 1) % len(b.records)
+	if b.next == 0 {
+		b.full = true
+	}
+}
+
+// The last n records, oldest first.
+func (b *ringBuffer) tail(_n int) []LogRecord {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	size := b.next
+	if b.full {
+		size = len(b.records)
+	}
+	if _n <= 0 || _n > size {
+		_n = size
+	}
+	tail := make([]LogRecord, _n)
+	for i := range tail {
+		tail[i] = b.records[(b.next-_n+i+len(b.records))%len(b.records)]
+	}
+	return tail
+}
+
+// The last n records emitted, oldest first, all of the buffer when n is 0.
+// Empty without WithLogRingBuffer.
+func (pm *ProjectInfrastructure) TailLogs(_n int) []LogRecord {
+	if pm.ring == nil {
+		return nil
+	}
+	return pm.ring.tail(_n)
+}
+
+/*
+HTTP handler returning the last records as a JSON array, meant to be mounted
+at "/debug/logs/tail" of an admin server
+
+@n: query parameter, number of records, all of the buffer by default
+
+@severity: query parameter, minimum severity of the records <debug/info/warn/error>
+
+@module: query parameter, module name or glob pattern of the records
+*/
+func (pm *ProjectInfrastructure) TailHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := 0
+		if s := r.URL.Query().Get("n"); s != "" {
+			var err error
+			if n, err = strconv.Atoi(s); err != nil || n < 0 {
+				http.Error(w, "invalid n "+s, http.StatusBadRequest)
+				return
+			}
+		}
+		minRank := math.MaxInt
+		if s := r.URL.Query().Get("severity"); s != "" {
+			rank, err := severityRank(s)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			minRank = rank
+		}
+		module := r.URL.Query().Get("module")
+
+		// Filter the whole buffer, n counts the matching records.
+		records := []LogRecord{}
+		for _, record := range pm.TailLogs(0) {
+			if rank, err := severityRank(record.Severity); err == nil && rank > minRank {
+				continue
+			}
+			if module != "" && !matchModulePattern(module, record.Module) {
+				continue
+			}
+			records = append(records, record)
+		}
+		if n > 0 && n < len(records) {
+			records = records[len(records)-n:]
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(records)
+	})
+}
@@ -0,0 +1,140 @@
+package infrastructure
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+/*
+Bucket of an S3 compatible object store, such as AWS S3, MinIO or Google
+Cloud Storage with HMAC keys
+
+@Endpoint: base URL of the store, e.g. "https://s3.eu-west-1.amazonaws.com", "http://minio:9000" or "https://storage.googleapis.com"
+
+@Region: signing region, "us-east-1" when empty, "auto" for Google Cloud Storage
+
+@Bucket: bucket the objects are put in, addressed in the path
+
+@AccessKeyID, SecretAccessKey: credentials signing the requests with AWS Signature Version 4
+*/
+type S3Config struct {
+	Endpoint        string
+	Region          string
+	Bucket          string
+	AccessKeyID     string
+	SecretAccessKey string
+}
+
+type s3Archiver struct {
+	config S3Config
+	client *http.Client
+}
+
+// Upload the archived log files to an S3 compatible bucket.
+func NewS3Archiver(_config S3Config) (Archiver, error) {
+	if _config.Endpoint == "" || _config.Bucket == "" {
+		return nil, errors.New("s3 archiver needs an endpoint and a bucket")
+	}
+	if _, err := url.Parse(_config.Endpoint); err != nil {
+		return nil, errors.Wrap(err, "s3 endpoint")
+	}
+	if _config.Region == "" {
+		_config.Region = "us-east-1"
+	}
+	_config.Endpoint = strings.TrimSuffix(_config.Endpoint, "/")
+	return &s3Archiver{config: _config, client: &http.Client{}}, nil
+}
+
+func (s *s3Archiver) Name() string {
+	return "s3://" + s.config.Bucket
+}
+
+// Keep the credentials out of formatted options, e.g. the config state.
+func (s *s3Archiver) String() string {
+	return s.Name()
+}
+
+func (s *s3Archiver) Upload(_ctx context.Context, _key string, _body []byte) error {
+	uri := "/" + s3Escape(s.config.Bucket) + "/" + s3Escape(_key)
+	req, err := http.NewRequestWithContext(_ctx, http.MethodPut, s.config.Endpoint+uri, bytes.NewReader(_body))
+	if err != nil {
+		return err
+	}
+	s.sign(req, uri, _body, time.Now().UTC())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return errors.Errorf("unexpected status %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// Add the AWS Signature Version 4 headers of a request without query.
+func (s *s3Archiver) sign(_req *http.Request, _uri string, _body []byte, _t time.Time) {
+	amzDate := _t.Format("20060102T150405Z")
+	date := _t.Format("20060102")
+	payload := sha256.Sum256(_body)
+	payloadHash := hex.EncodeToString(payload[:])
+
+	_req.Header.Set("X-Amz-Date", amzDate)
+	_req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	const signedHeaders = "host;x-amz-content-sha256;x-amz-date"
+	canonical := strings.Join([]string{
+		_req.Method,
+		_uri,
+		"",
+		"host:" + _req.URL.Host,
+		"x-amz-content-sha256:" + payloadHash,
+		"x-amz-date:" + amzDate,
+		"",
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	canonicalHash := sha256.Sum256([]byte(canonical))
+
+	scope := date + "/" + s.config.Region + "/s3/aws4_request"
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(canonicalHash[:])
+	key := []byte("AWS4" + s.config.SecretAccessKey)
+	for _, part := range []string{date, s.config.Region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	_req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.config.AccessKeyID, scope, signedHeaders, hex.EncodeToString(hmacSHA256(key, toSign))))
+}
+
+func hmacSHA256(_key []byte, _data string) []byte {
+	h := hmac.New(sha256.New, _key)
+	h.Write([]byte(_data))
+	return h.Sum(nil)
+}
+
+// Percent-encode a path as signed by S3, every byte but the unreserved
+// characters and the slashes.
+func s3Escape(_path string) string {
+	var b strings.Builder
+	for i := 0; i < len(_path); i++ {
+		c := _path[i]
+		if c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || strings.IndexByte("-._~/", c) >= 0 {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
@@ -0,0 +1,101 @@
+package infrastructure
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// Signature of a request as recomputed by the store, from what it received.
+func s3ServerSignature(_r *http.Request, _secret, _region string) string {
+	amzDate := _r.Header.Get("X-Amz-Date")
+	canonical := strings.Join([]string{
+		_r.Method,
+		_r.URL.EscapedPath(),
+		_r.URL.RawQuery,
+		"host:" + _r.Host,
+		"x-amz-content-sha256:" + _r.Header.Get("X-Amz-Content-Sha256"),
+		"x-amz-date:" + amzDate,
+		"",
+		"host;x-amz-content-sha256;x-amz-date",
+		_r.Header.Get("X-Amz-Content-Sha256"),
+	}, "\n")
+	hash := sha256.Sum256([]byte(canonical))
+	scope := amzDate[:8] + "/" + _region + "/s3/aws4_request"
+	mac := func(_key []byte, _data string) []byte {
+		h := hmac.New(sha256.New, _key)
+		h.Write([]byte(_data))
+		return h.Sum(nil)
+	}
+	key := mac(mac(mac(mac([]byte("AWS4"+_secret), amzDate[:8]), _region), "s3"), "aws4_request")
+	return hex.EncodeToString(mac(key, "AWS4-HMAC-SHA256\n"+amzDate+"\n"+scope+"\n"+hex.EncodeToString(hash[:])))
+}
+
+func TestS3Upload(t *testing.T) {
+	const secret = "wJalrXUtnFEMI/K7MDENG/bPxRfiCYEXAMPLEKEY"
+	type upload struct {
+		method, path, auth string
+		body               []byte
+		signatureOK        bool
+		payloadOK          bool
+	}
+	uploads := make(chan upload, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		sum := sha256.Sum256(body)
+		auth := r.Header.Get("Authorization")
+		m := regexp.MustCompile(`Signature=([0-9a-f]{64})$`).FindStringSubmatch(auth)
+		u := upload{
+			method:      r.Method,
+			path:        r.URL.EscapedPath(),
+			auth:        auth,
+			body:        body,
+			signatureOK: m != nil && m[1] == s3ServerSignature(r, secret, "eu-west-1"),
+			payloadOK:   r.Header.Get("X-Amz-Content-Sha256") == hex.EncodeToString(sum[:]),
+		}
+		uploads <- u
+		if strings.Contains(u.path, "denied") {
+			w.WriteHeader(http.StatusForbidden)
+			io.WriteString(w, "<Error><Code>AccessDenied</Code></Error>")
+		}
+	}))
+	defer srv.Close()
+
+	archiver, err := NewS3Archiver(S3Config{
+		Endpoint:        srv.URL + "/",
+		Region:          "eu-west-1",
+		Bucket:          "logs",
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: secret,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := archiver.Upload(context.Background(), "host-1/project 2026.log.gz", []byte("records")); err != nil {
+		t.Fatal(err)
+	}
+	u := <-uploads
+	if u.method != http.MethodPut || u.path != "/logs/host-1/project%202026.log.gz" || string(u.body) != "records" {
+		t.Errorf("%s %s with %q", u.method, u.path, u.body)
+	}
+	if !strings.HasPrefix(u.auth, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/") || !strings.Contains(u.auth, "/eu-west-1/s3/aws4_request, SignedHeaders=host;x-amz-content-sha256;x-amz-date, ") {
+		t.Errorf("authorization %q", u.auth)
+	}
+	if !u.signatureOK || !u.payloadOK {
+		t.Errorf("signature valid %v, payload hash valid %v", u.signatureOK, u.payloadOK)
+	}
+
+	// The error of the store is returned
+	err = archiver.Upload(context.Background(), "denied.log", []byte("records"))
+	<-uploads
+	if err == nil || !strings.Contains(err.Error(), "AccessDenied") {
+		t.Errorf("upload refused by the store: err = %v", err)
+	}
+}
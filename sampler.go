@@ -0,0 +1,102 @@
+package infrastructure
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// Count the records of each fingerprint per second, records beyond the rate
+// are suppressed and reported by a periodic summary.
+type sampler struct {
+	mu      sync.Mutex
+	rate    int
+	entries map[string]*sampleEntry
+}
+
+type sampleEntry struct {
+	module     string
+	severity   string
+	message    string
+	second     int64
+	count      int
+	suppressed int
+	lastSeen   time.Time
+}
+
+type sampleSummary struct {
+	fingerprint string
+	entry       sampleEntry
+}
+
+func newSampler(_rate int) *sampler {
+	return &sampler{rate: _rate, entries: make(map[string]*sampleEntry)}
+}
+
+// Report whether the record is logged, the first records of each second are.
+func (s *sampler) allow(_module, _severity string, _err error, _now time.Time) bool {
+	fp := Fingerprint(_module, _err)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entries[fp]
+	if !ok {
+		e = &sampleEntry{module: _module, message: errors.Cause(_err).Error()}
+		s.entries[fp] = e
+	}
+	e.severity = _severity
+	e.lastSeen = _now
+	if second := _now.Unix(); second != e.second {
+		e.second, e.count = second, 0
+	}
+	e.count++
+	if e.count <= s.rate {
+		return true
+	}
+	e.suppressed++
+	return false
+}
+
+// Take the suppressed counts and forget fingerprints idle for the interval.
+func (s *sampler) summaries(_now time.Time, _idle time.Duration) []sampleSummary {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var summaries []sampleSummary
+	for fp, e := range s.entries {
+		if e.suppressed > 0 {
+			summaries = append(summaries, sampleSummary{fingerprint: fp, entry: *e})
+			e.suppressed = 0
+		} else if _now.Sub(e.lastSeen) > _idle {
+			delete(s.entries, fp)
+		}
+	}
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].fingerprint < summaries[j].fingerprint })
+	return summaries
+}
+
+func (pm *ProjectInfrastructure) reportSuppressed(_interval time.Duration) {
+	if _interval <= 0 {
+		_interval = _defaultSampleSummary
+	}
+	go func() {
+		ticker := time.NewTicker(_interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case now := <-ticker.C:
+				for _, sum := range pm.sampler.summaries(now, _interval) {
+					pm.logOutput(sum.entry.module, sum.entry.severity,
+						errors.Errorf("suppressed %d duplicates: %s", sum.entry.suppressed, sum.entry.message),
+						false, logrus.Fields{"fingerprint": sum.fingerprint, "suppressed": sum.entry.suppressed})
+				}
+			case <-pm.cancel.Done():
+				return
+			}
+		}
+	}()
+}
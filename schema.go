@@ -0,0 +1,100 @@
+package infrastructure
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// Enumerated values of the options accepting a fixed set. The log level
+// also accepts the registered severities, see logTypes.
+var optionEnums = map[string][]string{
+	"log_format":             supportLogFormats,
+	"log_color":              supportLogColors,
+	"loki_compression_level": supportCompressionLevels,
+	"otlp_compression_level": supportCompressionLevels,
+}
+
+/*
+Return the JSON Schema of a config file, see WithConfigFile
+
+Keys are the snake case option names, durations are strings such as "5s",
+also inside maps and structs.
+Options that can only be set in code, such as functions, are left out.
+*/
+func ConfigSchema() []byte {
+	defaults := reflect.ValueOf(DefaultOptions())
+	t := defaults.Type()
+
+	properties := make(map[string]interface{})
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !layerable(field) {
+			continue
+		}
+		key := optionKey(field.Name)
+		prop := typeSchema(field.Type)
+		if enum, ok := optionEnums[key]; ok {
+			prop["enum"] = enum
+		}
+		if key == "log_level" {
+			prop["enum"] = logTypes()
+		}
+		if value := defaults.Field(i); !value.IsZero() {
+			if value.Type() == durationType {
+				prop["default"] = value.Interface().(interface{ String() string }).String()
+			} else {
+				prop["default"] = value.Interface()
+			}
+		}
+		properties[key] = prop
+	}
+
+	schema := map[string]interface{}{
+		"$schema":              "https://json-schema.org/draft/2020-12/schema",
+		"title":                "ProjectInfrastructureOptions",
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+	b, _ := json.MarshalIndent(schema, "", "  ")
+	return b
+}
+
+func typeSchema(_t reflect.Type) map[string]interface{} {
+	if _t == durationType {
+		return map[string]interface{}{
+			"type":    "string",
+			"pattern": `^-?(0|([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+)$`,
+		}
+	}
+
+	switch _t.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice:
+		return map[string]interface{}{"type": "array", "items": typeSchema(_t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": typeSchema(_t.Elem())}
+	case reflect.Struct:
+		properties := make(map[string]interface{})
+		for i := 0; i < _t.NumField(); i++ {
+			field := _t.Field(i)
+			name := field.Name
+			if tag := strings.Split(field.Tag.Get("json"), ",")[0]; tag != "" {
+				name = tag
+			}
+			properties[name] = typeSchema(field.Type)
+		}
+		return map[string]interface{}{"type": "object", "properties": properties, "additionalProperties": false}
+	}
+	return map[string]interface{}{}
+}
@@ -0,0 +1,103 @@
+package infrastructure
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// A value valid against the schema, with every object and array filled so
+// that nested types are exercised.
+func schemaSample(_schema map[string]interface{}) interface{} {
+	if enum, ok := _schema["enum"].([]interface{}); ok {
+		return enum[0]
+	}
+	switch _schema["type"] {
+	case "string":
+		if _, ok := _schema["pattern"]; ok {
+			return "90h"
+		}
+		return "x"
+	case "boolean":
+		return true
+	case "integer":
+		return 1
+	case "number":
+		return 1.5
+	case "array":
+		return []interface{}{schemaSample(_schema["items"].(map[string]interface{}))}
+	case "object":
+		obj := make(map[string]interface{})
+		if props, ok := _schema["properties"].(map[string]interface{}); ok {
+			for name, prop := range props {
+				obj[name] = schemaSample(prop.(map[string]interface{}))
+			}
+		} else if elem, ok := _schema["additionalProperties"].(map[string]interface{}); ok {
+			obj["key"] = schemaSample(elem)
+		}
+		return obj
+	}
+	return nil
+}
+
+func TestConfigSchemaRoundTrip(t *testing.T) {
+	var schema map[string]interface{}
+	if err := json.Unmarshal(ConfigSchema(), &schema); err != nil {
+		t.Fatal(err)
+	}
+	config := make(map[string]interface{})
+	for key, prop := range schema["properties"].(map[string]interface{}) {
+		config[key] = schemaSample(prop.(map[string]interface{}))
+	}
+	data, err := json.Marshal(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := DefaultOptions()
+	if err := applyConfigFile(&opts, path, map[string]string{}); err != nil {
+		t.Fatalf("config built from the schema does not load: %v", err)
+	}
+	tests := []struct {
+		key  string
+		got  interface{}
+		want interface{}
+	}{
+		{"flush_timeout", opts.FlushTimeout, 90 * time.Hour},
+		{"severity_retention", opts.SeverityRetention["key"], 90 * time.Hour},
+		{"module_budgets", opts.ModuleBudgets["key"], ModuleBudget{Bytes: 1, Entries: 1, Interval: 90 * time.Hour}},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %v, want %v", tt.key, tt.got, tt.want)
+		}
+	}
+}
+
+func TestDecodeOptionNestedDurations(t *testing.T) {
+	tests := []struct {
+		data string
+		ok   bool
+	}{
+		{`{"severity_retention": {"error": "90h"}}`, true},
+		{`{"module_budgets": {"x": {"Interval": "1m", "Entries": 10}}}`, true},
+		{`{"severity_retention": {"error": 5}}`, false},
+		{`{"module_budgets": {"x": {"Period": "1m"}}}`, false},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "config.json")
+		if err := os.WriteFile(path, []byte(tt.data), 0600); err != nil {
+			t.Fatal(err)
+		}
+		opts := DefaultOptions()
+		if err := applyConfigFile(&opts, path, map[string]string{}); (err == nil) != tt.ok {
+			t.Errorf("%s: error %v", tt.data, err)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package infrastructure
+
+import (
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// Latest schema of the json and logfmt records, see WithSchemaVersion.
+const LogSchemaVersion = 2
+
+/*
+Fields added to the records by each schema version
+
+Version 1 is the json record as first released: timestamp, severity, module,
+error and the fields of the record. Version 2 adds the environment labels,
+the caller, the trace and request IDs, the stack diff references and the
+severities added with RegisterSeverity.
+*/
+var schemaFields = map[int][]string{
+	2: {"env", "region", "cluster", "caller", "trace_id", "request_id", "stack_ref", "stack_base"},
+}
+
+func validSchemaVersion(_version int) error {
+	if _version < 0 || _version > LogSchemaVersion {
+		return errors.Errorf("invalid schema version %d, valid values are 1 to %d, 0 for the latest", _version, LogSchemaVersion)
+	}
+	return nil
+}
+
+// The fields and severity of the entry in the schema version, the entry
+// data itself for the latest one.
+func pinSchema(_entry *logrus.Entry, _version int) (logrus.Fields, string) {
+	severity := entrySeverity(_entry)
+	if _version == 0 || _version >= LogSchemaVersion {
+		return _entry.Data, severity
+	}
+	data := make(logrus.Fields, len(_entry.Data))
+	for k, v := range _entry.Data {
+		data[k] = v
+	}
+	for v := _version + 1; v <= LogSchemaVersion; v++ {
+		for _, k := range schemaFields[v] {
+			delete(data, k)
+		}
+	}
+	if isCustomSeverity(severity) {
+		severity = builtinSeverityName(_entry.Level)
+	}
+	return data, severity
+}
+
+// The built-in severity of a level, trace has none and is written as debug.
+func builtinSeverityName(_level logrus.Level) string {
+	if _level == logrus.TraceLevel {
+		return "debug"
+	}
+	return logLevelName(_level)
+}
+
+/*
+Convert a decoded json or logfmt record between schema versions, e.g. to
+feed records of a pinned fleet and of an upgraded one to the same parser
+
+Downgrading drops the fields added since the target version and writes the
+registered severities as the built-in severity of their level, unknown ones
+as "error". Upgrading returns the record as is, it simply lacks the added
+fields. The record is not modified.
+*/
+func ConvertLogRecord(_record map[string]interface{}, _from, _to int) (map[string]interface{}, error) {
+	for _, v := range []int{_from, _to} {
+		if v < 1 || v > LogSchemaVersion {
+			return nil, errors.Errorf("invalid schema version %d, valid values are 1 to %d", v, LogSchemaVersion)
+		}
+	}
+	record := make(map[string]interface{}, len(_record))
+	for k, v := range _record {
+		record[k] = v
+	}
+	for v := _to + 1; v <= _from; v++ {
+		for _, k := range schemaFields[v] {
+			delete(record, k)
+		}
+	}
+	if severity, ok := record["severity"].(string); ok && _to < 2 {
+		if _, builtin := builtinSeverities[severity]; !builtin {
+			level := logrus.ErrorLevel
+			if def, ok := severityByName(severity); ok {
+				level = def.level
+			}
+			record["severity"] = builtinSeverityName(level)
+		}
+	}
+	return record, nil
+}
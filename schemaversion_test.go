@@ -0,0 +1,133 @@
+package infrastructure
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func registerNotice(_t *testing.T) {
+	// Severities are process wide, the test may run more than once.
+	if _, ok := severityByName("notice"); !ok {
+		if err := RegisterSeverity(Severity{Name: "notice", Relative: "info", Above: true}); err != nil {
+			_t.Fatal(err)
+		}
+	}
+}
+
+func TestFormatEntrySchemaVersion(t *testing.T) {
+	registerNotice(t)
+	entry := Entry{
+		Time:     time.Date(2026, 3, 4, 5, 6, 7, 0, time.UTC),
+		Module:   "api",
+		Severity: "notice",
+		Err:      errors.New("cache warm"),
+		Fields:   map[string]interface{}{"trace_id": "t1", "user": "u1"},
+	}
+	tests := []struct {
+		format  string
+		version int
+		want    []string
+		missing []string
+	}{
+		{"json", 0, []string{`"severity":"notice"`, `"trace_id":"t1"`, `"env":"prod"`, `"user":"u1"`}, nil},
+		{"json", 2, []string{`"severity":"notice"`, `"trace_id":"t1"`}, nil},
+		{"json", 1, []string{`"severity":"info"`, `"user":"u1"`, `"module":"api"`}, []string{"trace_id", "env"}},
+		{"logfmt", 1, []string{`severity=info`, `user=u1`}, []string{"trace_id", "env"}},
+	}
+	for _, tt := range tests {
+		opts := DefaultOptions()
+		opts.LogFormat = tt.format
+		opts.SchemaVersion = tt.version
+		opts.Environment = "prod"
+		b, err := FormatEntry(opts, entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(string(b), want) {
+				t.Errorf("%s v%d: %s does not contain %s", tt.format, tt.version, b, want)
+			}
+		}
+		for _, missing := range tt.missing {
+			if strings.Contains(string(b), missing) {
+				t.Errorf("%s v%d: %s contains %s", tt.format, tt.version, b, missing)
+			}
+		}
+	}
+}
+
+func TestConvertLogRecord(t *testing.T) {
+	registerNotice(t)
+	record := map[string]interface{}{
+		"timestamp": "2026-03-04T05:06:07.000Z", "severity": "notice", "module": "api", "error": "cache warm",
+		"caller": "main.go:12", "request_id": "r1", "user": "u1",
+	}
+	tests := []struct {
+		from, to int
+		want     map[string]interface{}
+		ok       bool
+	}{
+		{2, 1, map[string]interface{}{
+			"timestamp": "2026-03-04T05:06:07.000Z", "severity": "info", "module": "api", "error": "cache warm", "user": "u1",
+		}, true},
+		{1, 2, record, true},
+		{2, 2, record, true},
+		{3, 1, nil, false},
+		{2, 0, nil, false},
+	}
+	for _, tt := range tests {
+		got, err := ConvertLogRecord(record, tt.from, tt.to)
+		if (err == nil) != tt.ok {
+			t.Errorf("%d to %d: err = %v", tt.from, tt.to, err)
+			continue
+		}
+		if tt.ok && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%d to %d = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+	if record["severity"] != "notice" || record["caller"] == nil {
+		t.Fatalf("record modified: %v", record)
+	}
+}
+
+func TestSchemaVersionPinned(t *testing.T) {
+	var buf syncBuffer
+	pm, err := NewProjectInfrastructure(context.Background(),
+		WithLibraryMode(true),
+		WithLogWriter(&buf),
+		WithLogFormat("json"),
+		WithSchemaVersion(1),
+		WithEnvironment("prod", "eu", "c1"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pm.Shutdown()
+	pm.ErrorTransmitCtx(WithTraceID(context.Background(), "0af7651916cd43dd8448eb211c80319c"), "api", "warn", errors.New("slow"), false, false)
+
+	var record map[string]interface{}
+	if err := json.Unmarshal([]byte(buf.String()), &record); err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range []string{"env", "region", "cluster", "trace_id"} {
+		if _, ok := record[k]; ok {
+			t.Errorf("%s written in schema 1: %v", k, record)
+		}
+	}
+
+	_, err = NewProjectInfrastructure(context.Background(),
+		WithLibraryMode(true),
+		WithLogWriter(io.Discard),
+		WithSchemaVersion(LogSchemaVersion+1),
+	)
+	if err == nil {
+		t.Fatal("unknown schema version accepted")
+	}
+}
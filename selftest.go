@@ -0,0 +1,81 @@
+package infrastructure
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+/*
+Exercise the configured log pipeline end-to-end
+
+The check writes a record to every log output and, for file output, verifies
+that rotated files can be created next to the log path. All failures are
+collected into one report error so misconfiguration is visible at startup.
+*/
+func (pm *ProjectInfrastructure) SelfTest() error {
+	var failures []string
+
+	// Format and write the record by hand, logrus does not report write errors.
+	entry := logrus.NewEntry(pm.logger)
+	entry.Time = time.Now()
+	entry.Level = logrus.InfoLevel
+	if pm.structured {
+		entry.Data["module"] = "selftest"
+		entry.Message = "self test record"
+	} else {
+		entry.Message = pm.logFormat(errors.New("self test record"), "selftest", "info")
+	}
+	for i, o := range pm.outputs {
+		e := *entry
+		if i > 0 && pm.colored {
+			e.Message = stripANSI(e.Message)
+		}
+		line, err := pm.logger.Formatter.Format(&e)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("log format %s: %v", pm.options.LogFormat, err))
+			break
+		}
+		if lw, ok := o.w.(leveledWriter); ok {
+			err = lw.WriteLevel(e.Level, line)
+		} else {
+			_, err = o.w.Write(line)
+		}
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("log output %s: %v", o.name, err))
+		}
+	}
+
+	if pm.hasLogOutput("file") {
+		dir := filepath.Dir(pm.options.LogPath)
+		if pm.options.LogRotationPattern != "" {
+			dir = filepath.Dir(pm.options.LogRotationPattern)
+		}
+		if err := checkDirWritable(dir); err != nil {
+			failures = append(failures, fmt.Sprintf("log rotation %s: %v", pm.options.LogPath, err))
+		}
+	}
+
+	if len(failures) > 0 {
+		return errors.Errorf("self test failed:\n  %s", strings.Join(failures, "\n  "))
+	}
+	return nil
+}
+
+func checkDirWritable(_dir string) error {
+	// Directories built from a strftime pattern only exist after rotation.
+	if strings.Contains(_dir, "%") {
+		return nil
+	}
+	f, err := os.CreateTemp(_dir, ".selftest-*")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
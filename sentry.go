@@ -0,0 +1,99 @@
+package infrastructure
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+var sentryLevels = map[logrus.Level]sentry.Level{
+	logrus.TraceLevel: sentry.LevelDebug,
+	logrus.DebugLevel: sentry.LevelDebug,
+	logrus.InfoLevel:  sentry.LevelInfo,
+	logrus.WarnLevel:  sentry.LevelWarning,
+	logrus.ErrorLevel: sentry.LevelError,
+	logrus.FatalLevel: sentry.LevelFatal,
+	logrus.PanicLevel: sentry.LevelFatal,
+}
+
+// Report records at or above a severity as Sentry events.
+type sentryReporter struct {
+	client  *sentry.Client
+	minRank int
+}
+
+func (pm *ProjectInfrastructure) initSentry(_opts ProjectInfrastructureOptions) error {
+	if _opts.SentryMinSeverity == "" {
+		_opts.SentryMinSeverity = _defaultSentrySeverity
+	}
+	minRank, err := severityRank(_opts.SentryMinSeverity)
+	if err != nil {
+		return errors.Errorf("invalid sentry severity %s, valid values are %s", _opts.SentryMinSeverity, logTypes())
+	}
+	client, err := sentry.NewClient(sentry.ClientOptions{
+		Dsn:         _opts.SentryDSN,
+		Environment: _opts.Environment,
+	})
+	if err != nil {
+		return errors.Errorf("init sentry: %v", err)
+	}
+	pm.sentry = &sentryReporter{client: client, minRank: minRank}
+	pm.RegisterFlusher("sentry", func(ctx context.Context) error {
+		timeout := pm.options.FlushTimeout
+		if deadline, ok := ctx.Deadline(); ok {
+			timeout = time.Until(deadline)
+		}
+		if !client.Flush(timeout) {
+			return errors.New("sentry events not sent before the timeout")
+		}
+		return nil
+	})
+	return nil
+}
+
+// Send an event with the module as tag, the record fields as extra data and
+// the deepest pkg/errors stack of the chain as stacktrace.
+func (s *sentryReporter) capture(_module, _severity string, _err error, _fields logrus.Fields) {
+	if severityRankOrError(_severity) > s.minRank {
+		return
+	}
+	level, err := parseLogLevel(_severity)
+	if err != nil {
+		level = logrus.ErrorLevel
+	}
+
+	cause := errors.Cause(_err)
+	event := sentry.NewEvent()
+	event.Level = sentryLevels[level]
+	event.Message = _err.Error()
+	event.Tags["module"] = _module
+	for k, v := range _fields {
+		event.Extra[k] = v
+	}
+	event.Exception = []sentry.Exception{{
+		Type:       fmt.Sprintf("%T", cause),
+		Value:      cause.Error(),
+		Module:     _module,
+		Stacktrace: deepestStacktrace(_err),
+	}}
+	s.client.CaptureEvent(event, nil, nil)
+}
+
+func deepestStacktrace(_err error) *sentry.Stacktrace {
+	var st *sentry.Stacktrace
+	for e := _err; e != nil; {
+		if s := sentry.ExtractStacktrace(e); s != nil {
+			st = s
+		}
+		c, ok := e.(interface{ Cause() error })
+		if !ok {
+			break
+		}
+		e = c.Cause()
+	}
+	return st
+}
@@ -0,0 +1,214 @@
+package infrastructure
+
+import (
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+/*
+A severity added to the built-in ones with RegisterSeverity
+
+@Name: severity name passed to ErrorTransmit and used in the options, e.g. "critical"
+
+@Relative: built-in or registered severity it is ordered next to
+
+@Above: order it more severe than Relative instead of less severe
+
+@Level: logrus level the records are written at and routed by, "trace" to "error", the level of Relative by default
+*/
+type Severity struct {
+	Name     string
+	Relative string
+	Above    bool
+	Level    string
+}
+
+// Rank of the severity in the ordering of all severities, lower is more
+// severe. Built-ins are spaced apart to leave room for the registered ones.
+type severityDef struct {
+	level logrus.Level
+	rank  int
+}
+
+var builtinSeverities = map[string]severityDef{
+	"panic": {logrus.PanicLevel, 0},
+	"fatal": {logrus.FatalLevel, 1000},
+	"error": {logrus.ErrorLevel, 2000},
+	"warn":  {logrus.WarnLevel, 3000},
+	"info":  {logrus.InfoLevel, 4000},
+	"debug": {logrus.DebugLevel, 5000},
+}
+
+var customSeverities struct {
+	sync.RWMutex
+	defs map[string]severityDef
+	// Registration order, listed after the built-ins by logTypes
+	names []string
+}
+
+// The built-in and registered severity names, a snapshot safe to keep.
+func logTypes() []string {
+	customSeverities.RLock()
+	defer customSeverities.RUnlock()
+	return lockedLogTypes()
+}
+
+/*
+Register an additional severity, e.g. "critical" above "error" or "trace" below "debug"
+
+The severity is ordered right next to its relative, so level filters,
+module levels and minimum severities such as the Sentry one honor it, and
+it is written at its logrus level with its name in the "severity" field.
+Severities are shared by all instances, register them before creating one.
+*/
+func RegisterSeverity(_severity Severity) error {
+	customSeverities.Lock()
+	defer customSeverities.Unlock()
+
+	if _severity.Name == "" {
+		return errors.New("severity without name")
+	}
+	if _, ok := lookupSeverity(_severity.Name); ok {
+		return errors.Errorf("severity %s already exists", _severity.Name)
+	}
+	relative, ok := lookupSeverity(_severity.Relative)
+	if !ok {
+		return errors.Errorf("invalid relative severity %s, valid values are %s", _severity.Relative, lockedLogTypes())
+	}
+
+	level := relative.level
+	if _severity.Level != "" {
+		l, err := logrus.ParseLevel(_severity.Level)
+		if err != nil || l < logrus.ErrorLevel {
+			return errors.Errorf("invalid level %s of severity %s, valid values are trace, debug, info, warn and error", _severity.Level, _severity.Name)
+		}
+		level = l
+	}
+	if level < logrus.ErrorLevel {
+		return errors.Errorf("severity %s can not be relative to %s at its level, fatal and panic terminate the process", _severity.Name, _severity.Relative)
+	}
+
+	// Halfway to the closest severity on that side.
+	neighbor := relative.rank + 1000
+	if _severity.Above {
+		neighbor = relative.rank - 1000
+	}
+	for _, defs := range []map[string]severityDef{builtinSeverities, customSeverities.defs} {
+		for _, def := range defs {
+			if _severity.Above && def.rank < relative.rank && def.rank > neighbor {
+				neighbor = def.rank
+			}
+			if !_severity.Above && def.rank > relative.rank && def.rank < neighbor {
+				neighbor = def.rank
+			}
+		}
+	}
+	rank := (relative.rank + neighbor) / 2
+	if rank == relative.rank || rank == neighbor {
+		return errors.Errorf("no room for severity %s next to %s", _severity.Name, _severity.Relative)
+	}
+
+	if customSeverities.defs == nil {
+		customSeverities.defs = make(map[string]severityDef)
+	}
+	customSeverities.defs[_severity.Name] = severityDef{level: level, rank: rank}
+	customSeverities.names = append(customSeverities.names, _severity.Name)
+	return nil
+}
+
+// logTypes for a caller holding the lock.
+func lockedLogTypes() []string {
+	return append(append([]string(nil), supportLogTypes...), customSeverities.names...)
+}
+
+// Definition of a built-in or registered severity, the caller holds the lock.
+func lookupSeverity(_name string) (severityDef, bool) {
+	if def, ok := builtinSeverities[_name]; ok {
+		return def, true
+	}
+	def, ok := customSeverities.defs[_name]
+	return def, ok
+}
+
+func severityByName(_name string) (severityDef, bool) {
+	if def, ok := builtinSeverities[_name]; ok {
+		return def, true
+	}
+	customSeverities.RLock()
+	defer customSeverities.RUnlock()
+	def, ok := customSeverities.defs[_name]
+	return def, ok
+}
+
+// Report whether the severity was added with RegisterSeverity.
+func isCustomSeverity(_name string) bool {
+	_, builtin := builtinSeverities[_name]
+	if builtin {
+		return false
+	}
+	_, ok := severityByName(_name)
+	return ok
+}
+
+// Rank of a severity name, see severityDef.
+func severityRank(_name string) (int, error) {
+	if def, ok := severityByName(_name); ok {
+		return def.rank, nil
+	}
+	return 0, errors.Errorf("invalid log level %s, valid values are %s", _name, logTypes())
+}
+
+// Rank of a severity, unknown severities rank as errors.
+func severityRankOrError(_name string) int {
+	if rank, err := severityRank(_name); err == nil {
+		return rank
+	}
+	return builtinSeverities["error"].rank
+}
+
+// Name of the severity of a rank.
+func severityName(_rank int) string {
+	for name, def := range builtinSeverities {
+		if def.rank == _rank {
+			return name
+		}
+	}
+	customSeverities.RLock()
+	defer customSeverities.RUnlock()
+	for name, def := range customSeverities.defs {
+		if def.rank == _rank {
+			return name
+		}
+	}
+	return ""
+}
+
+// The most verbose logrus level of the severities up to the rank, the
+// level the logger must let through.
+func rankLoggerLevel(_rank int) logrus.Level {
+	level := logrus.PanicLevel
+	for _, def := range builtinSeverities {
+		if def.rank <= _rank && def.level > level {
+			level = def.level
+		}
+	}
+	customSeverities.RLock()
+	defer customSeverities.RUnlock()
+	for _, def := range customSeverities.defs {
+		if def.rank <= _rank && def.level > level {
+			level = def.level
+		}
+	}
+	return level
+}
+
+// Name of the severity of an entry, the registered one it carries or that
+// of its level.
+func entrySeverity(_entry *logrus.Entry) string {
+	if s, ok := _entry.Data["severity"].(string); ok && s != "" {
+		return s
+	}
+	return logLevelName(_entry.Level)
+}
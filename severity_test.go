@@ -0,0 +1,64 @@
+package infrastructure
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestSeverityOrdering(t *testing.T) {
+	for _, s := range []Severity{
+		{Name: "critical", Relative: "error", Above: true},
+		{Name: "notice", Relative: "info", Above: true},
+		{Name: "trace2", Relative: "debug"},
+	} {
+		if _, ok := severityByName(s.Name); ok {
+			continue
+		}
+		if err := RegisterSeverity(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	order := []string{"panic", "fatal", "critical", "error", "warn", "notice", "info", "debug", "trace2"}
+	for i := 1; i < len(order); i++ {
+		if severityRankOrError(order[i-1]) >= severityRankOrError(order[i]) {
+			t.Errorf("%s is not more severe than %s", order[i-1], order[i])
+		}
+	}
+
+	tests := []struct {
+		severity Severity
+		ok       bool
+	}{
+		{Severity{Name: "critical", Relative: "error"}, false},
+		{Severity{Name: "", Relative: "error"}, false},
+		{Severity{Name: "x", Relative: "unknown"}, false},
+		{Severity{Name: "x", Relative: "fatal"}, false},
+		{Severity{Name: "x", Relative: "info", Level: "fatal"}, false},
+	}
+	for _, tt := range tests {
+		if err := RegisterSeverity(tt.severity); (err == nil) != tt.ok {
+			t.Errorf("RegisterSeverity(%+v) err = %v", tt.severity, err)
+		}
+	}
+}
+
+// Run with -race, registering and listing severities concurrently.
+func TestRegisterSeverityConcurrent(t *testing.T) {
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			RegisterSeverity(Severity{Name: fmt.Sprintf("concurrent%d", i), Relative: "warn"})
+		}(i)
+		go func() {
+			defer wg.Done()
+			ConfigSchema()
+			parseColorTheme(nil)
+			severityRank("unknown")
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,108 @@
+package infrastructure
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+/*
+Handle shutdown signals
+
+The first signal starts a graceful shutdown through ResourceRelease and exits
+with code 0 once it is done, or the WithReleaseFailureExitCode code when a
+release hook failed. Receiving ForceExitSignals signals in total, or
+the graceful shutdown taking longer than ShutdownTimeout, exits immediately
+with code 1 after flushing stdout. A ForceExitSignals of 0 never forces the
+exit on signals and a ShutdownTimeout of 0 waits for the shutdown forever.
+*/
+func (pm *ProjectInfrastructure) handleSignals(_opts ProjectInfrastructureOptions) {
+	ch := make(chan os.Signal, _opts.ForceExitSignals+1)
+	signal.Notify(ch, _opts.ShutdownSignals...)
+
+	go func() {
+		defer signal.Stop(ch)
+
+		var received uint
+		var released chan struct{}
+		var timeout <-chan time.Time
+		cancelled := pm.cancel.Done()
+		for {
+			select {
+			case sig := <-ch:
+				received++
+				if forceExitReached(received, _opts.ForceExitSignals) {
+					pm.logOutput("signal", "error", errors.Errorf("received %s %d times, forcing exit", sig, received), false, nil)
+					pm.forceExit()
+					return
+				}
+				if received == 1 {
+					pm.logOutput("signal", "warn", errors.Errorf("received %s, starting graceful shutdown", sig), false, nil)
+					pm.OperatorAction("signal", "signal.shutdown", sig.String(), nil)
+					// Our own ResourceRelease cancels the context, keep counting signals.
+					cancelled = nil
+					released = make(chan struct{})
+					if _opts.ShutdownTimeout > 0 {
+						timeout = time.After(_opts.ShutdownTimeout)
+					}
+					go func() {
+						pm.ResourceRelease()
+						close(released)
+					}()
+					continue
+				}
+				if _opts.ForceExitSignals == 0 {
+					pm.logOutput("signal", "warn", errors.Errorf("received %s again, shutdown in progress", sig), false, nil)
+					continue
+				}
+				pm.logOutput("signal", "warn", errors.Errorf("received %s again (%d/%d), shutdown in progress", sig, received, _opts.ForceExitSignals), false, nil)
+			case <-released:
+				pm.exit(0)
+				return
+			case <-timeout:
+				pm.logOutput("signal", "error", errors.Errorf("graceful shutdown exceeded %s, forcing exit", _opts.ShutdownTimeout), false, nil)
+				pm.forceExit()
+				return
+			case <-cancelled:
+				// Released by the program itself, stop handling signals.
+				return
+			}
+		}
+	}()
+}
+
+// Whether the signals received so far force an immediate exit, a limit of
+// 0 disables it.
+func forceExitReached(_received, _limit uint) bool {
+	return _limit > 0 && _received >= _limit
+}
+
+// Reopen the log files on SIGHUP until resources are released.
+func (pm *ProjectInfrastructure) handleReopenSignal() {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGHUP)
+
+	go func() {
+		defer signal.Stop(ch)
+		for {
+			select {
+			case <-ch:
+				if err := pm.ReopenLogs(); err != nil {
+					pm.logOutput("signal", "error", err, false, nil)
+					continue
+				}
+				pm.logOutput("signal", "info", errors.New("log files reopened on SIGHUP"), false, nil)
+			case <-pm.cancel.Done():
+				return
+			}
+		}
+	}()
+}
+
+func (pm *ProjectInfrastructure) forceExit() {
+	os.Stdout.Sync()
+	pm.exit(1)
+}
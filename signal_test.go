@@ -0,0 +1,23 @@
+package infrastructure
+
+import "testing"
+
+func TestForceExitReached(t *testing.T) {
+	tests := []struct {
+		received uint
+		limit    uint
+		want     bool
+	}{
+		{1, 0, false},
+		{5, 0, false},
+		{1, 1, true},
+		{1, 2, false},
+		{2, 2, true},
+		{3, 2, true},
+	}
+	for _, tt := range tests {
+		if got := forceExitReached(tt.received, tt.limit); got != tt.want {
+			t.Errorf("forceExitReached(%d, %d) = %v, want %v", tt.received, tt.limit, got, tt.want)
+		}
+	}
+}
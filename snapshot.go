@@ -0,0 +1,120 @@
+package infrastructure
+
+import (
+	"reflect"
+	"runtime"
+	"time"
+)
+
+// Options left out of snapshots because they carry credentials.
+var secretOptions = map[string]bool{"SentryDSN": true, "OTLPHeaders": true}
+
+/*
+Read-only view of a ProjectInfrastructure, see Snapshot
+
+@Options: the config file options by key, durations as strings and credentials redacted
+
+@Sinks: the log outputs and the records they hold, waiting to be sent
+
+@Health: last result of every host probe
+
+@Counters: emitted records by module and severity, as returned by Stats
+
+@PersistentCounters: totals since the first run, see WithCounterPersistence
+*/
+type InfraSnapshot struct {
+	Time       time.Time                    `json:"time"`
+	State      string                       `json:"state"`
+	Uptime     string                       `json:"uptime"`
+	ExitCode   int                          `json:"exit_code"`
+	LogLevel   string                       `json:"log_level"`
+	Options    map[string]interface{}       `json:"options"`
+	Sinks      []SinkSnapshot               `json:"sinks"`
+	Components []string                     `json:"components"`
+	Goroutines int                          `json:"goroutines"`
+	Health     map[string]HealthSnapshot    `json:"health"`
+	Counters   map[string]map[string]uint64 `json:"counters"`
+
+	PersistentCounters map[string]uint64 `json:"persistent_counters,omitempty"`
+}
+
+type SinkSnapshot struct {
+	Name   string `json:"name"`
+	Queued int    `json:"queued"`
+}
+
+type HealthSnapshot struct {
+	Time   time.Time              `json:"time"`
+	Values map[string]interface{} `json:"values,omitempty"`
+	Error  string                 `json:"error,omitempty"`
+}
+
+// Outputs that batch or buffer records before sending them.
+type queuedWriter interface {
+	queued() int
+}
+
+// Take a serializable snapshot of the state, e.g. for heartbeat payloads.
+func (pm *ProjectInfrastructure) Snapshot() InfraSnapshot {
+	now := time.Now()
+	s := InfraSnapshot{
+		Time:       now,
+		State:      pm.State().String(),
+		Uptime:     now.Sub(pm.started).Round(time.Second).String(),
+		ExitCode:   pm.ExitCode(),
+		LogLevel:   pm.LogLevel(),
+		Options:    snapshotOptions(*pm.options),
+		Sinks:      make([]SinkSnapshot, 0, len(pm.outputs)),
+		Components: pm.Components(),
+		Goroutines: runtime.NumGoroutine(),
+		Health:     make(map[string]HealthSnapshot),
+		Counters:   pm.Stats(),
+
+		PersistentCounters: pm.PersistentCounters(),
+	}
+	for _, o := range pm.outputs {
+		sink := SinkSnapshot{Name: o.name}
+		if q, ok := o.w.(queuedWriter); ok {
+			sink.Queued = q.queued()
+		}
+		s.Sinks = append(s.Sinks, sink)
+	}
+	for name, r := range pm.HostHealth() {
+		h := HealthSnapshot{Time: r.Time, Values: r.Values}
+		if r.Err != nil {
+			h.Error = r.Err.Error()
+		}
+		s.Health[name] = h
+	}
+	return s
+}
+
+func snapshotOptions(_opts ProjectInfrastructureOptions) map[string]interface{} {
+	options := make(map[string]interface{})
+	v := reflect.ValueOf(_opts)
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !layerable(field) {
+			continue
+		}
+		value := v.Field(i).Interface()
+		switch {
+		case secretOptions[field.Name]:
+			if !v.Field(i).IsZero() {
+				value = redactedText
+			}
+		case field.Type == durationType:
+			value = value.(time.Duration).String()
+		case field.Type.Kind() == reflect.Map && field.Type.Elem() == durationType:
+			durations := make(map[string]string)
+			iter := v.Field(i).MapRange()
+			for iter.Next() {
+				durations[iter.Key().String()] = iter.Value().Interface().(time.Duration).String()
+			}
+			value = durations
+		}
+		options[optionKey(field.Name)] = value
+	}
+	return options
+}
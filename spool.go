@@ -0,0 +1,173 @@
+package infrastructure
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+/*
+Batches of a sink kept until the sink acknowledged them, oldest first
+
+Every batch is stored before it is sent and removed once the sink accepted
+it, and keeps its idempotency key across retries and replays. With a
+directory the batches survive a collector offline for hours and a restart;
+files are named <time>-<seq>-<records>-<key><ext>, the extension tells the
+encoding, a batch of another encoding than the sink uses now is dropped.
+Without one they are kept in memory and lost on exit. Beyond the size cap
+the oldest batches are removed.
+*/
+type batchSpool struct {
+	dir     string
+	ext     string
+	maxSize int64
+
+	mu  sync.Mutex
+	seq uint64
+	// Batches of a spool without directory
+	memory []spooledBatch
+}
+
+func openBatchSpool(_dir, _ext string, _maxSize int64) (*batchSpool, error) {
+	if err := os.MkdirAll(_dir, 0o700); err != nil {
+		return nil, errors.Wrap(err, "create spool directory")
+	}
+	return &batchSpool{dir: _dir, ext: _ext, maxSize: _maxSize}, nil
+}
+
+func newMemorySpool(_maxSize int64) *batchSpool {
+	return &batchSpool{maxSize: _maxSize}
+}
+
+// A stored batch.
+type spooledBatch struct {
+	name  string
+	key   string
+	count int
+	size  int64
+	// Body of a batch kept in memory
+	body []byte
+}
+
+// Store the batch under a new idempotency key, durably with a directory.
+func (s *batchSpool) add(_body []byte, _count int) error {
+	key := newUUID()
+	s.mu.Lock()
+	s.seq++
+	name := fmt.Sprintf("%020d-%06d-%d-%s", time.Now().UnixNano(), s.seq%1000000, _count, key)
+	if s.dir == "" {
+		s.memory = append(s.memory, spooledBatch{name: name, key: key, count: _count, size: int64(len(_body)), body: _body})
+		s.mu.Unlock()
+		return nil
+	}
+	s.mu.Unlock()
+
+	f, err := os.CreateTemp(s.dir, ".batch-*")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(_body); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), filepath.Join(s.dir, name+s.ext))
+}
+
+// The batches oldest first, the records of those of another encoding are
+// returned as dropped and the files removed.
+func (s *batchSpool) batches() ([]spooledBatch, int) {
+	if s.dir == "" {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return append([]spooledBatch(nil), s.memory...), 0
+	}
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, 0
+	}
+	var batches []spooledBatch
+	dropped := 0
+	for _, e := range entries {
+		name := e.Name()
+		if !e.Type().IsRegular() || strings.HasPrefix(name, ".") {
+			continue
+		}
+		ext := filepath.Ext(name)
+		parts := strings.SplitN(strings.TrimSuffix(name, ext), "-", 4)
+		if len(parts) != 4 {
+			continue
+		}
+		count, err := strconv.Atoi(parts[2])
+		if err != nil {
+			continue
+		}
+		if ext != s.ext {
+			os.Remove(filepath.Join(s.dir, name))
+			dropped += count
+			continue
+		}
+		var size int64
+		if fi, err := e.Info(); err == nil {
+			size = fi.Size()
+		}
+		batches = append(batches, spooledBatch{name: name, key: parts[3], count: count, size: size})
+	}
+	sort.Slice(batches, func(i, j int) bool { return batches[i].name < batches[j].name })
+	return batches, dropped
+}
+
+// Remove the oldest batches beyond the size cap, the records dropped are
+// returned with the batches left.
+func (s *batchSpool) evict(_batches []spooledBatch) ([]spooledBatch, int) {
+	var total int64
+	for _, b := range _batches {
+		total += b.size
+	}
+	dropped := 0
+	for len(_batches) > 0 && s.maxSize > 0 && total > s.maxSize {
+		s.remove(_batches[0])
+		total -= _batches[0].size
+		dropped += _batches[0].count
+		_batches = _batches[1:]
+	}
+	return _batches, dropped
+}
+
+func (s *batchSpool) read(_b spooledBatch) ([]byte, error) {
+	if s.dir == "" {
+		return _b.body, nil
+	}
+	return os.ReadFile(filepath.Join(s.dir, _b.name))
+}
+
+func (s *batchSpool) remove(_b spooledBatch) {
+	if s.dir != "" {
+		os.Remove(filepath.Join(s.dir, _b.name))
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, b := range s.memory {
+		if b.name == _b.name {
+			s.memory = append(s.memory[:i], s.memory[i+1:]...)
+			return
+		}
+	}
+}
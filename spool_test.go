@@ -0,0 +1,226 @@
+package infrastructure
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Collector answering 503 while down, rejecting the body "rej" and
+// recording the accepted bodies and the idempotency key of every request.
+type spoolCollector struct {
+	down int32
+
+	mu       sync.Mutex
+	accepted []string
+	keys     []string
+}
+
+func (c *spoolCollector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	c.mu.Lock()
+	c.keys = append(c.keys, r.Header.Get("Idempotency-Key"))
+	c.mu.Unlock()
+	if atomic.LoadInt32(&c.down) == 1 {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	if string(body) == "rej" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	c.mu.Lock()
+	c.accepted = append(c.accepted, string(body))
+	c.mu.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (c *spoolCollector) received() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.accepted...)
+}
+
+func (c *spoolCollector) requestKeys() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.keys...)
+}
+
+// Sink spooling under dir, taking the queued bodies one per push.
+func spoolSink(_t *testing.T, _url, _dir string, _maxSize int64, _queue *[]string) *batchSink {
+	_t.Helper()
+	b := newBatchSink(&ProjectInfrastructure{}, "test", "push", _url, func() ([]byte, int, error) {
+		if len(*_queue) == 0 {
+			return nil, 0, nil
+		}
+		body := (*_queue)[0]
+		*_queue = (*_queue)[1:]
+		return []byte(body), 1, nil
+	})
+	opts := DefaultOptions()
+	opts.SpoolDir = _dir
+	opts.SpoolMaxSize = _maxSize
+	if err := b.openSpool(opts, ".json"); err != nil {
+		_t.Fatal(err)
+	}
+	return b
+}
+
+// Push with a deadline shorter than the retry backoff, a collector down
+// fails the push at once.
+func spoolPush(_b *batchSink) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	return _b.push(ctx)
+}
+
+func spooledFiles(_t *testing.T, _dir string) int {
+	_t.Helper()
+	entries, err := os.ReadDir(filepath.Join(_dir, "test"))
+	if err != nil {
+		_t.Fatal(err)
+	}
+	return len(entries)
+}
+
+func TestBatchSpoolReplay(t *testing.T) {
+	collector := &spoolCollector{down: 1}
+	srv := httptest.NewServer(collector)
+	defer srv.Close()
+	dir := t.TempDir()
+
+	queue := []string{"a", "b", "c"}
+	b := spoolSink(t, srv.URL, dir, 0, &queue)
+	for range []int{1, 2, 3} {
+		if err := spoolPush(b); err == nil || !strings.Contains(err.Error(), "kept in the spool") {
+			t.Fatalf("push while down: err = %v", err)
+		}
+	}
+	if n := spooledFiles(t, dir); n != 3 {
+		t.Fatalf("%d batches spooled, want 3", n)
+	}
+
+	// A new sink on the same directory, as after a restart
+	queue = []string{"d"}
+	b = spoolSink(t, srv.URL, dir, 0, &queue)
+	atomic.StoreInt32(&collector.down, 0)
+	if err := spoolPush(b); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(collector.received(), ""); got != "abcd" {
+		t.Errorf("replayed %q, want %q", got, "abcd")
+	}
+	// The replay keeps the key the batch was first sent with
+	if keys := collector.requestKeys(); keys[0] != keys[3] {
+		t.Errorf("key of the replayed batch %q, first sent with %q", keys[3], keys[0])
+	}
+	if n := spooledFiles(t, dir); n != 0 {
+		t.Errorf("%d batches left in the spool", n)
+	}
+}
+
+func TestBatchSpoolDrop(t *testing.T) {
+	collector := &spoolCollector{down: 1}
+	srv := httptest.NewServer(collector)
+	defer srv.Close()
+	dir := t.TempDir()
+
+	// Room for two batches of 3 bytes
+	queue := []string{"aaa", "bbb", "ccc"}
+	b := spoolSink(t, srv.URL, dir, 6, &queue)
+	spoolPush(b)
+	spoolPush(b)
+	if err := spoolPush(b); err == nil || !strings.Contains(err.Error(), "dropped 1 records, 2 kept in the spool: spool full") {
+		t.Fatalf("push beyond the cap: err = %v", err)
+	}
+
+	atomic.StoreInt32(&collector.down, 0)
+	if err := spoolPush(b); err != nil {
+		t.Fatal(err)
+	}
+
+	// A rejected batch is dropped, the next ones still delivered
+	queue = []string{"rej", "ddd"}
+	if err := spoolPush(b); err == nil || !strings.Contains(err.Error(), "dropped 1 records") {
+		t.Errorf("push of a rejected batch: err = %v", err)
+	}
+	if err := spoolPush(b); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(collector.received(), ","); got != "bbb,ccc,ddd" {
+		t.Errorf("delivered %q, want %q", got, "bbb,ccc,ddd")
+	}
+	if n := spooledFiles(t, dir); n != 0 {
+		t.Errorf("%d batches left in the spool", n)
+	}
+
+	// Batches of another encoding are dropped
+	if err := os.WriteFile(filepath.Join(dir, "test", "00000000000000000001-000001-4-key.pb"), []byte("x"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := spoolPush(b); err == nil || !strings.Contains(err.Error(), "dropped 4 records") {
+		t.Errorf("push with a stale batch: err = %v", err)
+	}
+}
+
+func TestBatchSinkAcknowledge(t *testing.T) {
+	collector := &spoolCollector{down: 1}
+	srv := httptest.NewServer(collector)
+	defer srv.Close()
+
+	// Without spool directory the batch is kept in memory
+	queue := []string{"a"}
+	b := newBatchSink(&ProjectInfrastructure{}, "test", "push", srv.URL, func() ([]byte, int, error) {
+		if len(queue) == 0 {
+			return nil, 0, nil
+		}
+		body := queue[0]
+		queue = queue[1:]
+		return []byte(body), 1, nil
+	})
+	for range []int{1, 2} {
+		if err := spoolPush(b); err == nil || !strings.Contains(err.Error(), "1 records kept") {
+			t.Fatalf("push while down: err = %v", err)
+		}
+	}
+	atomic.StoreInt32(&collector.down, 0)
+	if err := spoolPush(b); err != nil {
+		t.Fatal(err)
+	}
+	queue = []string{"b"}
+	if err := spoolPush(b); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(collector.received(), ""); got != "ab" {
+		t.Fatalf("delivered %q, want %q", got, "ab")
+	}
+
+	// The key of a batch is the same on every attempt, another one for the next
+	keys := collector.requestKeys()
+	if len(keys) < 3 {
+		t.Fatalf("%d requests, want at least 3", len(keys))
+	}
+	first, last := keys[:len(keys)-1], keys[len(keys)-1]
+	for _, k := range first {
+		if k == "" || k != first[0] {
+			t.Errorf("keys of the first batch %q, want one", first)
+			break
+		}
+	}
+	if last == "" || last == first[0] {
+		t.Errorf("key of the second batch %q, first %q", last, first[0])
+	}
+}
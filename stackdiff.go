@@ -0,0 +1,106 @@
+package infrastructure
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Fingerprints whose last printed stack is kept, the oldest are forgotten
+// all at once past this.
+const _stackDiffMaxEntries = 1024
+
+// Last printed stack of each fingerprint, recurring stacks are printed as
+// their changed frames.
+type stackDiffs struct {
+	mu      sync.Mutex
+	entries map[string]*stackDiffEntry
+}
+
+type stackDiffEntry struct {
+	ref    string
+	seq    int
+	frames map[string]bool
+}
+
+func newStackDiffs() *stackDiffs {
+	return &stackDiffs{entries: make(map[string]*stackDiffEntry)}
+}
+
+// Split a printed chain into its message lines and frames, a function line
+// together with its file line.
+func stackUnits(_chain string) []string {
+	lines := strings.Split(_chain, "\n")
+	units := make([]string, 0, len(lines))
+	for _, line := range lines {
+		if strings.HasPrefix(line, "\t") && len(units) > 0 {
+			units[len(units)-1] += "\n" + line
+			continue
+		}
+		units = append(units, line)
+	}
+	return units
+}
+
+// Replace the chain of a recurring stack with its frames missing from the
+// last printed one, and return the reference fields of the record.
+func (pm *ProjectInfrastructure) diffStack(_module string, _err error) (error, logrus.Fields) {
+	fp := Fingerprint(_module, _err)
+	chain := fmt.Sprintf("%+v", _err)
+	units := stackUnits(chain)
+
+	d := pm.stackDiffs
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	last, ok := d.entries[fp]
+	if !ok {
+		if len(d.entries) >= _stackDiffMaxEntries {
+			d.entries = make(map[string]*stackDiffEntry)
+		}
+		last = &stackDiffEntry{}
+		d.entries[fp] = last
+	}
+	// The message always comes first, the frames follow.
+	var changed []string
+	for _, u := range units[1:] {
+		if !last.frames[u] {
+			changed = append(changed, u)
+		}
+	}
+	if ok && len(changed) == 0 {
+		return &diffedError{error: _err, chain: fmt.Sprintf("%s\n[stack unchanged since %s]", units[0], last.ref)},
+			logrus.Fields{"stack_ref": last.ref}
+	}
+
+	base := last.ref
+	last.seq++
+	last.ref = fmt.Sprintf("%s.%d", fp, last.seq)
+	last.frames = make(map[string]bool, len(units))
+	for _, u := range units {
+		last.frames[u] = true
+	}
+	if !ok {
+		return _err, logrus.Fields{"stack_ref": last.ref}
+	}
+	return &diffedError{error: _err, chain: fmt.Sprintf("%s\n[stack changed since %s]\n%s", units[0], base, strings.Join(changed, "\n"))},
+		logrus.Fields{"stack_ref": last.ref, "stack_base": base}
+}
+
+// An error printing a stack diff for %+v, otherwise the error it wraps.
+type diffedError struct {
+	error
+	chain string
+}
+
+func (e *diffedError) Cause() error { return e.error }
+
+func (e *diffedError) Format(_s fmt.State, _verb rune) {
+	if _verb == 'v' && _s.Flag('+') {
+		fmt.Fprint(_s, e.chain)
+		return
+	}
+	fmt.Fprint(_s, e.Error())
+}
@@ -0,0 +1,147 @@
+package infrastructure
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// Lifecycle state of a ProjectInfrastructure.
+type State int
+
+const (
+	// NewProjectInfrastructure is initializing the components
+	StateStarting State = iota
+	// Ready for use
+	StateRunning
+	// ResourceRelease started, project goroutines are asked to stop and drained
+	StateQuiescing
+	// Goroutines have exited, providers are flushed and internals stopped
+	StateStopping
+	// Everything is released
+	StateStopped
+)
+
+var stateNames = [...]string{"Starting", "Running", "Quiescing", "Stopping", "Stopped"}
+
+func (s State) String() string {
+	if s < 0 || int(s) >= len(stateNames) {
+		return "Unknown"
+	}
+	return stateNames[s]
+}
+
+// Matched with errors.Is by every StateError.
+var ErrInvalidState = errors.New("invalid state")
+
+// Returned when an API is used in a state that does not allow it.
+type StateError struct {
+	Op    string
+	State State
+}
+
+func (e *StateError) Error() string {
+	return fmt.Sprintf("%s in state %s: %s", e.Op, e.State, ErrInvalidState)
+}
+
+func (e *StateError) Unwrap() error {
+	return ErrInvalidState
+}
+
+func (pm *ProjectInfrastructure) State() State {
+	pm.stateMu.RLock()
+	defer pm.stateMu.RUnlock()
+	return pm.state
+}
+
+// Move to the next state if the current one is the expected one.
+func (pm *ProjectInfrastructure) transition(_op string, _from, _to State) error {
+	pm.stateMu.Lock()
+	defer pm.stateMu.Unlock()
+
+	if pm.state != _from {
+		return &StateError{Op: _op, State: pm.state}
+	}
+	pm.state = _to
+	return nil
+}
+
+// Register work on the WaitGroup, refused once shutdown started so that
+// Add never races with the Wait of ResourceRelease.
+func (pm *ProjectInfrastructure) addWork(_op string) error {
+	pm.stateMu.RLock()
+	defer pm.stateMu.RUnlock()
+
+	if pm.state != StateRunning {
+		return &StateError{Op: _op, State: pm.state}
+	}
+	pm.WaitGroup.Add(1)
+	return nil
+}
+
+/*
+Run the function in a goroutine tracked by the WaitGroup
+
+The function receives GoroutineCancel and must return once it is done,
+ResourceRelease waits for it. A panic is recovered and logged with its stack.
+Returns ErrInvalidState once shutdown started.
+*/
+func (pm *ProjectInfrastructure) Go(_fn func(ctx context.Context)) error {
+	if err := pm.addWork("Go()"); err != nil {
+		return err
+	}
+	go func() {
+		defer pm.WaitGroup.Done()
+		defer func() {
+			if r := recover(); r != nil {
+				pm.logOutput("infra", "error", errors.New("panic recovered in Go()"), false, panicFields(r))
+			}
+		}()
+		_fn(pm.GoroutineCancel)
+	}()
+	return nil
+}
+
+// Release resources like ResourceRelease, returning ErrInvalidState when
+// the instance is not running, e.g. released twice.
+func (pm *ProjectInfrastructure) Shutdown() error {
+	if err := pm.transition("Shutdown()", StateRunning, StateQuiescing); err != nil {
+		return err
+	}
+
+	// The release function fails the shutdown like a release hook.
+	var failed []string
+	if err := pm.releaseFunc(); err != nil {
+		pm.logOutput("release", "error", errors.Errorf("release function: %v", err), false, nil)
+		failed = append(failed, "release function")
+	}
+	failed = append(failed, pm.runReleaseHooks()...)
+	pm.stopComponents()
+
+	pm.goroutineCancelFunc()
+	pm.WaitGroup.Wait()
+	pm.removeTempDirs()
+	pm.reportReleaseFailures(failed)
+	pm.saveCounters()
+	if pm.deduper != nil {
+		pm.stopDedupe()
+	}
+	if pm.budgets != nil {
+		pm.stopBudgets()
+	}
+
+	pm.setState(StateStopping)
+	pm.flushProviders()
+	pm.closeResources()
+	pm.cancelFunc()
+
+	pm.setState(StateStopped)
+	return nil
+}
+
+func (pm *ProjectInfrastructure) setState(_state State) {
+	pm.stateMu.Lock()
+	defer pm.stateMu.Unlock()
+	pm.state = _state
+}
@@ -0,0 +1,58 @@
+package infrastructure
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestShutdownReleaseFuncFailure(t *testing.T) {
+	tests := []struct {
+		name     string
+		release  func() error
+		exitCode int
+	}{
+		{"clean", func() error { return nil }, 0},
+		{"failed", func() error { return errors.New("close db") }, 3},
+	}
+	for _, tt := range tests {
+		pm, err := NewProjectInfrastructure(context.Background(),
+			WithLibraryMode(true),
+			WithResourceRleaseFunc(tt.release),
+			WithReleaseFailureExitCode(3),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pm.State() != StateRunning {
+			t.Fatalf("%s: state %s after creation", tt.name, pm.State())
+		}
+		if err := pm.Shutdown(); err != nil {
+			t.Fatal(err)
+		}
+		if pm.ExitCode() != tt.exitCode {
+			t.Errorf("%s: exit code %d, want %d", tt.name, pm.ExitCode(), tt.exitCode)
+		}
+		if err := pm.Shutdown(); !errors.Is(err, ErrInvalidState) {
+			t.Errorf("%s: second shutdown err = %v", tt.name, err)
+		}
+	}
+}
+
+func TestStateString(t *testing.T) {
+	tests := []struct {
+		state State
+		want  string
+	}{
+		{StateStarting, "Starting"},
+		{StateStopped, "Stopped"},
+		{State(-1), "Unknown"},
+		{State(42), "Unknown"},
+	}
+	for _, tt := range tests {
+		if got := tt.state.String(); got != tt.want {
+			t.Errorf("State(%d) = %q, want %q", tt.state, got, tt.want)
+		}
+	}
+}
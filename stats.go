@@ -0,0 +1,49 @@
+package infrastructure
+
+import "sync"
+
+// Records emitted per module and severity.
+type recordStats struct {
+	mu     sync.Mutex
+	counts map[string]map[string]uint64
+}
+
+func (s *recordStats) add(_module, _severity string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.counts == nil {
+		s.counts = make(map[string]map[string]uint64)
+	}
+	bySeverity, ok := s.counts[_module]
+	if !ok {
+		bySeverity = make(map[string]uint64)
+		s.counts[_module] = bySeverity
+	}
+	bySeverity[_severity]++
+}
+
+/*
+Number of records emitted since start, keyed by module then severity
+
+Records dropped by filters, deduplication, sampling or budgets are not
+counted. The maps are a copy, e.g. to assert that a run logged no error:
+
+	for module, counts := range pm.Stats() {
+		if counts["error"] > 0 { ... }
+	}
+*/
+func (pm *ProjectInfrastructure) Stats() map[string]map[string]uint64 {
+	pm.stats.mu.Lock()
+	defer pm.stats.mu.Unlock()
+
+	stats := make(map[string]map[string]uint64, len(pm.stats.counts))
+	for module, counts := range pm.stats.counts {
+		c := make(map[string]uint64, len(counts))
+		for severity, n := range counts {
+			c[severity] = n
+		}
+		stats[module] = c
+	}
+	return stats
+}
@@ -0,0 +1,24 @@
+//go:build windows || plan9
+
+package infrastructure
+
+import (
+	"runtime"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+type syslogWriter struct{}
+
+func openSyslog(_opts ProjectInfrastructureOptions) (*syslogWriter, error) {
+	return nil, errors.Errorf("syslog output is not supported on %s", runtime.GOOS)
+}
+
+func (s *syslogWriter) Write(_p []byte) (int, error) {
+	return 0, errors.New("syslog output is not supported")
+}
+
+func (s *syslogWriter) WriteLevel(_level logrus.Level, _p []byte) error {
+	return errors.New("syslog output is not supported")
+}
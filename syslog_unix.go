@@ -0,0 +1,72 @@
+//go:build !windows && !plan9
+
+package infrastructure
+
+import (
+	"log/syslog"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+var syslogFacilities = map[string]syslog.Priority{
+	"kern":     syslog.LOG_KERN,
+	"user":     syslog.LOG_USER,
+	"mail":     syslog.LOG_MAIL,
+	"daemon":   syslog.LOG_DAEMON,
+	"auth":     syslog.LOG_AUTH,
+	"syslog":   syslog.LOG_SYSLOG,
+	"lpr":      syslog.LOG_LPR,
+	"news":     syslog.LOG_NEWS,
+	"uucp":     syslog.LOG_UUCP,
+	"cron":     syslog.LOG_CRON,
+	"authpriv": syslog.LOG_AUTHPRIV,
+	"ftp":      syslog.LOG_FTP,
+	"local0":   syslog.LOG_LOCAL0,
+	"local1":   syslog.LOG_LOCAL1,
+	"local2":   syslog.LOG_LOCAL2,
+	"local3":   syslog.LOG_LOCAL3,
+	"local4":   syslog.LOG_LOCAL4,
+	"local5":   syslog.LOG_LOCAL5,
+	"local6":   syslog.LOG_LOCAL6,
+	"local7":   syslog.LOG_LOCAL7,
+}
+
+// Write records to syslog with the priority matching their severity.
+type syslogWriter struct {
+	w *syslog.Writer
+}
+
+func openSyslog(_opts ProjectInfrastructureOptions) (*syslogWriter, error) {
+	facility, ok := syslogFacilities[_opts.SyslogFacility]
+	if !ok {
+		return nil, errors.Errorf("invalid syslog facility %s", _opts.SyslogFacility)
+	}
+	w, err := syslog.Dial(_opts.SyslogNetwork, _opts.SyslogAddress, facility|syslog.LOG_INFO, _opts.SyslogTag)
+	if err != nil {
+		return nil, errors.Wrap(err, "connect syslog")
+	}
+	return &syslogWriter{w: w}, nil
+}
+
+func (s *syslogWriter) Write(_p []byte) (int, error) {
+	return s.w.Write(_p)
+}
+
+func (s *syslogWriter) WriteLevel(_level logrus.Level, _p []byte) error {
+	msg := string(_p)
+	switch _level {
+	case logrus.PanicLevel:
+		return s.w.Emerg(msg)
+	case logrus.FatalLevel:
+		return s.w.Crit(msg)
+	case logrus.ErrorLevel:
+		return s.w.Err(msg)
+	case logrus.WarnLevel:
+		return s.w.Warning(msg)
+	case logrus.InfoLevel:
+		return s.w.Info(msg)
+	default:
+		return s.w.Debug(msg)
+	}
+}
@@ -0,0 +1,86 @@
+package infrastructure
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+type tempDirs struct {
+	mu   sync.Mutex
+	dirs []string
+}
+
+// Directory holding the temp directories of this program, one entry per
+// directory named "<pid>-<name>-<random>".
+func tempDirRoot() string {
+	return filepath.Join(os.TempDir(), "infra-"+filepath.Base(os.Args[0]))
+}
+
+/*
+Create a temp directory removed during ResourceRelease
+
+Directories left behind by crashed runs of the same program are removed at
+startup.
+*/
+func (pm *ProjectInfrastructure) TempDir(_name string) (string, error) {
+	if err := pm.addWork("TempDir()"); err != nil {
+		return "", err
+	}
+	defer pm.WaitGroup.Done()
+
+	root := tempDirRoot()
+	if err := os.MkdirAll(root, 0o700); err != nil {
+		return "", errors.Wrap(err, "create temp root")
+	}
+	name := strings.ReplaceAll(_name, string(filepath.Separator), "_")
+	dir, err := os.MkdirTemp(root, strconv.Itoa(os.Getpid())+"-"+name+"-")
+	if err != nil {
+		return "", errors.Wrap(err, "create temp dir")
+	}
+
+	pm.temp.mu.Lock()
+	pm.temp.dirs = append(pm.temp.dirs, dir)
+	pm.temp.mu.Unlock()
+	return dir, nil
+}
+
+func (pm *ProjectInfrastructure) removeTempDirs() {
+	pm.temp.mu.Lock()
+	defer pm.temp.mu.Unlock()
+
+	for _, dir := range pm.temp.dirs {
+		if err := os.RemoveAll(dir); err != nil {
+			pm.logOutput("infra", "warn", errors.Errorf("remove temp dir %s: %v", dir, err), false, nil)
+		}
+	}
+	pm.temp.dirs = nil
+}
+
+// Remove the temp directories of processes that no longer run.
+func (pm *ProjectInfrastructure) sweepTempDirs() {
+	entries, err := os.ReadDir(tempDirRoot())
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		pidText, _, ok := strings.Cut(e.Name(), "-")
+		if !ok {
+			continue
+		}
+		pid, err := strconv.Atoi(pidText)
+		if err != nil || pid == os.Getpid() || processAlive(pid) {
+			continue
+		}
+		dir := filepath.Join(tempDirRoot(), e.Name())
+		if err := os.RemoveAll(dir); err != nil {
+			pm.logOutput("infra", "warn", errors.Errorf("remove orphan temp dir %s: %v", dir, err), false, nil)
+			continue
+		}
+		pm.logOutput("infra", "info", errors.Errorf("removed orphan temp dir %s", dir), false, nil)
+	}
+}
@@ -0,0 +1,118 @@
+package infrastructure
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// A debounced change of a watched path.
+type Event struct {
+	// Path of the file that changed
+	Path string
+	// Operations seen during the debounce interval, e.g. "WRITE|RENAME"
+	Op string
+}
+
+/*
+Watch a file or directory and call the handler on changes
+
+Events are debounced per file by WatchDebounce, handler errors are logged
+with the name as module and the watch stops when project goroutines are
+asked to stop. A file is watched through its directory so that editors and
+tools replacing it by rename are still seen.
+*/
+func (pm *ProjectInfrastructure) WatchPath(_name, _path string, _handler func(Event) error) error {
+	path, err := filepath.Abs(_path)
+	if err != nil {
+		return errors.Wrapf(err, "watch %s", _path)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return errors.Wrapf(err, "watch %s", _path)
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return errors.Wrapf(err, "watch %s", _path)
+	}
+	dir, file := path, ""
+	if !info.IsDir() {
+		dir, file = filepath.Dir(path), path
+	}
+	if err := watcher.Add(dir); err != nil {
+		watcher.Close()
+		return errors.Wrapf(err, "watch %s", _path)
+	}
+
+	err = pm.Go(func(ctx context.Context) {
+		defer watcher.Close()
+		pm.watchLoop(ctx, _name, watcher, file, _handler)
+	})
+	if err != nil {
+		watcher.Close()
+	}
+	return err
+}
+
+func (pm *ProjectInfrastructure) watchLoop(_ctx context.Context, _name string, _watcher *fsnotify.Watcher, _file string, _handler func(Event) error) {
+	var mu sync.Mutex
+	pending := make(map[string]fsnotify.Op)
+	timers := make(map[string]*time.Timer)
+	// Debounced handler calls still running when the watch stops are waited for.
+	var calls sync.WaitGroup
+	defer calls.Wait()
+
+	for {
+		select {
+		case <-_ctx.Done():
+			mu.Lock()
+			for _, t := range timers {
+				if t.Stop() {
+					calls.Done()
+				}
+			}
+			mu.Unlock()
+			return
+		case err, ok := <-_watcher.Errors:
+			if !ok {
+				return
+			}
+			pm.logOutput(_name, "warn", errors.Errorf("watch error: %v", err), false, nil)
+		case ev, ok := <-_watcher.Events:
+			if !ok {
+				return
+			}
+			if _file != "" && filepath.Clean(ev.Name) != _file {
+				continue
+			}
+
+			mu.Lock()
+			pending[ev.Name] |= ev.Op
+			if _, scheduled := timers[ev.Name]; !scheduled {
+				calls.Add(1)
+				name := ev.Name
+				timers[name] = time.AfterFunc(pm.options.WatchDebounce, func() {
+					defer calls.Done()
+
+					mu.Lock()
+					op := pending[name]
+					delete(pending, name)
+					delete(timers, name)
+					mu.Unlock()
+
+					if err := _handler(Event{Path: name, Op: op.String()}); err != nil {
+						pm.logOutput(_name, "error", err, false, logrus.Fields{"path": name})
+					}
+				})
+			}
+			mu.Unlock()
+		}
+	}
+}